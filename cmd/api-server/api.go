@@ -4,13 +4,32 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"log/slog"
 	"net/http"
+	"slices"
 	"time"
 
+	"github.com/azhovan/currywurst/internal/clock"
+	"github.com/azhovan/currywurst/internal/events"
+	"github.com/azhovan/currywurst/internal/idempotency"
+	"github.com/azhovan/currywurst/internal/inventory"
+	"github.com/azhovan/currywurst/internal/journal"
+	"github.com/azhovan/currywurst/internal/liveness"
 	"github.com/azhovan/currywurst/internal/orders"
+	"github.com/azhovan/currywurst/internal/pricing"
+	"github.com/azhovan/currywurst/internal/receipts"
+	"github.com/azhovan/currywurst/internal/routing"
+	"github.com/azhovan/currywurst/internal/schedule"
+	"github.com/azhovan/currywurst/internal/store"
 	"github.com/azhovan/currywurst/internal/terminals"
 )
 
+// orderTimeout is the time the handler waits for an order to be processed, before it is cancelled
+const orderTimeout = time.Minute * 10
+
+// retryAfter is the number of seconds a client waits before it retries a request that found the service unavailable
+const retryAfter = "2"
+
 // Handler is a struct that handles HTTP requests.
 type Handler struct {
 	// pins is a map of valid pins.
@@ -22,49 +41,304 @@ type Handler struct {
 	// ...
 	pins map[string]bool
 
-	// terminals is a map of terminals that receive the customer orders.
-	// The key is the name of the terminal (by default it is terminal-1, terminal-2, terminal-3).
-	// terminals discover the terminal that customer's request should be sent to.
-	terminals map[string]*terminals.Terminal
+	// adminPins is a map of valid pins of the staff, they give access to the admin endpoints.
+	// Like the pins, this is for demonstration only.
+	adminPins map[string]bool
+
+	// priorityPins is a map of the pins that can give their orders a priority class, so they skip the queue,
+	// i.e. the pin of the staff counter. They are valid pins as well.
+	priorityPins map[string]bool
+
+	// terminals is the registry of the terminals that receive the customer orders.
+	// The key is the name of the terminal (by default it is terminal-0, terminal-1, terminal-2).
+	// terminals discover the terminal that customer's request should be sent to,
+	// and they are added, renamed, paused and removed through the admin API while the server is running.
+	terminals *terminals.Registry
+
+	// pricing is the pricing engine that is evaluated over the basket of every order before payment.
+	// When it is nil the orders are paid at the regular prices.
+	pricing *pricing.Engine
+
+	// schedule holds the opening hours and the time based price overrides of the stand.
+	// When it is nil the stand is open around the clock at the regular prices.
+	schedule *schedule.Schedule
+
+	// receipts keeps the receipts of the paid orders, so they can be retrieved later by order id.
+	receipts *receipts.Store
+
+	// orders keeps a snapshot of every order that is sent to a terminal, it is updated after every
+	// transition of the order, so the status of the order can be looked up after the request is gone.
+	// By default the orders are kept in memory.
+	orders store.OrderStore
+
+	// active keeps the orders that are not settled yet, so they can be cancelled by id.
+	active *activeOrders
+
+	// attached counts the orders, kiosk sessions and event streams attached to the terminals,
+	// a terminal is only renamed while nothing is attached to it.
+	attached *attachments
+
+	// events fans out the transitions of the orders to the clients that follow them as server-sent events.
+	events *events.Broker
+
+	// idempotency remembers the order requests by their Idempotency-Key header,
+	// so a request that is retried by the client does not place a second order.
+	idempotency *idempotency.Store
+
+	// heartbeat is the interval of the comments sent over an idle event stream,
+	// they keep the connection open through proxies and let the server notice a client that is gone.
+	heartbeat time.Duration
+
+	// router chooses the terminal of the orders that do not name one, by default the terminal
+	// with the fewest queued orders.
+	router *routing.Router
+
+	// queueTimeout is how long an order waits for a free slot in a full terminal,
+	// before it is rejected with 503 Service Unavailable.
+	queueTimeout time.Duration
+
+	// clock tells the time the receipts are issued at.
+	clock clock.Clock
+
+	// inventory keeps the stock of the ingredients, the products that can not be made
+	// are marked unavailable in the catalog and rejected. When it is nil the ingredients are not tracked.
+	inventory *inventory.Inventory
+
+	// kiosks keeps track of the heartbeats of the physical kiosks attached to the terminals.
+	// When it is nil the heartbeats are not accepted.
+	kiosks *liveness.Monitor
+
+	// journal records the payments of the orders with their per-line breakdown.
+	// When it is nil the payments are only kept in the order store.
+	journal *journal.Journal
+
+	// logger logs the failures that can not be answered to a client, i.e. a snapshot of an order
+	// that could not be saved after the response was written. By default it is the default logger.
+	logger *slog.Logger
+}
+
+// HandlerOption is a function that modifies the handler
+type HandlerOption func(*Handler)
+
+// WithPricing sets the pricing engine of the handler
+func WithPricing(engine *pricing.Engine) HandlerOption {
+	return func(h *Handler) {
+		h.pricing = engine
+	}
+}
+
+// WithSchedule sets the schedule of the handler
+func WithSchedule(schedule *schedule.Schedule) HandlerOption {
+	return func(h *Handler) {
+		h.schedule = schedule
+	}
+}
+
+// WithInventory sets the inventory of the handler
+func WithInventory(inv *inventory.Inventory) HandlerOption {
+	return func(h *Handler) {
+		h.inventory = inv
+	}
+}
+
+// WithOrderStore sets the order store of the handler
+func WithOrderStore(s store.OrderStore) HandlerOption {
+	return func(h *Handler) {
+		h.orders = s
+	}
+}
+
+// WithIdempotency sets the store of the idempotency keys of the handler,
+// the store decides how long the keys are kept
+func WithIdempotency(store *idempotency.Store) HandlerOption {
+	return func(h *Handler) {
+		h.idempotency = store
+	}
+}
+
+// WithHeartbeat sets the interval of the heartbeats of the event streams, and of the pings of the kiosk connections
+func WithHeartbeat(interval time.Duration) HandlerOption {
+	return func(h *Handler) {
+		h.heartbeat = interval
+	}
+}
+
+// WithRouter sets the router that chooses the terminal of the orders that do not name one
+func WithRouter(router *routing.Router) HandlerOption {
+	return func(h *Handler) {
+		h.router = router
+	}
+}
+
+// WithQueueTimeout sets how long an order waits for a free slot in a full terminal
+func WithQueueTimeout(timeout time.Duration) HandlerOption {
+	return func(h *Handler) {
+		h.queueTimeout = timeout
+	}
+}
+
+// WithLiveness sets the monitor of the heartbeats of the kiosks
+func WithLiveness(monitor *liveness.Monitor) HandlerOption {
+	return func(h *Handler) {
+		h.kiosks = monitor
+	}
+}
+
+// WithClock sets the clock of the handler
+func WithClock(clock clock.Clock) HandlerOption {
+	return func(h *Handler) {
+		h.clock = clock
+	}
+}
+
+// WithJournal sets the journal the payments are recorded in
+func WithJournal(j *journal.Journal) HandlerOption {
+	return func(h *Handler) {
+		h.journal = j
+	}
+}
+
+// WithHandlerLogger sets the logger of the handler
+func WithHandlerLogger(logger *slog.Logger) HandlerOption {
+	return func(h *Handler) {
+		h.logger = logger
+	}
 }
 
 // OrderRequest is a struct type that represents an order request from a customer.
 type OrderRequest struct {
 	// TerminalId is a string that specifies the id of the terminal that will process the order.
+	// When it is omitted, the router of the handler chooses a terminal.
 	TerminalId string `json:"terminalId"`
+	// CustomerId optionally identifies the customer, the consistent hash routing sends the orders
+	// of a customer to the same terminal. The pin is used when it is omitted.
+	CustomerId string `json:"customerId,omitempty"`
 	// OrderType is a string that specifies the type of the order, such as `vegan` or `non-vegan`.
+	// It is a shortcut for an order with a single item, and it can not be combined with Items.
 	OrderType string `json:"orderType"`
+	// Items specifies the lines of a basket order, each one with its own order type and quantity.
+	Items []OrderItem `json:"items"`
+	// Coupon specifies an optional coupon code that grants a discount.
+	Coupon string `json:"coupon"`
+	// EatIn specifies whether the order is eaten in or taken away, it decides the VAT rate of the food.
+	EatIn bool `json:"eatIn"`
+	// Priority specifies the priority class of the order, such as `staff` or `accessibility`, the orders of a
+	// higher class skip the queue. Only the priority pins can set it, it is `normal` when omitted.
+	Priority string `json:"priority,omitempty"`
 	// Price specifies the inserted price of the order in cents sent by customer.
 	InsertedPrice int `json:"insertedPrice"`
 }
 
+// OrderItem is a struct type that represents a single line of a basket order request.
+type OrderItem struct {
+	// OrderType is a string that specifies the type of the item, such as `vegan` or `non-vegan`.
+	OrderType string `json:"orderType"`
+	// Quantity specifies the number of units of the item, it defaults to 1 when omitted.
+	Quantity int `json:"quantity"`
+}
+
 // OrderResponse is a struct type that represents an order response to the customer.
 type OrderResponse struct {
+	// OrderId is the id of the order, it can be used to retrieve the receipt later.
+	OrderId string `json:"orderId"`
+	// TerminalId is the id of the terminal that processed the order, it is the one chosen by the router
+	// when the customer did not choose one.
+	TerminalId string `json:"terminalId"`
 	// Returned is the amount of money returned to the customer in a human-readable format.
 	Returned string `json:"returned"`
+	// Subtotal is the price of the whole order in cents before discounts.
+	Subtotal int `json:"subtotal"`
+	// Discounts lists the discounts granted by the pricing rules.
+	Discounts []OrderDiscount `json:"discounts"`
+	// Total is the price of the whole order in cents.
+	Total int `json:"total"`
+	// Items is the per-line breakdown of the order.
+	Items []OrderLine `json:"items"`
+	// Receipt is the itemised receipt of the order, including the VAT breakdown.
+	Receipt *receipts.Receipt `json:"receipt,omitempty"`
+}
+
+// OrderDiscount is a struct type that represents a discount granted to an order.
+type OrderDiscount struct {
+	// Rule is the name of the pricing rule that granted the discount.
+	Rule string `json:"rule"`
+	// Amount is the amount of the discount in cents.
+	Amount int `json:"amount"`
+}
+
+// OrderLine is a struct type that represents a single priced line of an order response.
+type OrderLine struct {
+	// OrderType is the type of the item, such as `vegan` or `non-vegan`.
+	OrderType string `json:"orderType"`
+	// Quantity is the number of units of the item.
+	Quantity int `json:"quantity"`
+	// UnitPrice is the price of a single unit in cents.
+	UnitPrice int `json:"unitPrice"`
+	// Total is the price of the line in cents.
+	Total int `json:"total"`
+	// PriceOverride is the name of the time based price override that changed the unit price, if any.
+	PriceOverride string `json:"priceOverride,omitempty"`
 }
 
-// NewHandler creates a new Handler with some hardcoded pins.
-func NewHandler(terminals map[string]*terminals.Terminal) *Handler {
-	return &Handler{
+// NewHandler creates a new Handler with some hardcoded pins and the given options.
+func NewHandler(terminals *terminals.Registry, opts ...HandlerOption) *Handler {
+	h := &Handler{
 		pins: map[string]bool{
 			"1234": true,
 			"5678": true,
 			"9012": true,
 		},
-		terminals: terminals,
+		adminPins: map[string]bool{
+			"4321": true,
+		},
+		priorityPins: map[string]bool{
+			"9012": true,
+		},
+		terminals:    terminals,
+		receipts:     receipts.NewStore(),
+		orders:       store.NewMemory(),
+		active:       newActiveOrders(),
+		attached:     newAttachments(terminals),
+		events:       events.NewBroker(),
+		heartbeat:    15 * time.Second,
+		queueTimeout: 5 * time.Second,
+		clock:        clock.System,
+		logger:       slog.Default(),
 	}
+
+	// apply the options
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	if h.idempotency == nil {
+		h.idempotency = idempotency.NewStore(h.clock, idempotency.DefaultRetention)
+	}
+	if h.router == nil {
+		// the policy is valid, so the router is always created
+		h.router, _ = routing.NewRouter(routing.LeastQueued)
+	}
+
+	return h
 }
 
 // RegisterRoutes registers the routes for the handler
 func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
-	mux.HandleFunc("/order", h.orderHandler)
+	mux.HandleFunc("/order", h.idempotent(h.orderHandler))
+	mux.HandleFunc("/orders", h.idempotent(h.ordersHandler))
+	mux.HandleFunc("/orders/", h.orderResourceHandler)
+	mux.HandleFunc("/receipts/", h.receiptHandler)
+	mux.HandleFunc("/terminals/", h.terminalsHandler)
+	mux.HandleFunc("/catalog", h.catalogHandler)
+	mux.HandleFunc("/admin/inventory", h.inventoryHandler)
+	mux.HandleFunc("/admin/terminals", h.adminTerminalsHandler)
+	mux.HandleFunc("/admin/terminals/", h.adminTerminalHandler)
 }
 
 // orderHandler handles the /order endpoint
 func (h *Handler) orderHandler(w http.ResponseWriter, r *http.Request) {
 	// check the method and the pin
-	if err := h.validateRequest(r); err != nil {
+	if err := h.validateRequest(r, http.MethodPost); err != nil {
 		h.writeJSONError(w, err)
 		return
 	}
@@ -76,30 +350,89 @@ func (h *Handler) orderHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// get the terminal by id
-	terminal, err := h.getTerminal(orderRequest.TerminalId)
+	// get the terminal by id, or let the router choose one
+	terminal, err := h.getTerminal(r, orderRequest)
 	if err != nil {
 		h.writeJSONError(w, err)
 		return
 	}
 
 	// send the order to the terminal and wait for the response
-	response, err := h.sendOrder(r.Context(), terminal, orderRequest)
+	order, err := h.sendOrder(r.Context(), terminal, orderRequest)
 	if err != nil {
 		h.writeJSONError(w, err)
 		return
 	}
 
+	h.writeOrderResponse(w, order)
+}
+
+// writeOrderResponse issues the receipt of a paid order and writes it to the client along with the order as JSON
+func (h *Handler) writeOrderResponse(w http.ResponseWriter, order *orders.Order) {
+	// the receipt is issued once the order is paid,
+	// and kept so the customer can retrieve it later
+	receipt := h.issueReceipt(order)
+
 	// write the response to the client as JSON
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(OrderResponse{Returned: response})
+	json.NewEncoder(w).Encode(newOrderResponse(order, receipt))
+
+	// the change and the receipt have been handed over, the customer collected the order
+	order.Collect()
+}
+
+// issueReceipt issues the receipt of a paid order and keeps it, so the customer can retrieve it later.
+func (h *Handler) issueReceipt(order *orders.Order) *receipts.Receipt {
+	receipt := receipts.New(order, h.clock.Now())
+	h.receipts.Put(receipt)
+	return receipt
+}
+
+// newOrderResponse builds the response of a processed order, including the per-line breakdown
+func newOrderResponse(order *orders.Order, receipt *receipts.Receipt) OrderResponse {
+	return OrderResponse{
+		OrderId:    order.ID,
+		TerminalId: order.TerminalID,
+		// the amount of money returned
+		// to the customer in a human-readable format
+		Returned:  order.Returned.Formatted,
+		Subtotal:  order.Subtotal(),
+		Discounts: newOrderDiscounts(order.Discounts),
+		Total:     order.Total(),
+		Items:     newOrderLines(order.Items),
+		Receipt:   receipt,
+	}
+}
+
+// newOrderLines builds the per-line breakdown of the given items
+func newOrderLines(items []orders.Item) []OrderLine {
+	lines := make([]OrderLine, 0, len(items))
+	for _, item := range items {
+		lines = append(lines, OrderLine{
+			OrderType:     item.OrderType.String(),
+			Quantity:      item.Quantity,
+			UnitPrice:     item.UnitPrice,
+			Total:         item.Total(),
+			PriceOverride: item.PriceOverride,
+		})
+	}
+	return lines
+}
+
+// newOrderDiscounts builds the list of the given discounts
+func newOrderDiscounts(discounts []orders.Discount) []OrderDiscount {
+	list := make([]OrderDiscount, 0, len(discounts))
+	for _, discount := range discounts {
+		list = append(list, OrderDiscount{Rule: discount.Rule, Amount: discount.Amount})
+	}
+	return list
 }
 
 // validateRequest checks the method and the pin of the request
-func (h *Handler) validateRequest(r *http.Request) *httpError {
+func (h *Handler) validateRequest(r *http.Request, method string) *httpError {
 	// check the method
-	if r.Method != http.MethodPost {
+	if r.Method != method {
 		return &httpError{"Method not allowed", http.StatusMethodNotAllowed}
 	}
 
@@ -112,6 +445,22 @@ func (h *Handler) validateRequest(r *http.Request) *httpError {
 	return nil
 }
 
+// validateAdminRequest checks the method and the admin pin of the request
+func (h *Handler) validateAdminRequest(r *http.Request, methods ...string) *httpError {
+	// check the method
+	if !slices.Contains(methods, r.Method) {
+		return &httpError{"Method not allowed", http.StatusMethodNotAllowed}
+	}
+
+	// check the pin
+	pin := r.Header.Get("X-Pin")
+	if !h.adminPins[pin] {
+		return &httpError{"Invalid pin", http.StatusUnauthorized}
+	}
+
+	return nil
+}
+
 // parseOrderRequest decodes the request body into an Order struct
 func (h *Handler) parseOrderRequest(r *http.Request) (*OrderRequest, *httpError) {
 	orderRequest := OrderRequest{}
@@ -120,16 +469,74 @@ func (h *Handler) parseOrderRequest(r *http.Request) (*OrderRequest, *httpError)
 		return nil, &httpError{"Bad request", http.StatusBadRequest}
 	}
 
-	if orderRequest.TerminalId == "" {
-		return nil, &httpError{"terminalId is missing", http.StatusBadRequest}
+	if err := orderRequest.validate(); err != nil {
+		return nil, err
+	}
+	if err := h.authorizePriority(r.Header.Get("X-Pin"), &orderRequest); err != nil {
+		return nil, err
 	}
 
 	return &orderRequest, nil
 }
 
-// getTerminal returns the terminal by id or an error if not found
-func (h *Handler) getTerminal(id string) (*terminals.Terminal, *httpError) {
-	terminal, ok := h.terminals[id]
+// authorizePriority checks that only the priority pins skip the queue, every other pin gets the normal class
+func (h *Handler) authorizePriority(pin string, orderRequest *OrderRequest) *httpError {
+	priority := orders.Priority(orderRequest.Priority)
+	if priority.Rank() > orders.PriorityNormal.Rank() && !h.priorityPins[pin] {
+		return &httpError{"priority is reserved for the staff", http.StatusForbidden}
+	}
+	return nil
+}
+
+// validate checks the fields of the order request that do not depend on the terminal
+func (o *OrderRequest) validate() *httpError {
+	if o.OrderType != "" && len(o.Items) > 0 {
+		return &httpError{"orderType and items can not be combined", http.StatusBadRequest}
+	}
+
+	if !orders.Priority(o.Priority).IsValid() {
+		return &httpError{"invalid priority", http.StatusBadRequest}
+	}
+
+	return nil
+}
+
+// basketItems returns the items of the order request, a single orderType is
+// treated as a basket with one unit of it
+func (o *OrderRequest) basketItems() []orders.Item {
+	if len(o.Items) == 0 {
+		return []orders.Item{{OrderType: orders.OrderType(o.OrderType), Quantity: 1}}
+	}
+
+	items := make([]orders.Item, 0, len(o.Items))
+	for _, item := range o.Items {
+		quantity := item.Quantity
+		if quantity == 0 {
+			quantity = 1
+		}
+		items = append(items, orders.Item{OrderType: orders.OrderType(item.OrderType), Quantity: quantity})
+	}
+	return items
+}
+
+// getTerminal returns the terminal of the order request by id or an error if not found.
+// When the request has no terminal id, the router chooses a terminal and its id is set on the request.
+func (h *Handler) getTerminal(r *http.Request, orderRequest *OrderRequest) (*terminals.Terminal, *httpError) {
+	if orderRequest.TerminalId == "" {
+		key := orderRequest.CustomerId
+		if key == "" {
+			key = r.Header.Get("X-Pin")
+		}
+
+		terminalId, terminal, err := h.router.Route(h.terminals, key)
+		if err != nil {
+			return nil, &httpError{err.Error(), http.StatusServiceUnavailable}
+		}
+		orderRequest.TerminalId = terminalId
+		return terminal, nil
+	}
+
+	terminal, ok := h.terminals.Get(orderRequest.TerminalId)
 	if !ok {
 		return nil, &httpError{"invalid terminalId", http.StatusBadRequest}
 	}
@@ -138,30 +545,121 @@ func (h *Handler) getTerminal(id string) (*terminals.Terminal, *httpError) {
 }
 
 // sendOrder sends the order to the terminal and waits for the response
-func (h *Handler) sendOrder(ctx context.Context, terminal *terminals.Terminal, orderRequest *OrderRequest) (string, *httpError) {
+func (h *Handler) sendOrder(ctx context.Context, terminal *terminals.Terminal, orderRequest *OrderRequest) (*orders.Order, *httpError) {
+	// an idempotent request is retried by the client when the connection drops, so its order
+	// is not cancelled along with the request, and the retry gets the outcome of the order
+	if _, ok := idempotency.FromContext(ctx); ok {
+		ctx = context.WithoutCancel(ctx)
+	}
+
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	order, err := h.placeOrder(ctx, terminal, orderRequest)
+	if err != nil {
+		return nil, err
+	}
+	recordOrder(ctx, order.ID)
+
+	if err := h.awaitOrder(order); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// placeOrder builds the order out of customers request, prices it and puts it in the terminal queue.
+// The order lives as long as the given context, it returns as soon as the order is queued.
+func (h *Handler) placeOrder(ctx context.Context, terminal *terminals.Terminal, orderRequest *OrderRequest) (*orders.Order, *httpError) {
+	// orders are only accepted within the opening hours
+	if h.schedule != nil {
+		if err := h.schedule.CheckOpen(); err != nil {
+			return nil, &httpError{err.Error(), http.StatusUnprocessableEntity}
+		}
+	}
+
 	// build order object out of customers request to send to the terminal
 	// and send it to the terminal queue
-	order := orders.NewOrder(ctx, orderRequest.InsertedPrice, orders.OrderType(orderRequest.OrderType))
-	err := terminal.Put(order)
-	if err != nil {
-		return "", &httpError{err.Error(), http.StatusUnprocessableEntity}
+	items := orderRequest.basketItems()
+
+	// orders the stock can not make, in the quantities of the basket, are rejected before they reach the terminal.
+	// The worker consumes the ingredients atomically before the payment,
+	// so an order that passes this check may still be rejected there.
+	if h.inventory != nil {
+		if err := h.inventory.CanMake(items); err != nil {
+			return nil, &httpError{err.Error(), http.StatusConflict}
+		}
+	}
+
+	order := orders.NewBasketOrder(ctx, orderRequest.InsertedPrice, items)
+	order.TerminalID = orderRequest.TerminalId
+	order.Coupon = orderRequest.Coupon
+	order.EatIn = orderRequest.EatIn
+	order.Priority = orders.Priority(orderRequest.Priority)
+	// the terminals only take cash, the method is kept so the order history can be filtered by it
+	order.Payment = orders.PaymentCash
+
+	// the time based price overrides change the unit prices,
+	// so they are applied before the pricing rules
+	if h.schedule != nil {
+		h.schedule.Apply(order)
+	}
+
+	// the pricing rules are evaluated over the basket before the payment,
+	// so the worker charges the discounted total
+	if h.pricing != nil {
+		if err := h.pricing.Apply(order); err != nil {
+			return nil, &httpError{err.Error(), http.StatusBadRequest}
+		}
+	}
+
+	// the order refers to its terminal by id until it is settled, the terminal is not renamed in the meantime
+	if !h.attachOrder(order, terminal) {
+		return nil, &httpError{"the terminal is no longer available", http.StatusServiceUnavailable}
 	}
 
+	// the order is tracked from now on, so its status can be looked up
+	// while it is processed and after the customer's request is gone
+	if err := store.Track(h.orders, order, h.logger); err != nil {
+		order.Fail(err)
+		return nil, &httpError{err.Error(), http.StatusInternalServerError}
+	}
+	// the payment is recorded as soon as the order is paid, whether or not the customer waits for the receipt
+	if h.journal != nil {
+		journal.Track(h.journal, order, h.logger)
+	}
+	h.events.Track(order)
+	h.active.add(order)
+
+	// a full terminal is waited for a little while, then the customer is asked to come back later
+	// instead of holding the request until the queue moves
+	queueCtx, cancel := context.WithTimeout(ctx, h.queueTimeout)
+	defer cancel()
+	err := terminal.PutContext(queueCtx, order)
+	switch {
+	case errors.Is(err, terminals.ErrTerminalFull):
+		return nil, &httpError{terminals.ErrTerminalFull.Error(), http.StatusServiceUnavailable}
+	// a paused terminal or one under maintenance takes orders again later, a closed one does not
+	case errors.Is(err, terminals.ErrTerminalPaused), errors.Is(err, terminals.ErrTerminalMaintenance):
+		return nil, &httpError{err.Error(), http.StatusServiceUnavailable}
+	case errors.Is(err, terminals.ErrTerminalClosed):
+		return nil, &httpError{err.Error(), http.StatusGone}
+	case err != nil:
+		return nil, &httpError{err.Error(), http.StatusUnprocessableEntity}
+	}
+
+	return order, nil
+}
+
+// awaitOrder waits until the order is processed by the worker, and returns the error of the order if any
+func (h *Handler) awaitOrder(order *orders.Order) *httpError {
 	// wait until order is ready, or gave up after 10 minutes
-	orderTimeout := time.Minute * 10
-	err = order.WaitWithTimeout(orderTimeout)
+	err := order.WaitWithTimeout(orderTimeout)
 	// order has been processed
 	if err == nil {
 		er := order.OrderStatus.Error
 
 		if er == nil {
-			// the amount of money returned
-			// to the customer in a human-readable format
-			returned := order.OrderStatus.Returned.Formatted
-			return string(returned), nil
+			return nil
 		}
 		// there was an issue with order, like:
 		// - invalid price
@@ -171,31 +669,40 @@ func (h *Handler) sendOrder(ctx context.Context, terminal *terminals.Terminal, o
 		// case 1: invalid price
 		invalidOrder, ok := er.(*orders.ErrInvalidOrder)
 		if ok {
-			return "", &httpError{invalidOrder.Error(), http.StatusBadRequest}
+			return &httpError{invalidOrder.Error(), http.StatusBadRequest}
+		}
+
+		// case 2: invalid order type, quantity or an empty basket
+		if errors.Is(er, orders.ErrInvalidOrderType) ||
+			errors.Is(er, orders.ErrInvalidQuantity) ||
+			errors.Is(er, orders.ErrEmptyOrder) {
+			return &httpError{er.Error(), http.StatusBadRequest}
 		}
 
-		// case 2: invalid order type
-		if errors.Is(er, orders.ErrInvalidOrderType) {
-			return "", &httpError{er.Error(), http.StatusBadRequest}
+		// case 3: the ingredients ran out while the order was waiting in the terminal
+		if errors.Is(er, inventory.ErrOutOfStock) {
+			return &httpError{er.Error(), http.StatusConflict}
 		}
 
-		// case 3: not enough cash in the cash register
-		return "", &httpError{er.Error(), http.StatusInternalServerError}
+		// case 4: not enough cash in the cash register
+		return &httpError{er.Error(), http.StatusInternalServerError}
 
 	}
 
 	// order has been cancelled by customer, return
 	if errors.Is(err, orders.ErrOrderCancelled) {
-		return "", &httpError{err.Error(), http.StatusBadRequest}
+		return &httpError{err.Error(), http.StatusBadRequest}
 	}
 
 	// this error indicates that worker is so busy
-	// and can't complete order in the given orderTimeout as defined in above
+	// and can't complete order in the given orderTimeout as defined in above.
+	// The order is cancelled, unless it is already being paid.
 	if errors.Is(err, orders.ErrOrderTimeout) {
-		return "", &httpError{err.Error(), http.StatusUnprocessableEntity}
+		order.Cancel()
+		return &httpError{err.Error(), http.StatusUnprocessableEntity}
 	}
 
-	return "", &httpError{err.Error(), http.StatusInternalServerError}
+	return &httpError{err.Error(), http.StatusInternalServerError}
 }
 
 // httpError is a custom error type that contains a message and a status code
@@ -212,6 +719,10 @@ func (e *httpError) Error() string {
 // writeJSONError writes an error message and status code to the response as JSON
 func (h *Handler) writeJSONError(w http.ResponseWriter, err *httpError) {
 	w.Header().Set("Content-Type", "application/json")
+	// the service is only unavailable for a moment, i.e. while the terminal is full
+	if err.StatusCode == http.StatusServiceUnavailable && w.Header().Get("Retry-After") == "" {
+		w.Header().Set("Retry-After", retryAfter)
+	}
 	w.WriteHeader(err.StatusCode)
 	json.NewEncoder(w).Encode(map[string]string{"error": err.Message})
 }
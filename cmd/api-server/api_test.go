@@ -0,0 +1,50 @@
+package api_server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/azhovan/currywurst/internal/terminals"
+)
+
+// newTestMux returns the routes of a handler of the given terminals, the terminals have no workers
+// so the orders stay in their queue until the test takes them
+func newTestMux(t *testing.T, capacity int, ids []string, opts ...HandlerOption) (*http.ServeMux, *terminals.Registry) {
+	t.Helper()
+
+	registry := newTestRegistry(t, capacity, ids...)
+	mux := http.NewServeMux()
+	NewHandler(registry, opts...).RegisterRoutes(mux)
+	return mux, registry
+}
+
+// newTestRegistry returns a registry of the given terminals, without workers
+func newTestRegistry(t *testing.T, capacity int, ids ...string) *terminals.Registry {
+	t.Helper()
+
+	registry, err := terminals.NewRegistry(capacity, nil)
+	if err != nil {
+		t.Fatalf("terminals.NewRegistry() got error:%v, want nil", err)
+	}
+	for _, id := range ids {
+		if _, err = registry.Add(id); err != nil {
+			t.Fatalf("registry.Add() got error:%v, want nil", err)
+		}
+	}
+	return registry
+}
+
+// serve sends a request with the given pin and body to the routes, and returns the recorded response
+func serve(mux *http.ServeMux, method, path, pin, body string, header ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("X-Pin", pin)
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	return w
+}
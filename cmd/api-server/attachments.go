@@ -0,0 +1,101 @@
+package api_server
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/azhovan/currywurst/internal/orders"
+	"github.com/azhovan/currywurst/internal/terminals"
+)
+
+// errTerminalInUse is the error returned when a terminal is renamed while something is attached to it
+var errTerminalInUse = errors.New("terminal is in use, orders, kiosk sessions or event streams are attached to it")
+
+// attachments counts what is attached to the terminals by their id: the orders that are not settled yet,
+// the kiosk sessions and the event streams of the staff screens. They all refer to the terminal by its id,
+// so a terminal is only renamed while nothing is attached to it. It is safe for concurrent use.
+//
+// The lock of the attachments is never held while the registry is called, so it can be taken by the observers
+// of the orders, which run with the lock of the order and maybe of its terminal held.
+type attachments struct {
+	terminals *terminals.Registry
+
+	mu       sync.Mutex
+	count    map[string]int
+	renaming map[string]bool
+}
+
+// newAttachments returns the attachments of the terminals of the registry, nothing is attached yet
+func newAttachments(registry *terminals.Registry) *attachments {
+	return &attachments{
+		terminals: registry,
+		count:     map[string]int{},
+		renaming:  map[string]bool{},
+	}
+}
+
+// attach attaches something to the given terminal by its id, as long as the terminal is registered under the id.
+// It returns the function that detaches it, which can be called more than once, or false if the terminal
+// is being renamed, or it was renamed or removed.
+func (a *attachments) attach(id string, terminal *terminals.Terminal) (func(), bool) {
+	a.mu.Lock()
+	if a.renaming[id] {
+		a.mu.Unlock()
+		return nil, false
+	}
+	a.count[id]++
+	a.mu.Unlock()
+
+	var once sync.Once
+	detach := func() {
+		once.Do(func() {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			if a.count[id]--; a.count[id] <= 0 {
+				delete(a.count, id)
+			}
+		})
+	}
+
+	// the terminal is looked up once it is attached, so a rename either sees the attachment or is seen by it
+	if current, ok := a.terminals.Get(id); !ok || current != terminal {
+		detach()
+		return nil, false
+	}
+	return detach, true
+}
+
+// rename renames the terminal with the given id in the registry, it returns errTerminalInUse
+// if anything is attached to the terminal, or the error of the registry
+func (a *attachments) rename(id, newId string) error {
+	a.mu.Lock()
+	if a.count[id] > 0 || a.renaming[id] {
+		a.mu.Unlock()
+		return errTerminalInUse
+	}
+	a.renaming[id] = true
+	a.mu.Unlock()
+
+	defer func() {
+		a.mu.Lock()
+		delete(a.renaming, id)
+		a.mu.Unlock()
+	}()
+	return a.terminals.Rename(id, newId)
+}
+
+// attachOrder attaches the order to its terminal until it is ready, failed or cancelled.
+// It returns false if the terminal is no longer registered under the id of the order.
+func (h *Handler) attachOrder(order *orders.Order, terminal *terminals.Terminal) bool {
+	detach, ok := h.attached.attach(order.TerminalID, terminal)
+	if !ok {
+		return false
+	}
+
+	order.Observe(func(s orders.Snapshot) {
+		if s.State == orders.StateReady || s.State.IsFinal() {
+			detach()
+		}
+	})
+	return true
+}
@@ -0,0 +1,202 @@
+package api_server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/azhovan/currywurst/internal/events"
+	"github.com/azhovan/currywurst/internal/orders"
+	"github.com/azhovan/currywurst/internal/store"
+	"github.com/azhovan/currywurst/internal/terminals"
+)
+
+// orderEventsHandler handles the /orders/{orderId}/events endpoint.
+// It streams the transitions of the order as server-sent events, until the order is ready or reaches a final state.
+// Without a Last-Event-ID header the stream starts with the transitions the order went through so far.
+func (h *Handler) orderEventsHandler(w http.ResponseWriter, r *http.Request, orderId string) {
+	// check the method and the pin
+	if err := h.validateRequest(r, http.MethodGet); err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+
+	lastEventId, err := parseLastEventId(r)
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+
+	// subscribe before the order is looked up, so no transition is missed in between
+	sub := h.events.SubscribeSince(events.ForOrder(orderId), lastEventId)
+	defer sub.Close()
+
+	snapshot, er := h.orders.Get(orderId)
+	if errors.Is(er, store.ErrOrderNotFound) {
+		h.writeJSONError(w, &httpError{er.Error(), http.StatusNotFound})
+		return
+	}
+	if er != nil {
+		h.writeJSONError(w, &httpError{er.Error(), http.StatusInternalServerError})
+		return
+	}
+
+	// the events of an old order may no longer be kept, and a client that resumes the stream of
+	// a finished order has nothing left to receive, the stream starts with the current state of the order then
+	var current *events.Event
+	if len(sub.Replay) == 0 && (lastEventId == 0 || lastOrderEvent(snapshot)) {
+		current = &events.Event{Snapshot: snapshot}
+	}
+
+	h.streamEvents(w, r, sub, current, lastOrderEvent)
+}
+
+// lastOrderEvent reports whether the stream of an order ends with the given snapshot,
+// that is the order is ready or reached a final state
+func lastOrderEvent(s orders.Snapshot) bool {
+	return s.State == orders.StateReady || s.State.IsFinal()
+}
+
+// terminalEventsHandler handles the /terminals/{terminalId}/events endpoint.
+// It streams the transitions of all the orders sent to the terminal as server-sent events, for the staff screens.
+func (h *Handler) terminalEventsHandler(w http.ResponseWriter, r *http.Request, terminalId string, terminal *terminals.Terminal) {
+	// check the method and the pin
+	if err := h.validateAdminRequest(r, http.MethodGet); err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+
+	lastEventId, err := parseLastEventId(r)
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+
+	// the stream filters the events by the id of the terminal, the terminal is not renamed while it is open
+	detach, ok := h.attached.attach(terminalId, terminal)
+	if !ok {
+		h.writeJSONError(w, &httpError{"not found", http.StatusNotFound})
+		return
+	}
+	defer detach()
+
+	// a staff screen that connects for the first time is only interested in what happens from now on
+	var sub *events.Subscription
+	if lastEventId == 0 {
+		sub = h.events.Subscribe(events.ForTerminal(terminalId))
+	} else {
+		sub = h.events.SubscribeSince(events.ForTerminal(terminalId), lastEventId)
+	}
+	defer sub.Close()
+
+	h.streamEvents(w, r, sub, nil, func(orders.Snapshot) bool {
+		return false
+	})
+}
+
+// parseLastEventId returns the id of the last event the client received, or 0 if the client did not receive any
+func parseLastEventId(r *http.Request) (uint64, *httpError) {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		return 0, nil
+	}
+
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, &httpError{"invalid Last-Event-ID", http.StatusBadRequest}
+	}
+	return id, nil
+}
+
+// streamEvents writes the current event, if any, the replayed events and then the events of the subscription
+// to the client, until the last event is written or the client disconnects.
+// A heartbeat comment is written whenever the stream is idle for the heartbeat interval.
+func (h *Handler) streamEvents(w http.ResponseWriter, r *http.Request, sub *events.Subscription, current *events.Event, last func(orders.Snapshot) bool) {
+	rc := http.NewResponseController(w)
+	// the stream outlives the write timeout of the server
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		h.writeJSONError(w, &httpError{err.Error(), http.StatusInternalServerError})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	pending := sub.Replay
+	if current != nil {
+		pending = append([]events.Event{*current}, pending...)
+	}
+	for _, event := range pending {
+		if err := writeEvent(w, event); err != nil {
+			return
+		}
+		if last(event.Snapshot) {
+			rc.Flush()
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		// the client disconnected
+		case <-r.Context().Done():
+			return
+
+		case event, ok := <-sub.Events():
+			// the client fell too far behind, or the broker is closed.
+			// It reconnects and resumes from the last event it received.
+			if !ok {
+				return
+			}
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+			if last(event.Snapshot) {
+				return
+			}
+			heartbeat.Reset(h.heartbeat)
+
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeEvent writes a single server-sent event, named after the state of the order, with the status of the order as data.
+// Events without an id, like the current state of an order, do not change the last event id of the client.
+func writeEvent(w http.ResponseWriter, event events.Event) error {
+	data, err := json.Marshal(newOrderStatusResponse(event.Snapshot))
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	if event.ID != 0 {
+		fmt.Fprintf(&b, "id: %d\n", event.ID)
+	}
+	fmt.Fprintf(&b, "event: %s\n", event.Snapshot.State)
+	fmt.Fprintf(&b, "data: %s\n\n", data)
+
+	_, err = w.Write([]byte(b.String()))
+	return err
+}
@@ -0,0 +1,137 @@
+package api_server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/azhovan/currywurst/internal/liveness"
+	"github.com/azhovan/currywurst/internal/terminals"
+)
+
+// HeartbeatRequest is a struct type that represents the heartbeat of a kiosk, with the status of its hardware.
+type HeartbeatRequest struct {
+	// PrinterPaper is the paper left in the receipt printer: ok, low or empty.
+	PrinterPaper string `json:"printerPaper,omitempty"`
+	// CoinTubes are the coins left in the tubes of the coin changer by the value of the coin in cents: ok, low or empty.
+	CoinTubes map[int]string `json:"coinTubes,omitempty"`
+	// DoorOpen tells whether the service door of the kiosk is open.
+	DoorOpen bool `json:"doorOpen"`
+}
+
+// HeartbeatResponse is a struct type that represents the response to the heartbeat of a kiosk.
+type HeartbeatResponse struct {
+	// TerminalId is the id of the terminal the kiosk is attached to.
+	TerminalId string `json:"terminalId"`
+	// State is the state of the terminal, the kiosk tells the customers when it does not take orders.
+	State string `json:"state"`
+	// IntervalSeconds is the time until the next heartbeat of the kiosk is expected, in seconds.
+	IntervalSeconds int `json:"intervalSeconds"`
+}
+
+// KioskHealth is a struct type that represents the liveness of the kiosk attached to a terminal.
+type KioskHealth struct {
+	// LastSeen is the time of the last heartbeat of the kiosk.
+	LastSeen time.Time `json:"lastSeen"`
+	// Degraded tells whether the kiosk missed too many heartbeats.
+	Degraded bool `json:"degraded"`
+	// PrinterPaper is the paper left in the receipt printer, as of the last heartbeat.
+	PrinterPaper string `json:"printerPaper,omitempty"`
+	// CoinTubes are the coins left in the tubes of the coin changer, as of the last heartbeat.
+	CoinTubes map[int]string `json:"coinTubes,omitempty"`
+	// DoorOpen tells whether the service door of the kiosk was open, as of the last heartbeat.
+	DoorOpen bool `json:"doorOpen"`
+}
+
+// heartbeatHandler handles POST /terminals/{terminalId}/heartbeat, the kiosk attached to the terminal
+// calls it periodically with the status of its hardware.
+func (h *Handler) heartbeatHandler(w http.ResponseWriter, r *http.Request, terminalId string, terminal *terminals.Terminal) {
+	// the heartbeats are only accepted when they are monitored
+	if h.kiosks == nil {
+		h.writeJSONError(w, &httpError{"not found", http.StatusNotFound})
+		return
+	}
+
+	// check the method and the pin
+	if err := h.validateRequest(r, http.MethodPost); err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+
+	request := HeartbeatRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.writeJSONError(w, &httpError{"Bad request", http.StatusBadRequest})
+		return
+	}
+
+	hardware := liveness.Hardware{
+		PrinterPaper: liveness.Level(request.PrinterPaper),
+		DoorOpen:     request.DoorOpen,
+	}
+	if len(request.CoinTubes) > 0 {
+		hardware.CoinTubes = make(map[int]liveness.Level, len(request.CoinTubes))
+		for coin, level := range request.CoinTubes {
+			hardware.CoinTubes[coin] = liveness.Level(level)
+		}
+	}
+
+	err := h.kiosks.Beat(terminalId, hardware)
+	if errors.Is(err, liveness.ErrInvalidHardware) {
+		h.writeJSONError(w, &httpError{err.Error(), http.StatusBadRequest})
+		return
+	}
+	if err != nil {
+		h.writeJSONError(w, &httpError{err.Error(), http.StatusInternalServerError})
+		return
+	}
+
+	// the state is read after the heartbeat, it may have resumed the terminal
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(HeartbeatResponse{
+		TerminalId:      terminalId,
+		State:           terminal.Status().State.String(),
+		IntervalSeconds: seconds(h.kiosks.Interval()),
+	})
+}
+
+// kioskHealth returns the liveness of the kiosk attached to the terminal with the given id,
+// or nil if the heartbeats are not monitored or the kiosk has not sent one yet
+func (h *Handler) kioskHealth(terminalId string) *KioskHealth {
+	if h.kiosks == nil {
+		return nil
+	}
+	health, ok := h.kiosks.Health(terminalId)
+	if !ok {
+		return nil
+	}
+
+	response := &KioskHealth{
+		LastSeen:     health.LastSeen,
+		Degraded:     health.Degraded,
+		PrinterPaper: string(health.Hardware.PrinterPaper),
+		DoorOpen:     health.Hardware.DoorOpen,
+	}
+	if len(health.Hardware.CoinTubes) > 0 {
+		response.CoinTubes = make(map[int]string, len(health.Hardware.CoinTubes))
+		for coin, level := range health.Hardware.CoinTubes {
+			response.CoinTubes[coin] = string(level)
+		}
+	}
+	return response
+}
+
+// forgetKiosk stops tracking the kiosk attached to the terminal with the given id
+func (h *Handler) forgetKiosk(terminalId string) {
+	if h.kiosks != nil {
+		h.kiosks.Forget(terminalId)
+	}
+}
+
+// renameKiosk tracks the kiosk attached to the terminal with the given id under the new id of the terminal
+func (h *Handler) renameKiosk(terminalId, newId string) {
+	if h.kiosks != nil {
+		h.kiosks.Rename(terminalId, newId)
+	}
+}
@@ -0,0 +1,151 @@
+package api_server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/azhovan/currywurst/internal/orders"
+	"github.com/azhovan/currywurst/internal/store"
+	"github.com/azhovan/currywurst/pkg"
+)
+
+// OrderListResponse is a struct type that represents a page of the order history.
+type OrderListResponse struct {
+	// Orders lists the orders of the page, with the same shape as the status of an order.
+	Orders []OrderStatusResponse `json:"orders"`
+	// NextCursor is the cursor of the next page, it is empty on the last page.
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// listOrdersHandler handles GET /orders, it returns the orders of the order store that match the filters
+// of the query, one page at a time. It is meant for the managers, so it requires the admin pin.
+//
+// The filters are from and to (RFC 3339 times, the orders created in [from, to)), terminalId, status
+// (a comma separated list of states), product, payment, minTotal and maxTotal (in cents). The orders are
+// sorted by sort, one of createdAt, -createdAt, total and -total. A page holds limit orders, and the
+// nextCursor of the response is passed as the cursor of the query to get the next page.
+func (h *Handler) listOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	// check the method and the pin
+	if err := h.validateAdminRequest(r, http.MethodGet); err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+
+	query, err := parseOrderQuery(r.URL.Query())
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+
+	page, findErr := store.Find(h.orders, query)
+	if errors.Is(findErr, store.ErrInvalidCursor) {
+		h.writeJSONError(w, &httpError{findErr.Error(), http.StatusBadRequest})
+		return
+	}
+	if findErr != nil {
+		h.writeJSONError(w, &httpError{findErr.Error(), http.StatusInternalServerError})
+		return
+	}
+
+	response := OrderListResponse{Orders: make([]OrderStatusResponse, 0, len(page.Orders)), NextCursor: page.Next}
+	for _, snapshot := range page.Orders {
+		response.Orders = append(response.Orders, newOrderStatusResponse(snapshot))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// parseOrderQuery builds the query of the order store out of the query parameters
+func parseOrderQuery(values url.Values) (store.Query, *httpError) {
+	query := store.Query{
+		TerminalID: values.Get("terminalId"),
+		Sort:       store.Sort(values.Get("sort")),
+		Cursor:     values.Get("cursor"),
+	}
+
+	var err *httpError
+	if query.From, err = parseTimeParam(values, "from"); err != nil {
+		return store.Query{}, err
+	}
+	if query.To, err = parseTimeParam(values, "to"); err != nil {
+		return store.Query{}, err
+	}
+	if query.MinTotal, err = parseIntParam(values, "minTotal"); err != nil {
+		return store.Query{}, err
+	}
+	// a maximum of zero selects the orders that cost nothing, so a missing parameter is told apart
+	if values.Has("maxTotal") {
+		maxTotal, err := parseIntParam(values, "maxTotal")
+		if err != nil {
+			return store.Query{}, err
+		}
+		query.MaxTotal = &maxTotal
+	}
+	if query.Limit, err = parseIntParam(values, "limit"); err != nil {
+		return store.Query{}, err
+	}
+
+	if status := values.Get("status"); status != "" {
+		for _, name := range strings.Split(status, ",") {
+			state := orders.State(strings.TrimSpace(name))
+			if !state.IsValid() {
+				return store.Query{}, &httpError{"invalid status parameter " + name, http.StatusBadRequest}
+			}
+			query.States = append(query.States, state)
+		}
+	}
+
+	if product := values.Get("product"); product != "" {
+		if pkg.GetOrderType(product) == nil {
+			return store.Query{}, &httpError{"invalid product parameter", http.StatusBadRequest}
+		}
+		query.Product = orders.OrderType(product)
+	}
+
+	if payment := orders.PaymentMethod(values.Get("payment")); payment != "" {
+		if !payment.IsValid() {
+			return store.Query{}, &httpError{"invalid payment parameter, the terminals only accept cash", http.StatusBadRequest}
+		}
+		query.Payment = payment
+	}
+
+	if query.Sort != "" && !query.Sort.IsValid() {
+		return store.Query{}, &httpError{"invalid sort parameter", http.StatusBadRequest}
+	}
+	if query.Limit < 0 || query.Limit > store.MaxPageSize {
+		return store.Query{}, &httpError{"invalid limit parameter, it is at most " + strconv.Itoa(store.MaxPageSize), http.StatusBadRequest}
+	}
+	return query, nil
+}
+
+// parseTimeParam parses the RFC 3339 time of the given query parameter, a missing parameter is the zero time
+func parseTimeParam(values url.Values, name string) (time.Time, *httpError) {
+	value := values.Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, &httpError{"invalid " + name + " parameter, it is an RFC 3339 time", http.StatusBadRequest}
+	}
+	return t, nil
+}
+
+// parseIntParam parses the non-negative number of the given query parameter, a missing parameter is zero
+func parseIntParam(values url.Values, name string) (int, *httpError) {
+	value := values.Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, &httpError{"invalid " + name + " parameter", http.StatusBadRequest}
+	}
+	return n, nil
+}
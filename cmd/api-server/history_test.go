@@ -0,0 +1,42 @@
+package api_server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestOrders_History(t *testing.T) {
+	mux, _ := newTestMux(t, 10, []string{"terminal-0"})
+	serve(mux, http.MethodPost, "/orders", "1234", `{"terminalId":"terminal-0","orderType":"vegan","insertedPrice":30}`)
+
+	tests := []struct {
+		query      string
+		statusCode int
+		orders     int
+	}{
+		{"", http.StatusOK, 1},
+		{"?payment=cash", http.StatusOK, 1},
+		{"?payment=card", http.StatusBadRequest, 0},
+		// a maximum of zero only selects the orders that cost nothing
+		{"?maxTotal=0", http.StatusOK, 0},
+		{"?maxTotal=30", http.StatusOK, 1},
+	}
+	for _, tt := range tests {
+		w := serve(mux, http.MethodGet, "/orders"+tt.query, "4321", "")
+		if w.Code != tt.statusCode {
+			t.Errorf("GET /orders%s got status:%d, want:%d", tt.query, w.Code, tt.statusCode)
+			continue
+		}
+		var response OrderListResponse
+		json.NewDecoder(w.Body).Decode(&response)
+		if len(response.Orders) != tt.orders {
+			t.Errorf("GET /orders%s got %d orders, want:%d", tt.query, len(response.Orders), tt.orders)
+		}
+		for _, order := range response.Orders {
+			if order.PaymentMethod != "cash" {
+				t.Errorf("GET /orders%s got payment method:%s, want:cash", tt.query, order.PaymentMethod)
+			}
+		}
+	}
+}
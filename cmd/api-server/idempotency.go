@@ -0,0 +1,167 @@
+package api_server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/azhovan/currywurst/internal/idempotency"
+)
+
+// Define the limits of the idempotent requests
+const (
+	maxIdempotencyKeyLength = 255
+	maxOrderRequestSize     = 1 << 20
+)
+
+// idempotent makes the order submission safe to retry. A request with an Idempotency-Key header is handled once,
+// a repeated request with the same key and body gets the response of the first one, or the order of the first one
+// while it is in progress. The same key with a different body is rejected with 422 Unprocessable Entity.
+// The keys are scoped to the pin of the customer, and requests without a key, or that are not submissions,
+// are handled as usual.
+func (h *Handler) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" || r.Method != http.MethodPost {
+			next(w, r)
+			return
+		}
+
+		// only the requests of valid customers are remembered
+		if err := h.validateRequest(r, http.MethodPost); err != nil {
+			h.writeJSONError(w, err)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			h.writeJSONError(w, &httpError{"Idempotency-Key is too long", http.StatusBadRequest})
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxOrderRequestSize))
+		if err != nil {
+			h.writeJSONError(w, &httpError{"Bad request", http.StatusBadRequest})
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		scopedKey := r.Header.Get("X-Pin") + ":" + key
+		entry, first, err := h.idempotency.Begin(scopedKey, fingerprint(r, body))
+		if errors.Is(err, idempotency.ErrKeyReused) {
+			h.writeJSONError(w, &httpError{err.Error(), http.StatusUnprocessableEntity})
+			return
+		}
+		if err != nil {
+			h.writeJSONError(w, &httpError{err.Error(), http.StatusInternalServerError})
+			return
+		}
+
+		if !first {
+			h.writeRepeatedResponse(w, entry)
+			return
+		}
+
+		// a request that failed on the server side, or whose handler panicked, can be retried with the same key
+		completed := false
+		defer func() {
+			if !completed {
+				h.idempotency.Release(scopedKey, entry)
+			}
+		}()
+
+		rec := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		next(rec, r.WithContext(idempotency.NewContext(r.Context(), entry)))
+
+		if rec.statusCode >= http.StatusInternalServerError {
+			return
+		}
+		h.idempotency.Complete(entry, idempotency.Response{
+			StatusCode: rec.statusCode,
+			Header:     rec.Header().Clone(),
+			Body:       rec.body.Bytes(),
+		})
+		completed = true
+	}
+}
+
+// writeRepeatedResponse answers a repeated request with the response of the first request, or with the order
+// of the first request while it is in progress
+func (h *Handler) writeRepeatedResponse(w http.ResponseWriter, entry *idempotency.Entry) {
+	if response, ok := entry.Response(); ok {
+		for name, values := range response.Header {
+			w.Header()[name] = values
+		}
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(response.StatusCode)
+		w.Write(response.Body)
+		return
+	}
+
+	orderId := entry.OrderID()
+	if orderId == "" {
+		w.Header().Set("Retry-After", "1")
+		h.writeJSONError(w, &httpError{"a request with the same Idempotency-Key is in progress", http.StatusConflict})
+		return
+	}
+
+	accepted := OrderAcceptedResponse{OrderId: orderId}
+	if snapshot, err := h.orders.Get(orderId); err == nil {
+		accepted.TerminalId = snapshot.TerminalID
+		accepted.State = snapshot.State.String()
+		accepted.Queue = h.queueEstimate(orderId, snapshot.TerminalID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/orders/"+orderId)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(accepted)
+}
+
+// fingerprint identifies the request by its path, query and body. A JSON body is compacted,
+// so the same order sent with a different formatting is still the same request.
+func fingerprint(r *http.Request, body []byte) string {
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, body); err == nil {
+		body = compact.Bytes()
+	}
+
+	sum := sha256.New()
+	sum.Write([]byte(r.URL.Path + "?" + r.URL.RawQuery + "\n"))
+	sum.Write(body)
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// recordOrder records the order placed by an idempotent request, so a repeated request is pointed to it
+func recordOrder(ctx context.Context, orderId string) {
+	if entry, ok := idempotency.FromContext(ctx); ok {
+		entry.SetOrderID(orderId)
+	}
+}
+
+// responseRecorder writes the response to the client and keeps a copy of it
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+// WriteHeader writes the status code to the client and keeps it
+func (r *responseRecorder) WriteHeader(statusCode int) {
+	r.statusCode = statusCode
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write writes the data to the client and keeps a copy of it
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+// Unwrap returns the original response writer, so http.ResponseController can reach it
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,85 @@
+package api_server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/azhovan/currywurst/internal/orders"
+	"github.com/azhovan/currywurst/internal/store"
+)
+
+// failingStore is an order store that fails to save the orders while fail is set
+type failingStore struct {
+	store.OrderStore
+	fail atomic.Bool
+}
+
+func (s *failingStore) Save(snapshot orders.Snapshot) error {
+	if s.fail.Load() {
+		return errors.New("disk full")
+	}
+	return s.OrderStore.Save(snapshot)
+}
+
+func TestIdempotent_Replay(t *testing.T) {
+	mux, _ := newTestMux(t, 10, []string{"terminal-0"})
+	body := `{"terminalId":"terminal-0","orderType":"vegan","insertedPrice":30}`
+
+	first := serve(mux, http.MethodPost, "/orders", "1234", body, "Idempotency-Key", "key-1")
+	if first.Code != http.StatusAccepted {
+		t.Fatalf("POST /orders got status:%d, want:%d", first.Code, http.StatusAccepted)
+	}
+	var accepted OrderAcceptedResponse
+	json.NewDecoder(first.Body).Decode(&accepted)
+
+	// the same body with another formatting is the same request
+	repeated := serve(mux, http.MethodPost, "/orders", "1234", "  "+body, "Idempotency-Key", "key-1")
+	if repeated.Code != http.StatusAccepted || repeated.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("repeated POST /orders got status:%d replayed:%q, want:%d true",
+			repeated.Code, repeated.Header().Get("Idempotent-Replayed"), http.StatusAccepted)
+	}
+	var replayed OrderAcceptedResponse
+	json.NewDecoder(repeated.Body).Decode(&replayed)
+	if replayed.OrderId != accepted.OrderId {
+		t.Errorf("repeated POST /orders got order:%s, want:%s", replayed.OrderId, accepted.OrderId)
+	}
+
+	// the key is scoped to the pin of the customer
+	other := serve(mux, http.MethodPost, "/orders", "5678", body, "Idempotency-Key", "key-1")
+	if other.Code != http.StatusAccepted || other.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("POST /orders of another pin got status:%d replayed:%q, want:%d and a new order",
+			other.Code, other.Header().Get("Idempotent-Replayed"), http.StatusAccepted)
+	}
+}
+
+func TestIdempotent_KeyReused(t *testing.T) {
+	mux, _ := newTestMux(t, 10, []string{"terminal-0"})
+
+	serve(mux, http.MethodPost, "/orders", "1234", `{"terminalId":"terminal-0","orderType":"vegan","insertedPrice":30}`, "Idempotency-Key", "key-1")
+	w := serve(mux, http.MethodPost, "/orders", "1234", `{"terminalId":"terminal-0","orderType":"vegan","insertedPrice":50}`, "Idempotency-Key", "key-1")
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("POST /orders with a reused key got status:%d, want:%d", w.Code, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestIdempotent_ReleaseOnServerError(t *testing.T) {
+	orderStore := &failingStore{OrderStore: store.NewMemory()}
+	orderStore.fail.Store(true)
+	mux, _ := newTestMux(t, 10, []string{"terminal-0"}, WithOrderStore(orderStore))
+	body := `{"terminalId":"terminal-0","orderType":"vegan","insertedPrice":30}`
+
+	if w := serve(mux, http.MethodPost, "/orders", "1234", body, "Idempotency-Key", "key-1"); w.Code != http.StatusInternalServerError {
+		t.Fatalf("POST /orders got status:%d, want:%d", w.Code, http.StatusInternalServerError)
+	}
+
+	// the failed request is forgotten, so the retry is handled again instead of replaying the error
+	orderStore.fail.Store(false)
+	w := serve(mux, http.MethodPost, "/orders", "1234", body, "Idempotency-Key", "key-1")
+	if w.Code != http.StatusAccepted || w.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("retried POST /orders got status:%d replayed:%q, want:%d and a new order",
+			w.Code, w.Header().Get("Idempotent-Replayed"), http.StatusAccepted)
+	}
+}
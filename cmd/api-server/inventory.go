@@ -0,0 +1,111 @@
+package api_server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/azhovan/currywurst/internal/inventory"
+	"github.com/azhovan/currywurst/internal/orders"
+	"github.com/azhovan/currywurst/pkg"
+)
+
+// CatalogItem is a struct type that represents a product of the catalog.
+type CatalogItem struct {
+	// OrderType is the type of the product, such as `vegan` or `non-vegan`.
+	OrderType string `json:"orderType"`
+	// Price is the regular price of the product in cents.
+	Price int `json:"price"`
+	// TaxCategory is the VAT category of the product, such as `food` or `beverage`.
+	TaxCategory string `json:"taxCategory"`
+	// Available tells whether the product can be ordered, it is false once the product is sold out.
+	Available bool `json:"available"`
+}
+
+// InventoryRequest is a struct type that represents a restock request from the staff.
+type InventoryRequest struct {
+	// Ingredients specifies the amount of every ingredient that is added to the stock.
+	Ingredients map[inventory.Ingredient]int `json:"ingredients"`
+}
+
+// InventoryResponse is a struct type that represents the stock of the ingredients.
+type InventoryResponse struct {
+	// Ingredients is the current amount of every ingredient in the stock.
+	Ingredients map[inventory.Ingredient]int `json:"ingredients"`
+	// Catalog lists the products and whether they can be made with the current stock.
+	Catalog []CatalogItem `json:"catalog"`
+}
+
+// catalogHandler handles the /catalog endpoint
+func (h *Handler) catalogHandler(w http.ResponseWriter, r *http.Request) {
+	// check the method and the pin
+	if err := h.validateRequest(r, http.MethodGet); err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(h.catalog())
+}
+
+// inventoryHandler handles the /admin/inventory endpoint.
+// A GET request returns the stock, a POST request restocks the ingredients and returns the new stock.
+func (h *Handler) inventoryHandler(w http.ResponseWriter, r *http.Request) {
+	// check the method and the admin pin
+	if err := h.validateAdminRequest(r, http.MethodGet, http.MethodPost); err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+
+	if h.inventory == nil {
+		h.writeJSONError(w, &httpError{"inventory is not tracked", http.StatusNotFound})
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		request := InventoryRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			h.writeJSONError(w, &httpError{"Bad request", http.StatusBadRequest})
+			return
+		}
+
+		err := h.inventory.Restock(request.Ingredients)
+		if errors.Is(err, inventory.ErrInvalidAmount) {
+			h.writeJSONError(w, &httpError{err.Error(), http.StatusBadRequest})
+			return
+		}
+		if err != nil {
+			h.writeJSONError(w, &httpError{err.Error(), http.StatusInternalServerError})
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(InventoryResponse{
+		Ingredients: h.inventory.Stock(),
+		Catalog:     h.catalog(),
+	})
+}
+
+// catalog returns the products and whether they are available with the current stock
+func (h *Handler) catalog() []CatalogItem {
+	orderTypes := pkg.OrderTypes()
+	catalog := make([]CatalogItem, 0, len(orderTypes))
+	for _, orderType := range orderTypes {
+		available := true
+		if h.inventory != nil {
+			available = h.inventory.Available(orders.OrderType(orderType.Name()))
+		}
+
+		catalog = append(catalog, CatalogItem{
+			OrderType:   orderType.Name(),
+			Price:       orderType.Price(),
+			TaxCategory: string(orderType.TaxCategory()),
+			Available:   available,
+		})
+	}
+
+	return catalog
+}
@@ -0,0 +1,300 @@
+package api_server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/azhovan/currywurst/internal/events"
+	"github.com/azhovan/currywurst/internal/orders"
+	"github.com/azhovan/currywurst/internal/terminals"
+	"github.com/azhovan/currywurst/internal/websocket"
+)
+
+// Define the types of the messages of the kiosk protocol
+const (
+	// sent by the kiosk
+	kioskAuth   = "auth"   // authenticates the kiosk with a pin, it must be the first message
+	kioskOrder  = "order"  // submits an order to the terminal of the connection
+	kioskCancel = "cancel" // cancels an order that was submitted over the connection
+
+	// sent by the server
+	kioskAuthenticated = "authenticated" // the kiosk is authenticated
+	kioskAccepted      = "accepted"      // the order is queued
+	kioskStatus        = "status"        // the order moved to a new state
+	kioskCancelled     = "cancelled"     // the order is cancelled
+	kioskError         = "error"         // the message of the kiosk could not be handled
+)
+
+// KioskMessage is a message of the kiosk protocol, it is sent in both directions as a JSON text message.
+// Only the fields that are relevant to the type of the message are set.
+type KioskMessage struct {
+	// Type is the type of the message, such as `order` or `status`.
+	Type string `json:"type"`
+	// RequestId is an optional id chosen by the kiosk, it is echoed in the reply to the message.
+	RequestId string `json:"requestId,omitempty"`
+	// Pin is the pin of the kiosk, sent in the auth message.
+	Pin string `json:"pin,omitempty"`
+	// TerminalId is the id of the terminal of the connection, sent in the authenticated message.
+	TerminalId string `json:"terminalId,omitempty"`
+	// OrderId is the id of the order the message is about.
+	OrderId string `json:"orderId,omitempty"`
+	// Order is the order submitted by the kiosk, its terminalId is ignored.
+	Order *OrderRequest `json:"order,omitempty"`
+	// EventId is the id of the event of a status message, it matches the id of the server-sent events.
+	EventId uint64 `json:"eventId,omitempty"`
+	// Status is the status of the order, sent in the accepted, status and cancelled messages.
+	Status *OrderStatusResponse `json:"status,omitempty"`
+	// Error is the reason the message of the kiosk could not be handled.
+	Error string `json:"error,omitempty"`
+	// Code is the HTTP status code that matches the error, i.e. 409 for a sold out product.
+	Code int `json:"code,omitempty"`
+}
+
+// kioskSession is the state of a kiosk connection
+type kioskSession struct {
+	conn       *websocket.Conn
+	terminalId string
+	terminal   *terminals.Terminal
+	// pin is the pin the kiosk authenticated with
+	pin string
+
+	// out holds the messages that are waiting to be written by the writer
+	out chan KioskMessage
+	// done is closed once the reader is done with the connection
+	done chan struct{}
+	// stopped is closed once the writer stopped writing to the connection
+	stopped chan struct{}
+
+	// mu guards orders
+	mu sync.Mutex
+	// orders holds the orders submitted over the connection that are still followed
+	orders map[string]*orders.Order
+}
+
+// kioskHandler handles the /terminals/{terminalId}/ws endpoint.
+// It upgrades the connection to a WebSocket, the kiosk of the terminal authenticates once and then submits
+// and cancels its orders over the connection, and receives a status message after every transition of them.
+func (h *Handler) kioskHandler(w http.ResponseWriter, r *http.Request, terminalId string, terminal *terminals.Terminal) {
+	// the session refers to the terminal by id, the terminal is not renamed while the kiosk is connected
+	detach, ok := h.attached.attach(terminalId, terminal)
+	if !ok {
+		h.writeJSONError(w, &httpError{"not found", http.StatusNotFound})
+		return
+	}
+	defer detach()
+
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		return
+	}
+	// the kiosk is pinged every heartbeat interval, a kiosk that does not answer two pings in a row is gone
+	conn.ReadTimeout = 2 * h.heartbeat
+
+	s := &kioskSession{
+		conn:       conn,
+		terminalId: terminalId,
+		terminal:   terminal,
+		out:        make(chan KioskMessage, 16),
+		done:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+		orders:     map[string]*orders.Order{},
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		h.writeKioskMessages(s)
+	}()
+
+	h.readKioskMessages(s)
+
+	close(s.done)
+	conn.Close(websocket.CloseNormal, "")
+	wg.Wait()
+}
+
+// readKioskMessages handles the messages of the kiosk until the connection is closed
+func (h *Handler) readKioskMessages(s *kioskSession) {
+	authenticated := false
+
+	for {
+		messageType, data, err := s.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var msg KioskMessage
+		if messageType != websocket.TextMessage || json.Unmarshal(data, &msg) != nil {
+			s.send(KioskMessage{Type: kioskError, Error: "Bad request", Code: http.StatusBadRequest})
+			continue
+		}
+
+		// the kiosk authenticates once, before anything else
+		if !authenticated {
+			if msg.Type != kioskAuth || !h.pins[msg.Pin] {
+				// nothing else is queued before the kiosk is authenticated, so the error is written right away
+				data, _ := json.Marshal(KioskMessage{Type: kioskError, RequestId: msg.RequestId, Error: "Invalid pin", Code: http.StatusUnauthorized})
+				s.conn.WriteMessage(websocket.TextMessage, data)
+				s.conn.Close(websocket.ClosePolicyViolation, "Invalid pin")
+				return
+			}
+			authenticated = true
+			s.pin = msg.Pin
+			s.send(KioskMessage{Type: kioskAuthenticated, RequestId: msg.RequestId, TerminalId: s.terminalId})
+			continue
+		}
+
+		switch msg.Type {
+		case kioskOrder:
+			h.kioskOrder(s, msg)
+		case kioskCancel:
+			h.kioskCancel(s, msg)
+		default:
+			s.send(KioskMessage{Type: kioskError, RequestId: msg.RequestId, Error: "unknown message type", Code: http.StatusBadRequest})
+		}
+	}
+}
+
+// kioskOrder places the order of the kiosk on the terminal of the connection, the same way as the /orders endpoint
+func (h *Handler) kioskOrder(s *kioskSession, msg KioskMessage) {
+	if msg.Order == nil {
+		s.send(KioskMessage{Type: kioskError, RequestId: msg.RequestId, Error: "order is missing", Code: http.StatusBadRequest})
+		return
+	}
+
+	orderRequest := *msg.Order
+	orderRequest.TerminalId = s.terminalId
+	if err := orderRequest.validate(); err != nil {
+		s.send(KioskMessage{Type: kioskError, RequestId: msg.RequestId, Error: err.Message, Code: err.StatusCode})
+		return
+	}
+	if err := h.authorizePriority(s.pin, &orderRequest); err != nil {
+		s.send(KioskMessage{Type: kioskError, RequestId: msg.RequestId, Error: err.Message, Code: err.StatusCode})
+		return
+	}
+
+	// the order is not tied to the connection, it is processed even if the kiosk goes away
+	order, err := h.placeOrder(context.Background(), s.terminal, &orderRequest)
+	if err != nil {
+		s.send(KioskMessage{Type: kioskError, RequestId: msg.RequestId, Error: err.Message, Code: err.StatusCode})
+		return
+	}
+	go h.settleOrder(order)
+
+	s.mu.Lock()
+	s.orders[order.ID] = order
+	s.mu.Unlock()
+
+	status := newOrderStatusResponse(order.Snapshot())
+	status.Queue = h.queueEstimate(order.ID, order.TerminalID)
+	s.send(KioskMessage{Type: kioskAccepted, RequestId: msg.RequestId, OrderId: order.ID, Status: &status})
+
+	go h.followKioskOrder(s, order.ID)
+}
+
+// kioskCancel cancels an order that was submitted over the connection and is not being paid yet
+func (h *Handler) kioskCancel(s *kioskSession, msg KioskMessage) {
+	s.mu.Lock()
+	order, ok := s.orders[msg.OrderId]
+	s.mu.Unlock()
+	if !ok {
+		s.send(KioskMessage{Type: kioskError, RequestId: msg.RequestId, OrderId: msg.OrderId, Error: "order not found", Code: http.StatusNotFound})
+		return
+	}
+
+	if err := order.Cancel(); err != nil {
+		code := http.StatusInternalServerError
+		if errors.Is(err, orders.ErrInvalidTransition) {
+			code = http.StatusConflict
+		}
+		s.send(KioskMessage{Type: kioskError, RequestId: msg.RequestId, OrderId: msg.OrderId, Error: err.Error(), Code: code})
+		return
+	}
+
+	status := newOrderStatusResponse(order.Snapshot())
+	s.send(KioskMessage{Type: kioskCancelled, RequestId: msg.RequestId, OrderId: order.ID, Status: &status})
+}
+
+// followKioskOrder sends a status message to the kiosk after every transition of the order,
+// until the order is ready or reaches a final state, or the connection is gone.
+func (h *Handler) followKioskOrder(s *kioskSession, orderId string) {
+	// the order may have moved on since it was queued, those transitions are replayed
+	sub := h.events.SubscribeSince(events.ForOrder(orderId), 0)
+	defer sub.Close()
+	defer func() {
+		s.mu.Lock()
+		delete(s.orders, orderId)
+		s.mu.Unlock()
+	}()
+
+	notify := func(event events.Event) bool {
+		status := newOrderStatusResponse(event.Snapshot)
+		s.send(KioskMessage{Type: kioskStatus, OrderId: orderId, EventId: event.ID, Status: &status})
+		return lastOrderEvent(event.Snapshot)
+	}
+
+	for _, event := range sub.Replay {
+		if notify(event) {
+			return
+		}
+	}
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case event, ok := <-sub.Events():
+			if !ok || notify(event) {
+				return
+			}
+		}
+	}
+}
+
+// writeKioskMessages writes the messages to the kiosk, and pings it every heartbeat interval,
+// until the connection is gone. The kiosk is pinged even while messages are written to it, its pongs keep
+// the connection alive when it has nothing to send.
+func (h *Handler) writeKioskMessages(s *kioskSession) {
+	defer close(s.stopped)
+
+	ping := time.NewTicker(h.heartbeat)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+
+		case msg := <-s.out:
+			data, err := json.Marshal(msg)
+			if err != nil {
+				continue
+			}
+			if err := s.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				// the reader notices the broken connection and tears the session down
+				s.conn.Close(websocket.CloseGoingAway, "")
+				return
+			}
+
+		case <-ping.C:
+			if err := s.conn.Ping(nil); err != nil {
+				s.conn.Close(websocket.CloseGoingAway, "")
+				return
+			}
+		}
+	}
+}
+
+// send queues a message for the kiosk, it gives up once the connection is gone
+func (s *kioskSession) send(msg KioskMessage) {
+	select {
+	case s.out <- msg:
+	case <-s.done:
+	case <-s.stopped:
+	}
+}
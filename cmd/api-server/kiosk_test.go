@@ -0,0 +1,197 @@
+package api_server
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/azhovan/currywurst/internal/orders"
+	"github.com/azhovan/currywurst/internal/websocket"
+)
+
+// kioskClient is the client side of a kiosk connection, the frames are written and read by hand
+type kioskClient struct {
+	t    *testing.T
+	conn net.Conn
+	br   *bufio.Reader
+}
+
+// dialKiosk opens the kiosk connection of the terminal with the given id
+func dialKiosk(t *testing.T, server *httptest.Server, terminalId string) *kioskClient {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatalf("net.Dial() got error:%v, want nil", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	request := "GET /terminals/" + terminalId + "/ws HTTP/1.1\r\nHost: test\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n"
+	if _, err = conn.Write([]byte(request)); err != nil {
+		t.Fatalf("conn.Write() got error:%v, want nil", err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil || resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("the handshake got response:%v error:%v, want status:%d", resp, err, http.StatusSwitchingProtocols)
+	}
+	return &kioskClient{t: t, conn: conn, br: br}
+}
+
+// write sends the message as a masked text frame
+func (c *kioskClient) write(msg string) {
+	c.t.Helper()
+
+	frame := []byte{0x80 | byte(websocket.TextMessage), 0x80 | 126}
+	frame = binary.BigEndian.AppendUint16(frame, uint16(len(msg)))
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i := range msg {
+		frame = append(frame, msg[i]^mask[i%4])
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		c.t.Fatalf("conn.Write() got error:%v, want nil", err)
+	}
+}
+
+// readFrame reads the next frame of the server, and returns its opcode and payload
+func (c *kioskClient) readFrame() (byte, []byte) {
+	c.t.Helper()
+
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(c.br, header); err != nil {
+		c.t.Fatalf("reading the frame got error:%v, want nil", err)
+	}
+	length := int(header[1] & 0x7F)
+	if length == 126 {
+		ext := make([]byte, 2)
+		io.ReadFull(c.br, ext)
+		length = int(binary.BigEndian.Uint16(ext))
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		c.t.Fatalf("reading the payload got error:%v, want nil", err)
+	}
+	return header[0] & 0x0F, payload
+}
+
+// expect reads the messages of the server until one of the given type, the other messages are skipped
+func (c *kioskClient) expect(messageType string) KioskMessage {
+	c.t.Helper()
+
+	for {
+		opcode, payload := c.readFrame()
+		if opcode != byte(websocket.TextMessage) {
+			c.t.Fatalf("got frame %d %q, want a %s message", opcode, payload, messageType)
+		}
+		var msg KioskMessage
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			c.t.Fatalf("json.Unmarshal() got error:%v, want nil", err)
+		}
+		if msg.Type == messageType {
+			return msg
+		}
+	}
+}
+
+// expectClose reads the frames of the server until the close frame, and returns its status code
+func (c *kioskClient) expectClose() int {
+	c.t.Helper()
+
+	for {
+		opcode, payload := c.readFrame()
+		if opcode == 0x8 && len(payload) >= 2 {
+			return int(binary.BigEndian.Uint16(payload))
+		}
+	}
+}
+
+func TestKiosk_Protocol(t *testing.T) {
+	mux, registry := newTestMux(t, 10, []string{"terminal-0"})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	kiosk := dialKiosk(t, server, "terminal-0")
+	kiosk.write(`{"type":"auth","requestId":"r0","pin":"1234"}`)
+	if msg := kiosk.expect(kioskAuthenticated); msg.RequestId != "r0" || msg.TerminalId != "terminal-0" {
+		t.Errorf("got message:%+v, want authenticated on terminal-0", msg)
+	}
+
+	// the order is queued on the terminal of the connection, its terminalId is ignored
+	kiosk.write(`{"type":"order","requestId":"r1","order":{"terminalId":"terminal-9","orderType":"vegan","insertedPrice":30}}`)
+	accepted := kiosk.expect(kioskAccepted)
+	if accepted.RequestId != "r1" || accepted.OrderId == "" || accepted.Status == nil || accepted.Status.State != string(orders.StateQueued) {
+		t.Fatalf("got message:%+v, want the order accepted", accepted)
+	}
+	if terminal, _ := registry.Get("terminal-0"); terminal.Len() != 1 {
+		t.Errorf("got %d queued orders, want:1", terminal.Len())
+	}
+	if msg := kiosk.expect(kioskStatus); msg.OrderId != accepted.OrderId || msg.EventId == 0 || msg.Status.State != string(orders.StateQueued) {
+		t.Errorf("got message:%+v, want the status of the queued order", msg)
+	}
+
+	kiosk.write(`{"type":"order","requestId":"r2","order":{"orderType":"vegan","insertedPrice":30,"priority":"vip"}}`)
+	if msg := kiosk.expect(kioskError); msg.RequestId != "r2" || msg.Code != http.StatusBadRequest {
+		t.Errorf("got message:%+v, want an error with code:%d", msg, http.StatusBadRequest)
+	}
+
+	kiosk.write(`{"type":"cancel","requestId":"r3","orderId":"` + accepted.OrderId + `"}`)
+	if msg := kiosk.expect(kioskCancelled); msg.RequestId != "r3" || msg.Status.State != string(orders.StateCancelled) {
+		t.Errorf("got message:%+v, want the order cancelled", msg)
+	}
+
+	kiosk.write(`{"type":"cancel","requestId":"r4","orderId":"unknown"}`)
+	if msg := kiosk.expect(kioskError); msg.RequestId != "r4" || msg.Code != http.StatusNotFound {
+		t.Errorf("got message:%+v, want an error with code:%d", msg, http.StatusNotFound)
+	}
+}
+
+func TestKiosk_Unauthenticated(t *testing.T) {
+	mux, registry := newTestMux(t, 10, []string{"terminal-0"})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	for _, first := range []string{
+		`{"type":"auth","pin":"0000"}`,
+		`{"type":"order","order":{"orderType":"vegan","insertedPrice":30}}`,
+	} {
+		kiosk := dialKiosk(t, server, "terminal-0")
+		kiosk.write(first)
+		if msg := kiosk.expect(kioskError); msg.Code != http.StatusUnauthorized {
+			t.Errorf("got message:%+v, want an error with code:%d", msg, http.StatusUnauthorized)
+		}
+		if code := kiosk.expectClose(); code != websocket.ClosePolicyViolation {
+			t.Errorf("got close status:%d, want:%d", code, websocket.ClosePolicyViolation)
+		}
+	}
+
+	if terminal, _ := registry.Get("terminal-0"); terminal.Len() != 0 {
+		t.Errorf("got %d queued orders, want none", terminal.Len())
+	}
+}
+
+func TestKiosk_ReadTimeout(t *testing.T) {
+	mux, _ := newTestMux(t, 10, []string{"terminal-0"}, WithHeartbeat(20*time.Millisecond))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	// the kiosk is pinged, but it never answers, so the connection is closed
+	kiosk := dialKiosk(t, server, "terminal-0")
+	kiosk.write(`{"type":"auth","pin":"1234"}`)
+	kiosk.expect(kioskAuthenticated)
+	if opcode, _ := kiosk.readFrame(); opcode != 0x9 {
+		t.Errorf("got frame %d, want a ping", opcode)
+	}
+	if code := kiosk.expectClose(); code != websocket.CloseNormal {
+		t.Errorf("got close status:%d, want:%d", code, websocket.CloseNormal)
+	}
+}
@@ -1,47 +1,182 @@
 package main
 
 import (
+	"context"
 	"log"
 	"log/slog"
 	"net/http"
 	"os"
+	"path/filepath"
+	"time"
 
 	. "github.com/azhovan/currywurst/cmd/api-server"
+	"github.com/azhovan/currywurst/internal/clock"
+	"github.com/azhovan/currywurst/internal/idempotency"
+	"github.com/azhovan/currywurst/internal/inventory"
+	"github.com/azhovan/currywurst/internal/journal"
+	"github.com/azhovan/currywurst/internal/liveness"
+	"github.com/azhovan/currywurst/internal/orders"
+	"github.com/azhovan/currywurst/internal/pricing"
+	"github.com/azhovan/currywurst/internal/recovery"
+	"github.com/azhovan/currywurst/internal/routing"
+	"github.com/azhovan/currywurst/internal/schedule"
+	"github.com/azhovan/currywurst/internal/store"
 	"github.com/azhovan/currywurst/internal/utils"
 	"github.com/azhovan/currywurst/internal/workers"
 )
 
 func main() {
-	// terminalCount is the number of terminals that the handler can handle.
-	// It represents the number of terminals that customers can join and place their orders.
-	//
-	// At the moment, this constant has been hardcoded, but in real world scenarios,
-	// it could be injected from configuration files, configmaps, etc.
-	const terminalCount = 3
-	terminals, cashRegister, err := utils.CreateTerminalWorkers(terminalCount)
-	if err != nil {
+	// create a logger that uses the handler and sets the minimum level to error
+	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
+
+	if err := run(logger); err != nil {
 		log.Fatal(err)
 	}
+}
+
+// run sets up the stand and serves it until the server is shut down. The order store is closed on the way out,
+// also when the server did not start, so the pending records of the orders are written and the log is compacted.
+func run(logger *slog.Logger) (err error) {
+	// The settings of the stand. At the moment, these constants have been hardcoded, but in real world scenarios,
+	// they could be injected from configuration files, configmaps, etc.
+	const (
+		// terminalCount is the number of terminals that the handler can handle.
+		// It represents the number of terminals that customers can join and place their orders.
+		terminalCount = 3
+		// dataDir is the directory the orders are persisted in, so their history survives restarts.
+		dataDir = "data"
+		// workStealing lets the idle workers take orders from the busiest terminal.
+		workStealing = true
+		// routingPolicy chooses the terminal of the orders that do not name one.
+		routingPolicy = routing.LeastQueued
+		// heartbeatInterval is the time between two heartbeats of a kiosk, and missedHeartbeats the number
+		// of heartbeats it can miss before its terminal is degraded and paused until the kiosk is back.
+		heartbeatInterval = 10 * time.Second
+		missedHeartbeats  = 3
+	)
+
+	// stock is the initial stock of the ingredients, it is shared by all the workers.
+	// It can be restocked at runtime through the /admin/inventory endpoint.
+	stock := map[inventory.Ingredient]int{
+		inventory.VeganSausage: 50,
+		inventory.Sausage:      50,
+		inventory.Sauce:        100,
+		inventory.FriesPortion: 80,
+		inventory.DrinkBottle:  80,
+	}
+	inv := inventory.NewInventory(inventory.DefaultRecipes(), stock)
+
+	orderStore, err := store.NewFile(dataDir, store.WithLogger(logger))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// the log of the orders is compacted on close, so the next start is quick
+		if closeErr := orderStore.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	// the journal records the payments and the recovered orders, it is kept next to the orders
+	payments, err := journal.Open(filepath.Join(dataDir, "journal.log"))
+	if err != nil {
+		return err
+	}
+	defer payments.Close()
+
+	// the orders that were in flight when the server stopped are resolved before any new order is placed,
+	// the paid orders are handed back to the kitchen once the workers run, so the customers can still collect them
+	decisions, err := recovery.Run(orderStore, payments, logger, recovery.Requeue)
+	if err != nil {
+		return err
+	}
+	var requeued []string
+	for _, d := range decisions {
+		if d.Requeued() {
+			requeued = append(requeued, d.OrderID)
+		}
+	}
 
 	// creates and run workers for each terminal.
-	for _, terminal := range terminals {
-		worker := workers.NewWorker(terminal, cashRegister)
-		go worker.Run()
+	terminals, _, err := utils.CreateTerminalWorkers(terminalCount, workStealing, workers.WithInventory(inv))
+	if err != nil {
+		return err
+	}
+
+	// pricingRules are the pricing rules that are evaluated over every basket before payment.
+	// Like the terminalCount, they are hardcoded for demonstration purposes.
+	pricingRules := []pricing.Rule{
+		pricing.NewCombo("vegan menu", 10, 60, orders.Vegan, orders.Fries, orders.Drink),
+		pricing.NewCombo("menu", 10, 65, orders.NonVegan, orders.Fries, orders.Drink),
+		pricing.NewBuyXGetY("second vegan currywurst half price", 20, orders.Vegan, 1, 1, 50),
+		pricing.NewCoupon("CURRY10", pricing.NewPercentageDiscount("10% off coupon", 30, "", 10)),
+	}
+
+	// openingHours are the opening hours of the stand for every day of the week,
+	// on fridays and saturdays the stand is open until 2 in the morning.
+	openingHours := []schedule.Option{
+		schedule.WithOpeningHours(time.Monday, schedule.Window{Start: schedule.At(10, 0), End: schedule.At(23, 0)}),
+		schedule.WithOpeningHours(time.Tuesday, schedule.Window{Start: schedule.At(10, 0), End: schedule.At(23, 0)}),
+		schedule.WithOpeningHours(time.Wednesday, schedule.Window{Start: schedule.At(10, 0), End: schedule.At(23, 0)}),
+		schedule.WithOpeningHours(time.Thursday, schedule.Window{Start: schedule.At(10, 0), End: schedule.At(23, 0)}),
+		schedule.WithOpeningHours(time.Friday, schedule.Window{Start: schedule.At(10, 0), End: schedule.At(2, 0)}),
+		schedule.WithOpeningHours(time.Saturday, schedule.Window{Start: schedule.At(10, 0), End: schedule.At(2, 0)}),
+		schedule.WithOpeningHours(time.Sunday, schedule.Window{Start: schedule.At(12, 0), End: schedule.At(22, 0)}),
+		schedule.WithHolidays(
+			schedule.Holiday{Month: time.December, Day: 25},
+			schedule.Holiday{Month: time.January, Day: 1},
+		),
+		schedule.WithPriceOverrides(
+			schedule.PriceOverride{
+				Name:    "happy hour",
+				Days:    []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+				Window:  schedule.Window{Start: schedule.At(15, 0), End: schedule.At(17, 0)},
+				Percent: -20,
+			},
+			schedule.PriceOverride{
+				Name:    "late-night surcharge",
+				Window:  schedule.Window{Start: schedule.At(23, 0), End: schedule.At(2, 0)},
+				Percent: 10,
+			},
+		),
+	}
+
+	// router chooses the terminal of the orders that do not name one
+	router, err := routing.NewRouter(routingPolicy)
+	if err != nil {
+		return err
+	}
+
+	// kiosks keeps track of the heartbeats of the kiosks
+	kiosks, err := liveness.NewMonitor(heartbeatInterval, liveness.WithMissed(missedHeartbeats), liveness.WithAutoPause(terminals))
+	if err != nil {
+		return err
 	}
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go kiosks.Run(ctx)
 
 	// create the handler a serve mux, and registers the handler
-	handler := NewHandler(terminals)
+	handler := NewHandler(terminals,
+		WithLiveness(kiosks),
+		WithRouter(router),
+		WithPricing(pricing.NewEngine(pricingRules...)),
+		WithSchedule(schedule.NewSchedule(clock.System, openingHours...)),
+		WithInventory(inv),
+		WithOrderStore(orderStore),
+		WithJournal(payments),
+		WithHandlerLogger(logger),
+		// the idempotency keys of the order requests are kept for a day
+		WithIdempotency(idempotency.NewStore(clock.System, 24*time.Hour)),
+	)
+	if err = handler.Requeue(requeued); err != nil {
+		return err
+	}
 	mux := http.NewServeMux()
 	handler.RegisterRoutes(mux)
 
-	// create a logger that uses the handler and sets the minimum level to error
-	logger := slog.New(slog.NewTextHandler(os.Stdout, nil))
-
 	// create a server with the logger as the option
 	server := NewServer(mux, WithAddr(":8080"), WithLogger(logger))
 
-	err = server.Start()
-	if err != nil {
-		log.Fatal(err)
-	}
+	return server.Start()
 }
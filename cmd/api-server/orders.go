@@ -0,0 +1,368 @@
+package api_server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/azhovan/currywurst/internal/journal"
+	"github.com/azhovan/currywurst/internal/orders"
+	"github.com/azhovan/currywurst/internal/store"
+)
+
+// OrderAcceptedResponse is a struct type that represents the response to an order that is accepted
+// for asynchronous processing.
+type OrderAcceptedResponse struct {
+	// OrderId is the id of the order, its status can be looked up at /orders/{orderId}.
+	OrderId string `json:"orderId"`
+	// TerminalId is the id of the terminal the order was sent to.
+	TerminalId string `json:"terminalId"`
+	// State is the state of the order when it was accepted.
+	State string `json:"state"`
+	// Queue is the place of the order in the queue of the terminal, it is missing once the order is taken out of it.
+	Queue *QueueEstimate `json:"queue,omitempty"`
+}
+
+// OrderStatusResponse is a struct type that represents the status of an order.
+type OrderStatusResponse struct {
+	// OrderId is the id of the order.
+	OrderId string `json:"orderId"`
+	// TerminalId is the id of the terminal the order was sent to.
+	TerminalId string `json:"terminalId"`
+	// State is the current state of the order, such as `queued` or `ready`.
+	State string `json:"state"`
+	// Priority is the priority class of the order in the queue of the terminal.
+	Priority string `json:"priority"`
+	// Items is the per-line breakdown of the order.
+	Items []OrderLine `json:"items"`
+	// Discounts lists the discounts granted by the pricing rules.
+	Discounts []OrderDiscount `json:"discounts"`
+	// Subtotal is the price of the whole order in cents before discounts.
+	Subtotal int `json:"subtotal"`
+	// Total is the price of the whole order in cents.
+	Total int `json:"total"`
+	// Inserted is the amount of money inserted by the customer in cents.
+	Inserted int `json:"inserted"`
+	// PaymentMethod is the way the order is paid, the terminals only accept `cash`.
+	PaymentMethod string `json:"paymentMethod"`
+	// Returned is the amount of money returned to the customer in cents, once the order is paid.
+	Returned int `json:"returned"`
+	// ReturnedFormatted is the amount of money returned to the customer in a human-readable format.
+	ReturnedFormatted string `json:"returnedFormatted,omitempty"`
+	// CreatedAt is the time the order was created at.
+	CreatedAt time.Time `json:"createdAt"`
+	// UpdatedAt is the time of the last transition of the order.
+	UpdatedAt time.Time `json:"updatedAt"`
+	// History lists the transitions of the order, oldest first.
+	History []OrderTransition `json:"history"`
+	// Error is the reason the order failed, if it did.
+	Error string `json:"error,omitempty"`
+	// Queue is the place of the order in the queue of the terminal, it is missing once the order is taken out of it.
+	Queue *QueueEstimate `json:"queue,omitempty"`
+}
+
+// OrderTransition is a struct type that represents a change of the state of an order.
+type OrderTransition struct {
+	// From is the state the order left, it is empty for the initial state.
+	From string `json:"from"`
+	// To is the state the order entered.
+	To string `json:"to"`
+	// At is the time of the transition.
+	At time.Time `json:"at"`
+	// Reason tells why the order was moved, i.e. when it was recovered after a restart.
+	Reason string `json:"reason,omitempty"`
+}
+
+// ordersHandler handles the /orders endpoint.
+// The order is accepted with 202 Accepted as soon as it is queued, and the Location header points to its status,
+// so the client polls or subscribes for the result instead of holding the connection open.
+// With the wait=true query parameter it waits for the order and responds like the /order endpoint.
+// A GET request queries the history of the orders instead, see listOrdersHandler.
+func (h *Handler) ordersHandler(w http.ResponseWriter, r *http.Request) {
+	// the history of the orders is queried on the same path
+	if r.Method == http.MethodGet {
+		h.listOrdersHandler(w, r)
+		return
+	}
+
+	// check the method and the pin
+	if err := h.validateRequest(r, http.MethodPost); err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+
+	wait := false
+	if value := r.URL.Query().Get("wait"); value != "" {
+		var err error
+		if wait, err = strconv.ParseBool(value); err != nil {
+			h.writeJSONError(w, &httpError{"invalid wait parameter", http.StatusBadRequest})
+			return
+		}
+	}
+
+	// process the HTTP request body
+	orderRequest, err := h.parseOrderRequest(r)
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+
+	// get the terminal by id, or let the router choose one
+	terminal, err := h.getTerminal(r, orderRequest)
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+
+	if wait {
+		order, err := h.sendOrder(r.Context(), terminal, orderRequest)
+		if err != nil {
+			h.writeJSONError(w, err)
+			return
+		}
+		h.writeOrderResponse(w, order)
+		return
+	}
+
+	// the order is not tied to the request, it is processed after the response is written
+	order, err := h.placeOrder(context.Background(), terminal, orderRequest)
+	if err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+	go h.settleOrder(order)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/orders/"+order.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(OrderAcceptedResponse{
+		OrderId:    order.ID,
+		TerminalId: order.TerminalID,
+		State:      order.State().String(),
+		Queue:      h.queueEstimate(order.ID, order.TerminalID),
+	})
+}
+
+// settleOrder waits for an asynchronous order in the background and issues its receipt once it is paid.
+// The outcome of the order is kept in the order store, so there is nothing else to do on failure.
+func (h *Handler) settleOrder(order *orders.Order) {
+	if err := h.awaitOrder(order); err != nil {
+		return
+	}
+	h.issueReceipt(order)
+
+	// the terminal hands out the change and the receipt is kept for the customer,
+	// like a synchronous order the order is collected once they are handed over
+	order.Collect()
+}
+
+// reasonTerminalGone is the reason a re-queued order is refunded, when its terminal is no longer there
+const reasonTerminalGone = "the terminal of the order is gone, the payment is refunded"
+
+// Requeue hands the orders with the given ids, that were paid but not prepared when the server stopped,
+// back to the kitchen of their terminal, see recovery.Requeue. It is meant to run on start once the workers run,
+// before any order is placed. The orders are tracked like new ones, so their status, events and receipts are
+// served as usual. An order that its terminal does not take, i.e. because the terminal is gone, is refunded
+// in full and the refund is recorded in the journal.
+func (h *Handler) Requeue(orderIds []string) error {
+	for _, id := range orderIds {
+		snapshot, err := h.orders.Get(id)
+		if err != nil {
+			return err
+		}
+		order := orders.Restore(snapshot)
+		if err = store.Track(h.orders, order, h.logger); err != nil {
+			return err
+		}
+		h.events.Track(order)
+		h.active.add(order)
+
+		terminal, ok := h.terminals.Get(order.TerminalID)
+		if !ok || !h.attachOrder(order, terminal) {
+			order.Recover(orders.StateFailed, reasonTerminalGone)
+			h.recordRefund(order, snapshot.State)
+			continue
+		}
+		// the terminal refunds the order it does not take
+		if err = terminal.Put(order); err != nil {
+			h.recordRefund(order, snapshot.State)
+			continue
+		}
+		go h.settleOrder(order)
+	}
+	return nil
+}
+
+// recordRefund logs the refund of a re-queued order and records it in the journal
+func (h *Handler) recordRefund(order *orders.Order, from orders.State) {
+	snapshot := order.Snapshot()
+	h.logger.Warn("re-queued order refunded",
+		slog.String("order_id", snapshot.ID),
+		slog.String("terminal_id", snapshot.TerminalID),
+		slog.String("reason", snapshot.Error),
+		slog.Int("refunded_cents", snapshot.Returned.Cents),
+	)
+	if h.journal != nil {
+		if err := h.journal.Record(journal.Recovery(snapshot, from, snapshot.Error, h.clock.Now())); err != nil {
+			h.logger.Error("refund not recorded in the journal", slog.String("order_id", snapshot.ID), slog.Any("err", err))
+		}
+	}
+}
+
+// orderResourceHandler handles the /orders/{orderId} endpoints, it hands the request over
+// to the handler of the method and the resource of the order.
+func (h *Handler) orderResourceHandler(w http.ResponseWriter, r *http.Request) {
+	orderId, resource, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/orders/"), "/")
+	switch {
+	case orderId == "":
+		h.writeJSONError(w, &httpError{"not found", http.StatusNotFound})
+	case resource == "events":
+		h.orderEventsHandler(w, r, orderId)
+	case resource != "":
+		h.writeJSONError(w, &httpError{"not found", http.StatusNotFound})
+	case r.Method == http.MethodDelete:
+		h.cancelOrderHandler(w, r, orderId)
+	default:
+		h.orderStatusHandler(w, r, orderId)
+	}
+}
+
+// orderStatusHandler handles GET /orders/{orderId}, it responds with the current status of the order.
+func (h *Handler) orderStatusHandler(w http.ResponseWriter, r *http.Request, orderId string) {
+	// check the method and the pin
+	if err := h.validateRequest(r, http.MethodGet); err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+
+	snapshot, err := h.orders.Get(orderId)
+	if errors.Is(err, store.ErrOrderNotFound) {
+		h.writeJSONError(w, &httpError{err.Error(), http.StatusNotFound})
+		return
+	}
+	if err != nil {
+		h.writeJSONError(w, &httpError{err.Error(), http.StatusInternalServerError})
+		return
+	}
+
+	// the place in the queue changes all the time, so it is only part of the status of the order when it is asked for
+	response := newOrderStatusResponse(snapshot)
+	response.Queue = h.queueEstimate(snapshot.ID, snapshot.TerminalID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// cancelOrderHandler handles DELETE /orders/{orderId}.
+// It cancels an order that has not been paid yet, the inserted money is returned in full.
+// An order that is being paid or already settled can no longer be cancelled, it is answered with 409 Conflict.
+func (h *Handler) cancelOrderHandler(w http.ResponseWriter, r *http.Request, orderId string) {
+	// check the method and the pin
+	if err := h.validateRequest(r, http.MethodDelete); err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+
+	if order, ok := h.active.get(orderId); ok {
+		err := order.Cancel()
+		if errors.Is(err, orders.ErrInvalidTransition) {
+			h.writeJSONError(w, &httpError{fmt.Sprintf("order can no longer be cancelled, it is %s", order.State()), http.StatusConflict})
+			return
+		}
+		if err != nil {
+			h.writeJSONError(w, &httpError{err.Error(), http.StatusInternalServerError})
+			return
+		}
+	}
+
+	// the order is settled, or it has just been cancelled
+	snapshot, err := h.orders.Get(orderId)
+	if errors.Is(err, store.ErrOrderNotFound) {
+		h.writeJSONError(w, &httpError{err.Error(), http.StatusNotFound})
+		return
+	}
+	if err != nil {
+		h.writeJSONError(w, &httpError{err.Error(), http.StatusInternalServerError})
+		return
+	}
+
+	// cancelling an order that is already cancelled is not an error
+	if snapshot.State != orders.StateCancelled {
+		h.writeJSONError(w, &httpError{fmt.Sprintf("order can no longer be cancelled, it is %s", snapshot.State), http.StatusConflict})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(newOrderStatusResponse(snapshot))
+}
+
+// activeOrders keeps the orders that are not settled yet, so they can be cancelled by id.
+// It is safe for concurrent use.
+type activeOrders struct {
+	mu     sync.Mutex
+	orders map[string]*orders.Order
+}
+
+// newActiveOrders returns a new empty set of active orders
+func newActiveOrders() *activeOrders {
+	return &activeOrders{orders: map[string]*orders.Order{}}
+}
+
+// add keeps the order until it is ready, failed or cancelled
+func (a *activeOrders) add(order *orders.Order) {
+	a.mu.Lock()
+	a.orders[order.ID] = order
+	a.mu.Unlock()
+
+	order.Observe(func(s orders.Snapshot) {
+		if s.State == orders.StateReady || s.State.IsFinal() {
+			a.mu.Lock()
+			delete(a.orders, s.ID)
+			a.mu.Unlock()
+		}
+	})
+}
+
+// get returns the active order with the given id
+func (a *activeOrders) get(id string) (*orders.Order, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	order, ok := a.orders[id]
+	return order, ok
+}
+
+// newOrderStatusResponse builds the status response out of a snapshot of the order
+func newOrderStatusResponse(snapshot orders.Snapshot) OrderStatusResponse {
+	history := make([]OrderTransition, 0, len(snapshot.History))
+	for _, t := range snapshot.History {
+		history = append(history, OrderTransition{From: t.From.String(), To: t.To.String(), At: t.At, Reason: t.Reason})
+	}
+
+	return OrderStatusResponse{
+		OrderId:           snapshot.ID,
+		TerminalId:        snapshot.TerminalID,
+		State:             snapshot.State.String(),
+		Priority:          snapshot.Priority.String(),
+		Items:             newOrderLines(snapshot.Items),
+		Discounts:         newOrderDiscounts(snapshot.Discounts),
+		Subtotal:          snapshot.Subtotal,
+		Total:             snapshot.Total,
+		Inserted:          snapshot.Inserted,
+		PaymentMethod:     snapshot.Payment.String(),
+		Returned:          snapshot.Returned.Cents,
+		ReturnedFormatted: snapshot.Returned.Formatted,
+		CreatedAt:         snapshot.CreatedAt(),
+		UpdatedAt:         snapshot.UpdatedAt(),
+		History:           history,
+		Error:             snapshot.Error,
+	}
+}
@@ -0,0 +1,134 @@
+package api_server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/azhovan/currywurst/internal/cashregister"
+	"github.com/azhovan/currywurst/internal/inventory"
+	"github.com/azhovan/currywurst/internal/journal"
+	"github.com/azhovan/currywurst/internal/orders"
+)
+
+func TestOrders_TerminalFull(t *testing.T) {
+	mux, _ := newTestMux(t, 1, []string{"terminal-0"}, WithQueueTimeout(10*time.Millisecond))
+	body := `{"terminalId":"terminal-0","orderType":"vegan","insertedPrice":30}`
+
+	if w := serve(mux, http.MethodPost, "/orders", "1234", body); w.Code != http.StatusAccepted {
+		t.Fatalf("POST /orders got status:%d, want:%d", w.Code, http.StatusAccepted)
+	}
+
+	// the queue is full and nobody takes the order out of it, the customer is asked to come back later
+	for _, path := range []string{"/order", "/orders"} {
+		w := serve(mux, http.MethodPost, path, "1234", body)
+		if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "2" {
+			t.Errorf("POST %s got status:%d Retry-After:%q, want:%d %q",
+				path, w.Code, w.Header().Get("Retry-After"), http.StatusServiceUnavailable, "2")
+		}
+	}
+}
+
+func TestOrders_Cancel(t *testing.T) {
+	mux, registry := newTestMux(t, 10, []string{"terminal-0"})
+	body := `{"terminalId":"terminal-0","orderType":"vegan","insertedPrice":30}`
+
+	// a queued order is cancelled, and cancelling it again is not an error
+	var accepted OrderAcceptedResponse
+	json.NewDecoder(serve(mux, http.MethodPost, "/orders", "1234", body).Body).Decode(&accepted)
+	for i := 0; i < 2; i++ {
+		w := serve(mux, http.MethodDelete, "/orders/"+accepted.OrderId, "1234", "")
+		var status OrderStatusResponse
+		json.NewDecoder(w.Body).Decode(&status)
+		if w.Code != http.StatusOK || status.State != orders.StateCancelled.String() {
+			t.Errorf("DELETE /orders/{id} #%d got status:%d state:%s, want:%d %s",
+				i, w.Code, status.State, http.StatusOK, orders.StateCancelled)
+		}
+	}
+
+	// an order that is being paid is past the point of no return
+	json.NewDecoder(serve(mux, http.MethodPost, "/orders", "1234", body).Body).Decode(&accepted)
+	terminal, _ := registry.Get("terminal-0")
+	// the cancelled order is still in the queue, it is skipped like a worker does
+	terminal.TryGet()
+	order, err := terminal.TryGet()
+	if err != nil || order.ID != accepted.OrderId {
+		t.Fatalf("terminal.TryGet() got error:%v, want the second order", err)
+	}
+	for _, state := range []orders.State{orders.StateValidating, orders.StatePaying} {
+		if err = order.Transition(state); err != nil {
+			t.Fatalf("order.Transition(%s) got error:%v, want nil", state, err)
+		}
+	}
+	if w := serve(mux, http.MethodDelete, "/orders/"+accepted.OrderId, "1234", ""); w.Code != http.StatusConflict {
+		t.Errorf("DELETE /orders/{id} of a paying order got status:%d, want:%d", w.Code, http.StatusConflict)
+	}
+
+	if w := serve(mux, http.MethodDelete, "/orders/unknown", "1234", ""); w.Code != http.StatusNotFound {
+		t.Errorf("DELETE /orders/{id} of an unknown order got status:%d, want:%d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestOrders_Journal(t *testing.T) {
+	var buf bytes.Buffer
+	mux, registry := newTestMux(t, 10, []string{"terminal-0"}, WithJournal(journal.New(&buf)))
+
+	var accepted OrderAcceptedResponse
+	body := `{"terminalId":"terminal-0","items":[{"orderType":"vegan","quantity":2},{"orderType":"drink"}],"insertedPrice":100}`
+	json.NewDecoder(serve(mux, http.MethodPost, "/orders", "1234", body).Body).Decode(&accepted)
+
+	// the test takes the order like a worker, and pays it
+	terminal, _ := registry.Get("terminal-0")
+	order, err := terminal.TryGet()
+	if err != nil {
+		t.Fatalf("terminal.TryGet() got error:%v, want nil", err)
+	}
+	order.Transition(orders.StateValidating)
+	order.Transition(orders.StatePaying)
+	order.Paid(cashregister.ReturnedAmount{Cents: 20, Formatted: "20 Cent"})
+
+	// the payment is recorded once the order is paid, before anybody gets a receipt
+	var entry journal.Entry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("the journal got %q, want a payment", buf.String())
+	}
+	if entry.Kind != journal.KindPayment || entry.OrderID != accepted.OrderId || len(entry.Lines) != 2 || entry.Total != 80 {
+		t.Errorf("the journal got %+v, want the payment of the order with its 2 lines", entry)
+	}
+	order.Transition(orders.StateReady)
+
+	// the receipt does not record the payment again
+	var status OrderStatusResponse
+	for deadline := time.Now().Add(time.Second); status.State != orders.StateCollected.String(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("GET /orders/{id} got state:%s, want:%s", status.State, orders.StateCollected)
+		}
+		json.NewDecoder(serve(mux, http.MethodGet, "/orders/"+accepted.OrderId, "1234", "").Body).Decode(&status)
+	}
+
+	if lines := strings.Count(buf.String(), "\n"); lines != 1 {
+		t.Errorf("the journal holds %d entries, want:1", lines)
+	}
+}
+
+func TestOrders_OutOfStock(t *testing.T) {
+	inv := inventory.NewInventory(inventory.DefaultRecipes(), map[inventory.Ingredient]int{inventory.VeganSausage: 1, inventory.Sauce: 10})
+	mux, registry := newTestMux(t, 10, []string{"terminal-0"}, WithInventory(inv))
+
+	// a single sausage is left, so five vegan currywursts can not be made
+	body := `{"terminalId":"terminal-0","items":[{"orderType":"vegan","quantity":5}],"insertedPrice":150}`
+	if w := serve(mux, http.MethodPost, "/orders", "1234", body); w.Code != http.StatusConflict {
+		t.Errorf("POST /orders got status:%d, want:%d", w.Code, http.StatusConflict)
+	}
+	if terminal, _ := registry.Get("terminal-0"); terminal.Len() != 0 {
+		t.Errorf("got %d queued orders, want none", terminal.Len())
+	}
+
+	body = `{"terminalId":"terminal-0","items":[{"orderType":"vegan","quantity":1}],"insertedPrice":30}`
+	if w := serve(mux, http.MethodPost, "/orders", "1234", body); w.Code != http.StatusAccepted {
+		t.Errorf("POST /orders got status:%d, want:%d", w.Code, http.StatusAccepted)
+	}
+}
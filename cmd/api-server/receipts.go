@@ -0,0 +1,72 @@
+package api_server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/azhovan/currywurst/internal/receipts"
+	"github.com/azhovan/currywurst/internal/store"
+)
+
+// receiptHandler handles the /receipts/{orderId} endpoint.
+// It responds with the receipt as JSON, or as plain text when the format=text query parameter
+// is set or the client accepts text/plain.
+func (h *Handler) receiptHandler(w http.ResponseWriter, r *http.Request) {
+	// check the method and the pin
+	if err := h.validateRequest(r, http.MethodGet); err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+
+	orderId := strings.TrimPrefix(r.URL.Path, "/receipts/")
+	if orderId == "" || strings.Contains(orderId, "/") {
+		h.writeJSONError(w, &httpError{"not found", http.StatusNotFound})
+		return
+	}
+
+	receipt, err := h.findReceipt(orderId)
+	if errors.Is(err, receipts.ErrReceiptNotFound) {
+		h.writeJSONError(w, &httpError{err.Error(), http.StatusNotFound})
+		return
+	}
+	if err != nil {
+		h.writeJSONError(w, &httpError{err.Error(), http.StatusInternalServerError})
+		return
+	}
+
+	if r.URL.Query().Get("format") == "text" || strings.Contains(r.Header.Get("Accept"), "text/plain") {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(receipt.Text()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(receipt)
+}
+
+// findReceipt returns the receipt of the order. The receipts are kept in memory, so the receipt of an order
+// that was paid before the server restarted is rebuilt out of the order store, and kept again.
+func (h *Handler) findReceipt(orderId string) (*receipts.Receipt, error) {
+	receipt, err := h.receipts.Get(orderId)
+	if !errors.Is(err, receipts.ErrReceiptNotFound) {
+		return receipt, err
+	}
+
+	snapshot, err := h.orders.Get(orderId)
+	if errors.Is(err, store.ErrOrderNotFound) {
+		return nil, receipts.ErrReceiptNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if receipt, err = receipts.Rebuild(snapshot); err != nil {
+		return nil, err
+	}
+	h.receipts.Put(receipt)
+	return receipt, nil
+}
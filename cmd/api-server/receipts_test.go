@@ -0,0 +1,46 @@
+package api_server
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"github.com/azhovan/currywurst/internal/cashregister"
+	"github.com/azhovan/currywurst/internal/orders"
+	"github.com/azhovan/currywurst/internal/receipts"
+	"github.com/azhovan/currywurst/internal/store"
+)
+
+func TestReceipts_AfterRestart(t *testing.T) {
+	// the order was paid before the server restarted, only its snapshot is left
+	orderStore := store.NewMemory()
+	paid := orders.NewOrder(context.TODO(), 50, orders.Vegan)
+	store.Track(orderStore, paid, slog.Default())
+	for _, state := range []orders.State{orders.StateQueued, orders.StateValidating, orders.StatePaying} {
+		paid.Transition(state)
+	}
+	paid.Paid(cashregister.ReturnedAmount{Cents: 20, Formatted: "20 Cent"})
+	paid.Transition(orders.StateReady)
+	unpaid := orders.NewOrder(context.TODO(), 50, orders.Vegan)
+	store.Track(orderStore, unpaid, slog.Default())
+
+	mux, _ := newTestMux(t, 10, []string{"terminal-0"}, WithOrderStore(orderStore))
+
+	w := serve(mux, http.MethodGet, "/receipts/"+paid.ID, "1234", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /receipts/{id} got status:%d, want:%d", w.Code, http.StatusOK)
+	}
+	var receipt receipts.Receipt
+	json.NewDecoder(w.Body).Decode(&receipt)
+	if receipt.Total != 30 || receipt.Paid != 50 || receipt.Returned != 20 {
+		t.Errorf("GET /receipts/{id} got total:%d paid:%d returned:%d, want:30 50 20", receipt.Total, receipt.Paid, receipt.Returned)
+	}
+
+	for _, id := range []string{unpaid.ID, "unknown"} {
+		if w := serve(mux, http.MethodGet, "/receipts/"+id, "1234", ""); w.Code != http.StatusNotFound {
+			t.Errorf("GET /receipts/%s got status:%d, want:%d", id, w.Code, http.StatusNotFound)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package api_server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/azhovan/currywurst/internal/cashregister"
+	"github.com/azhovan/currywurst/internal/journal"
+	"github.com/azhovan/currywurst/internal/orders"
+	"github.com/azhovan/currywurst/internal/store"
+)
+
+// paidOrder returns an order of the given terminal that was paid but not prepared when the server stopped
+func paidOrder(t *testing.T, s store.OrderStore, terminalId string) *orders.Order {
+	t.Helper()
+
+	order := orders.NewOrder(context.TODO(), 50, orders.Vegan)
+	order.TerminalID = terminalId
+	if err := store.Track(s, order, slog.Default()); err != nil {
+		t.Fatalf("store.Track() got error:%v, want nil", err)
+	}
+	for _, state := range []orders.State{orders.StateQueued, orders.StateValidating, orders.StatePaying} {
+		order.Transition(state)
+	}
+	order.Paid(cashregister.ReturnedAmount{Cents: 20, Formatted: "20 Cent"})
+	return order
+}
+
+func TestHandler_Requeue(t *testing.T) {
+	orderStore := store.NewMemory()
+	requeued := paidOrder(t, orderStore, "terminal-0")
+	orphan := paidOrder(t, orderStore, "terminal-gone")
+
+	var buf bytes.Buffer
+	registry := newTestRegistry(t, 10, "terminal-0")
+	handler := NewHandler(registry, WithOrderStore(orderStore), WithJournal(journal.New(&buf)))
+	mux := http.NewServeMux()
+	handler.RegisterRoutes(mux)
+
+	if err := handler.Requeue([]string{requeued.ID, orphan.ID}); err != nil {
+		t.Fatalf("handler.Requeue() got error:%v, want nil", err)
+	}
+
+	// the order of a terminal that is gone is refunded, and the refund is recorded in the journal
+	snapshot, _ := orderStore.Get(orphan.ID)
+	if snapshot.State != orders.StateFailed || snapshot.Returned.Cents != 50 {
+		t.Errorf("orphan order got state:%s returned:%d, want:%s %d", snapshot.State, snapshot.Returned.Cents, orders.StateFailed, 50)
+	}
+	var refund journal.Entry
+	if err := json.Unmarshal(buf.Bytes(), &refund); err != nil || refund.Kind != journal.KindRecovery || refund.OrderID != orphan.ID {
+		t.Errorf("the journal got %q, want the refund of the orphan order", buf.String())
+	}
+	buf.Reset()
+
+	// the test takes the order like a worker, it is still paid and only has to be prepared
+	terminal, _ := registry.Get("terminal-0")
+	order, err := terminal.TryGet()
+	if err != nil || order.ID != requeued.ID || order.State() != orders.StatePreparing {
+		t.Fatalf("terminal.TryGet() got error:%v, want the re-queued order being prepared", err)
+	}
+	order.Transition(orders.StateReady)
+
+	// the order is settled like any other, its customer gets the receipt and the change
+	var status OrderStatusResponse
+	for deadline := time.Now().Add(time.Second); status.State != orders.StateCollected.String(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("GET /orders/{id} got state:%s, want:%s", status.State, orders.StateCollected)
+		}
+		json.NewDecoder(serve(mux, http.MethodGet, "/orders/"+requeued.ID, "1234", "").Body).Decode(&status)
+	}
+	if status.Returned != 20 {
+		t.Errorf("GET /orders/{id} got returned:%d, want:%d", status.Returned, 20)
+	}
+	if w := serve(mux, http.MethodGet, "/receipts/"+requeued.ID, "1234", ""); w.Code != http.StatusOK {
+		t.Errorf("GET /receipts/{id} got status:%d, want:%d", w.Code, http.StatusOK)
+	}
+}
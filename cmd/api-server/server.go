@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
 	"time"
 )
 
@@ -56,14 +57,15 @@ func WithLogger(logger *slog.Logger) ServerOption {
 // Start starts the server and listens for signals
 func (s *Server) Start() error {
 	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, os.Interrupt, os.Kill)
+	// SIGTERM is what a process manager stops the server with, os.Kill can not be caught
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 
 	// start the server
 	go func() {
 		s.logger.Info("Starting server", "addr", s.server.Addr)
 		err := s.server.ListenAndServe()
 		if err != nil && err != http.ErrServerClosed {
-			s.logger.Error("Server error", err)
+			s.logger.Error("Server error", "err", err)
 		}
 	}()
 
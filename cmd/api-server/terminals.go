@@ -0,0 +1,361 @@
+package api_server
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/azhovan/currywurst/internal/terminals"
+)
+
+// TerminalRequest is a struct type that represents the request body of the admin API of the terminals.
+type TerminalRequest struct {
+	// TerminalId is the id of a new terminal, or the new id of a renamed terminal.
+	TerminalId string `json:"terminalId"`
+	// Paused pauses or resumes the terminal, it is left as it is when it is missing.
+	Paused *bool `json:"paused,omitempty"`
+	// State switches the terminal to open, paused, maintenance or closed, it is left as it is when it is missing.
+	State string `json:"state,omitempty"`
+	// Reason is the reason the state of the terminal is switched, i.e. "coin acceptor jammed".
+	Reason string `json:"reason,omitempty"`
+	// Workers is the number of workers that process the orders of the terminal, it is left as it is when it is missing.
+	Workers *int `json:"workers,omitempty"`
+}
+
+// TerminalResponse is a struct type that represents a terminal in the admin API.
+type TerminalResponse struct {
+	// TerminalId is the id of the terminal.
+	TerminalId string `json:"terminalId"`
+	// Paused tells whether the terminal refuses new orders for now.
+	Paused bool `json:"paused"`
+	// State is the state of the terminal: open, paused, maintenance or closed.
+	State string `json:"state"`
+	// Reason is the reason the terminal has been switched to its state, if any.
+	Reason string `json:"reason,omitempty"`
+	// Since is the time the terminal has been switched to its state.
+	Since time.Time `json:"since"`
+	// Queued is the number of orders waiting in the queue of the terminal.
+	Queued int `json:"queued"`
+	// Workers is the number of workers that process the orders of the terminal.
+	Workers int `json:"workers"`
+	// CurrentWaitSeconds is the time an order that joins the queue now takes to be ready, in seconds.
+	CurrentWaitSeconds int `json:"currentWaitSeconds"`
+	// Kiosk is the liveness of the kiosk attached to the terminal, it is missing until the kiosk sends a heartbeat.
+	Kiosk *KioskHealth `json:"kiosk,omitempty"`
+}
+
+// TerminalWaitResponse is a struct type that represents the current wait of a terminal, for the signage.
+type TerminalWaitResponse struct {
+	// TerminalId is the id of the terminal.
+	TerminalId string `json:"terminalId"`
+	// State is the state of the terminal, the signage tells the customers when it does not take orders.
+	State string `json:"state"`
+	// Queued is the number of orders waiting in the queue of the terminal.
+	Queued int `json:"queued"`
+	// CurrentWaitSeconds is the time an order that joins the queue now takes to be ready, in seconds.
+	CurrentWaitSeconds int `json:"currentWaitSeconds"`
+}
+
+// QueueEstimate is a struct type that represents the place of an order in the queue of its terminal.
+type QueueEstimate struct {
+	// Position is the place of the order in the queue, the first order is served next.
+	Position int `json:"position"`
+	// EstimatedWaitSeconds is the time until the order is ready, in seconds.
+	EstimatedWaitSeconds int `json:"estimatedWaitSeconds"`
+}
+
+// terminalsHandler handles the /terminals/{terminalId}/... endpoints, it hands the request over
+// to the handler of the resource of the terminal.
+func (h *Handler) terminalsHandler(w http.ResponseWriter, r *http.Request) {
+	terminalId, resource, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/terminals/"), "/")
+	terminal, ok := h.terminals.Get(terminalId)
+	if !ok {
+		h.writeJSONError(w, &httpError{"not found", http.StatusNotFound})
+		return
+	}
+
+	switch resource {
+	case "events":
+		h.terminalEventsHandler(w, r, terminalId, terminal)
+	case "ws":
+		h.kioskHandler(w, r, terminalId, terminal)
+	case "wait":
+		h.terminalWaitHandler(w, r, terminalId, terminal)
+	case "heartbeat":
+		h.heartbeatHandler(w, r, terminalId, terminal)
+	default:
+		h.writeJSONError(w, &httpError{"not found", http.StatusNotFound})
+	}
+}
+
+// terminalWaitHandler handles GET /terminals/{terminalId}/wait, it responds with the current wait of the terminal.
+func (h *Handler) terminalWaitHandler(w http.ResponseWriter, r *http.Request, terminalId string, terminal *terminals.Terminal) {
+	// check the method and the pin
+	if err := h.validateRequest(r, http.MethodGet); err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(TerminalWaitResponse{
+		TerminalId:         terminalId,
+		State:              terminal.Status().State.String(),
+		Queued:             terminal.Len(),
+		CurrentWaitSeconds: seconds(terminal.CurrentWait(h.workerCount(terminalId))),
+	})
+}
+
+// adminTerminalsHandler handles the /admin/terminals endpoint.
+// A GET request lists the terminals, a POST request adds a terminal and starts its workers.
+func (h *Handler) adminTerminalsHandler(w http.ResponseWriter, r *http.Request) {
+	// check the method and the admin pin
+	if err := h.validateAdminRequest(r, http.MethodGet, http.MethodPost); err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		ids := h.terminals.IDs()
+		response := make([]TerminalResponse, 0, len(ids))
+		for _, id := range ids {
+			if terminal, ok := h.terminals.Get(id); ok {
+				response = append(response, h.newTerminalResponse(id, terminal))
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	request := TerminalRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		h.writeJSONError(w, &httpError{"Bad request", http.StatusBadRequest})
+		return
+	}
+
+	// the size of the pool is checked before the terminal is added, so an invalid request adds nothing
+	if request.Workers != nil && *request.Workers < 1 {
+		h.writeJSONError(w, &httpError{"invalid workers, a terminal needs at least one worker", http.StatusBadRequest})
+		return
+	}
+
+	terminal, err := h.terminals.Add(request.TerminalId)
+	if err != nil {
+		h.writeJSONError(w, terminalError(err))
+		return
+	}
+	if request.Workers != nil {
+		if err := h.resizeWorkers(request.TerminalId, *request.Workers); err != nil {
+			// the terminal is added with its pool or not at all, an order that made it in the meantime is finished
+			h.terminals.Remove(request.TerminalId, terminals.CloseDrain)
+			h.writeJSONError(w, err)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/admin/terminals/"+request.TerminalId)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(h.newTerminalResponse(request.TerminalId, terminal))
+}
+
+// adminTerminalHandler handles the /admin/terminals/{terminalId} endpoint.
+// A GET request returns the terminal, a PATCH request renames it, switches its state or resizes its pool of workers,
+// and a DELETE request removes it. The removed terminal drains its queue by default, with the mode=fail query
+// parameter its queued orders are failed instead. Its workers stop once the terminal is closed.
+func (h *Handler) adminTerminalHandler(w http.ResponseWriter, r *http.Request) {
+	// check the method and the admin pin
+	if err := h.validateAdminRequest(r, http.MethodGet, http.MethodPatch, http.MethodDelete); err != nil {
+		h.writeJSONError(w, err)
+		return
+	}
+
+	terminalId := strings.TrimPrefix(r.URL.Path, "/admin/terminals/")
+	terminal, ok := h.terminals.Get(terminalId)
+	if !ok {
+		h.writeJSONError(w, &httpError{"not found", http.StatusNotFound})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPatch:
+		request := TerminalRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			h.writeJSONError(w, &httpError{"Bad request", http.StatusBadRequest})
+			return
+		}
+
+		// everything is checked before anything is changed, so an invalid request changes nothing
+		if err := h.validateTerminalRequest(terminalId, terminal, request); err != nil {
+			h.writeJSONError(w, err)
+			return
+		}
+
+		// the rename goes first, it is the only change that depends on what is attached to the terminal right now
+		if request.TerminalId != "" && request.TerminalId != terminalId {
+			if err := h.attached.rename(terminalId, request.TerminalId); err != nil {
+				h.writeJSONError(w, terminalError(err))
+				return
+			}
+			// the kiosk keeps its liveness, a terminal it paused is resumed by its next heartbeat to the new id
+			h.renameKiosk(terminalId, request.TerminalId)
+			terminalId = request.TerminalId
+		}
+
+		if request.Workers != nil {
+			if err := h.resizeWorkers(terminalId, *request.Workers); err != nil {
+				h.writeJSONError(w, err)
+				return
+			}
+		}
+
+		if request.Paused != nil {
+			change := terminal.Resume
+			if *request.Paused {
+				change = terminal.Pause
+			}
+			if err := change(); err != nil {
+				h.writeJSONError(w, terminalError(err))
+				return
+			}
+		}
+
+		if request.State != "" {
+			if err := terminal.SetState(terminals.State(request.State), request.Reason); err != nil {
+				h.writeJSONError(w, terminalError(err))
+				return
+			}
+		}
+
+	case http.MethodDelete:
+		mode := terminals.CloseDrain
+		switch r.URL.Query().Get("mode") {
+		case "", "drain":
+		case "fail":
+			mode = terminals.CloseFail
+		default:
+			h.writeJSONError(w, &httpError{"invalid mode parameter", http.StatusBadRequest})
+			return
+		}
+
+		if err := h.terminals.Remove(terminalId, mode); err != nil {
+			h.writeJSONError(w, terminalError(err))
+			return
+		}
+		h.forgetKiosk(terminalId)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.newTerminalResponse(terminalId, terminal))
+}
+
+// validateTerminalRequest checks the changes of a PATCH request of the terminal with the given id, before any of them is made
+func (h *Handler) validateTerminalRequest(terminalId string, terminal *terminals.Terminal, request TerminalRequest) *httpError {
+	if request.Workers != nil {
+		if *request.Workers < 1 {
+			return &httpError{"invalid workers, a terminal needs at least one worker", http.StatusBadRequest}
+		}
+		if _, ok := h.terminals.Workers(terminalId); !ok {
+			return &httpError{"the terminal has no workers to resize", http.StatusConflict}
+		}
+	}
+
+	if request.State != "" && !terminals.State(request.State).IsValid() {
+		return terminalError(terminals.ErrInvalidState)
+	}
+	if closed, _ := terminal.IsClosed(); closed && (request.Paused != nil || request.State != "") {
+		return terminalError(terminals.ErrTerminalClosed)
+	}
+
+	if request.TerminalId != "" && request.TerminalId != terminalId {
+		if err := terminals.ValidateID(request.TerminalId); err != nil {
+			return terminalError(err)
+		}
+		if _, ok := h.terminals.Get(request.TerminalId); ok {
+			return terminalError(terminals.ErrTerminalExists)
+		}
+	}
+	return nil
+}
+
+// newTerminalResponse creates the admin view of the terminal
+func (h *Handler) newTerminalResponse(terminalId string, terminal *terminals.Terminal) TerminalResponse {
+	status := terminal.Status()
+	response := TerminalResponse{
+		TerminalId: terminalId,
+		Paused:     status.State == terminals.StatePaused,
+		State:      status.State.String(),
+		Reason:     status.Reason,
+		Since:      status.Since,
+		Queued:     terminal.Len(),
+	}
+	if workers, ok := h.terminals.Workers(terminalId); ok {
+		response.Workers = workers.Size()
+	}
+	response.CurrentWaitSeconds = seconds(terminal.CurrentWait(response.Workers))
+	response.Kiosk = h.kioskHealth(terminalId)
+	return response
+}
+
+// queueEstimate returns the place of the order in the queue of the terminal and the time until it is ready,
+// or nil if the order is not waiting in the queue
+func (h *Handler) queueEstimate(orderId, terminalId string) *QueueEstimate {
+	terminal, ok := h.terminals.Get(terminalId)
+	if !ok {
+		return nil
+	}
+	position, ok := terminal.Position(orderId)
+	if !ok {
+		return nil
+	}
+
+	return &QueueEstimate{
+		Position:             position,
+		EstimatedWaitSeconds: seconds(terminal.EstimateWait(position, h.workerCount(terminalId))),
+	}
+}
+
+// workerCount returns the number of workers of the terminal with the given id, a terminal is served by one at least
+func (h *Handler) workerCount(terminalId string) int {
+	if workers, ok := h.terminals.Workers(terminalId); ok {
+		return workers.Size()
+	}
+	return 1
+}
+
+// seconds rounds the duration up to whole seconds, a customer is never told they are served sooner than they are
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// resizeWorkers changes the number of workers of the terminal with the given id
+func (h *Handler) resizeWorkers(terminalId string, size int) *httpError {
+	workers, ok := h.terminals.Workers(terminalId)
+	if !ok {
+		return &httpError{"the terminal has no workers to resize", http.StatusConflict}
+	}
+	if err := workers.Resize(size); err != nil {
+		return &httpError{err.Error(), http.StatusBadRequest}
+	}
+	return nil
+}
+
+// terminalError maps the errors of the terminal registry to the HTTP errors
+func terminalError(err error) *httpError {
+	switch {
+	case errors.Is(err, terminals.ErrInvalidTerminalID), errors.Is(err, terminals.ErrInvalidState):
+		return &httpError{err.Error(), http.StatusBadRequest}
+	case errors.Is(err, terminals.ErrTerminalNotFound):
+		return &httpError{err.Error(), http.StatusNotFound}
+	case errors.Is(err, terminals.ErrTerminalExists), errors.Is(err, terminals.ErrTerminalClosed), errors.Is(err, errTerminalInUse):
+		return &httpError{err.Error(), http.StatusConflict}
+	default:
+		return &httpError{err.Error(), http.StatusInternalServerError}
+	}
+}
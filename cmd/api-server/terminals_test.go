@@ -0,0 +1,88 @@
+package api_server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/azhovan/currywurst/internal/terminals"
+)
+
+func TestTerminals_State(t *testing.T) {
+	mux, _ := newTestMux(t, 10, []string{"terminal-0"})
+	body := `{"terminalId":"terminal-0","orderType":"vegan","insertedPrice":30}`
+
+	tests := []struct {
+		state      string
+		statusCode int
+	}{
+		// a terminal under maintenance takes orders again later, a closed one does not
+		{state: "maintenance", statusCode: http.StatusServiceUnavailable},
+		{state: "closed", statusCode: http.StatusGone},
+	}
+	for _, tt := range tests {
+		w := serve(mux, http.MethodPatch, "/admin/terminals/terminal-0", "4321", `{"state":"`+tt.state+`","reason":"coin acceptor jammed"}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("PATCH /admin/terminals/{id} to %s got status:%d, want:%d", tt.state, w.Code, http.StatusOK)
+		}
+
+		w = serve(mux, http.MethodPost, "/orders", "1234", body)
+		if w.Code != tt.statusCode {
+			t.Errorf("POST /orders to a terminal %s got status:%d, want:%d", tt.state, w.Code, tt.statusCode)
+		}
+		if retryAfter := w.Header().Get("Retry-After"); (tt.statusCode == http.StatusServiceUnavailable) != (retryAfter == "2") {
+			t.Errorf("POST /orders to a terminal %s got Retry-After:%q", tt.state, retryAfter)
+		}
+	}
+}
+
+func TestTerminals_Rename(t *testing.T) {
+	mux, registry := newTestMux(t, 10, []string{"terminal-0", "terminal-1"})
+
+	var accepted OrderAcceptedResponse
+	json.NewDecoder(serve(mux, http.MethodPost, "/orders", "1234", `{"terminalId":"terminal-0","orderType":"vegan","insertedPrice":30}`).Body).Decode(&accepted)
+
+	tests := []struct {
+		name       string
+		body       string
+		statusCode int
+	}{
+		// the order refers to the terminal by its id, the whole request is rejected
+		{"order attached", `{"terminalId":"terminal-9","state":"maintenance"}`, http.StatusConflict},
+		// the request is checked before the terminal is renamed
+		{"invalid workers", `{"terminalId":"terminal-9","workers":0}`, http.StatusBadRequest},
+		{"invalid state", `{"terminalId":"terminal-9","state":"broken"}`, http.StatusBadRequest},
+		{"id taken", `{"terminalId":"terminal-1","state":"maintenance"}`, http.StatusConflict},
+		{"invalid id", `{"terminalId":"terminal/9","state":"maintenance"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if w := serve(mux, http.MethodPatch, "/admin/terminals/terminal-0", "4321", tt.body); w.Code != tt.statusCode {
+			t.Errorf("PATCH /admin/terminals/{id} %s got status:%d, want:%d", tt.name, w.Code, tt.statusCode)
+		}
+		terminal, ok := registry.Get("terminal-0")
+		if !ok || terminal.Status().State != terminals.StateOpen {
+			t.Fatalf("PATCH /admin/terminals/{id} %s changed the terminal", tt.name)
+		}
+	}
+
+	// once the order is settled nothing is attached anymore
+	serve(mux, http.MethodDelete, "/orders/"+accepted.OrderId, "1234", "")
+	if w := serve(mux, http.MethodPatch, "/admin/terminals/terminal-0", "4321", `{"terminalId":"terminal-9","state":"maintenance"}`); w.Code != http.StatusOK {
+		t.Fatalf("PATCH /admin/terminals/{id} got status:%d, want:%d", w.Code, http.StatusOK)
+	}
+	if terminal, ok := registry.Get("terminal-9"); !ok || terminal.Status().State != terminals.StateMaintenance {
+		t.Errorf("PATCH /admin/terminals/{id} did not rename the terminal and switch its state")
+	}
+}
+
+func TestTerminals_AddResizeFails(t *testing.T) {
+	// the terminals of the test have no workers, so their pool can not be resized
+	mux, registry := newTestMux(t, 10, []string{"terminal-0"})
+
+	if w := serve(mux, http.MethodPost, "/admin/terminals", "4321", `{"terminalId":"drive-in","workers":2}`); w.Code != http.StatusConflict {
+		t.Errorf("POST /admin/terminals got status:%d, want:%d", w.Code, http.StatusConflict)
+	}
+	if _, ok := registry.Get("drive-in"); ok {
+		t.Errorf("POST /admin/terminals left the terminal registered")
+	}
+}
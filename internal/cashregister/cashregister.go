@@ -37,7 +37,9 @@ type ReturnedAmount struct {
 // it takes the price and the inserted amount of money as arguments, in cents
 func (cr *CashRegister) Pay(price, inserted int) (ReturnedAmount, error) {
 	// fast fail
-	if (price <= 0 || inserted <= 0) || inserted < price {
+	// a zero price is valid, i.e. an order that is fully covered by discounts,
+	// and it is the only price that can be paid without inserting any money
+	if price < 0 || inserted < 0 || (inserted == 0 && price > 0) || inserted < price {
 		return ReturnedAmount{}, ErrInvalidPayment
 	}
 
@@ -85,14 +87,29 @@ func (cr *CashRegister) Pay(price, inserted int) (ReturnedAmount, error) {
 	}, nil
 }
 
+// Refund returns the inserted money in full, i.e. when the order is cancelled before it is paid.
+// The customer gets back the very notes and coins they inserted, so the stock is not touched.
+func Refund(inserted int) ReturnedAmount {
+	if inserted <= 0 {
+		return ReturnedAmount{}
+	}
+	return ReturnedAmount{Cents: inserted, Formatted: centsToReadable(inserted)}
+}
+
 // stockToCentsAndReadable converts the given stock which is a map of stocks into cents and a human-readable format
 func stockToCentsAndReadable(stock map[int]int) (int, string) {
 	var cents int
-	var result strings.Builder
 	for denom, quantity := range stock {
 		cents += denom * quantity
 	}
 
+	return cents, centsToReadable(cents)
+}
+
+// centsToReadable converts the given amount of money in cents into a human-readable format
+func centsToReadable(cents int) string {
+	var result strings.Builder
+
 	euros := cents / 100
 	remainingCents := cents % 100
 
@@ -106,5 +123,5 @@ func stockToCentsAndReadable(stock map[int]int) (int, string) {
 		result.WriteString(fmt.Sprintf("%d Cent", remainingCents))
 	}
 
-	return cents, result.String()
+	return result.String()
 }
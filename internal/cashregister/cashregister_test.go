@@ -22,6 +22,25 @@ func TestStockToReadable(t *testing.T) {
 	}
 }
 
+func TestRefund(t *testing.T) {
+	tests := []struct {
+		inserted int
+		returned ReturnedAmount
+	}{
+		{inserted: 0, returned: ReturnedAmount{}},
+		{inserted: 50, returned: ReturnedAmount{Cents: 50, Formatted: "50 Cent"}},
+		{inserted: 250, returned: ReturnedAmount{Cents: 250, Formatted: "2 Euro and 50 Cent"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("inserted %d", tt.inserted), func(t *testing.T) {
+			if returned := Refund(tt.inserted); returned != tt.returned {
+				t.Errorf("Refund() got:%+v, want:%+v", returned, tt.returned)
+			}
+		})
+	}
+}
+
 func TestPay(t *testing.T) {
 	tests := []struct {
 		price, inserted int
@@ -29,9 +48,16 @@ func TestPay(t *testing.T) {
 		err             error
 	}{
 		{
+			// fully discounted order, paid without inserting any money
 			price:    0,
 			inserted: 0,
 			returned: ReturnedAmount{}, // empty
+			err:      nil,
+		},
+		{
+			price:    5,
+			inserted: 0,
+			returned: ReturnedAmount{},
 			err:      ErrInvalidPayment,
 		},
 		{
@@ -58,6 +84,13 @@ func TestPay(t *testing.T) {
 			returned: ReturnedAmount{Cents: 5, Formatted: "5 Cent"},
 			err:      nil,
 		},
+		{
+			// fully discounted order, the whole inserted amount is returned
+			price:    0,
+			inserted: 10,
+			returned: ReturnedAmount{Cents: 10, Formatted: "10 Cent"},
+			err:      nil,
+		},
 	}
 
 	cr := NewCashRegister()
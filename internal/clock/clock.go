@@ -0,0 +1,21 @@
+package clock
+
+import "time"
+
+// Clock tells the current time.
+// The packages that depend on the time take a Clock instead of calling time.Now,
+// so the time can be injected in tests.
+type Clock interface {
+	Now() time.Time
+}
+
+// Func is an adapter to allow the use of an ordinary function as a Clock.
+type Func func() time.Time
+
+// Now returns the time returned by f.
+func (f Func) Now() time.Time {
+	return f()
+}
+
+// System is the clock that tells the time of the operating system.
+var System Clock = Func(time.Now)
@@ -7,12 +7,60 @@
 // - cashregister: provides a CashRegister type that can calculate and return
 // the change for a given price and inserted amount of money.
 //
+// - clock: provides a Clock interface that tells the current time, so it can
+// be injected in tests.
+//
+// - events: provides a Broker type that fans out the transitions of the orders
+// to the subscribers, and keeps the recent events so a subscription can be resumed.
+//
+// - idempotency: provides a Store type that remembers the requests by their
+// idempotency keys, so a retried request is handled only once.
+//
+// - inventory: provides an Inventory type that keeps the stock of the ingredients
+// and the recipes of the products, and consumes the ingredients of the paid orders.
+//
+// - journal: provides a Journal type that appends the payments of the orders,
+// with their per-line breakdown, and the decisions of the crash recovery to an
+// append-only log of JSON lines.
+//
+// - liveness: provides a Monitor type that tracks the heartbeats of the kiosks
+// attached to the terminals, and degrades and pauses the terminals of the silent ones.
+//
 // - orders: provides an Order type that represents a currywurst order with
-// a cancellable context and a status channel.
+// a unique id, a cancellable context and a lifecycle state machine.
+//
+// - pricing: provides an Engine type that evaluates pricing rules, such as
+// combos, discounts and coupons, over the basket of an order before payment.
+//
+// - receipts: provides a Receipt type that represents the itemised receipt of
+// a paid order, and a Store type that keeps the receipts by order id.
+//
+// - recovery: resolves the orders that were left in flight when the server stopped,
+// by cancelling, refunding or re-queueing them, and records every decision.
+//
+// - routing: provides a Router type that chooses the terminal of an order,
+// by the length of the queues, round-robin or by consistent hashing of the customer.
+//
+// - schedule: provides a Schedule type that holds the opening hours, the holidays
+// and the time based price overrides of the stand, using an injectable clock.
+//
+// - store: provides the OrderStore interface that keeps the snapshots of the orders,
+// so they can be looked up after the customer's request is gone, with an in-memory
+// and a durable file backed implementation.
+//
+// - tax: computes the German VAT of an order per rate, for take-away and eat-in orders.
 //
 // - terminals: provides a Terminal type that represents a queue of orders
-// that customers can join and place their orders.
+// that customers can join and place their orders, and a Registry type that
+// adds, renames and removes the terminals at runtime. A terminal tracks how
+// long its orders take, and estimates the wait of the queued orders. It is
+// open, paused, under maintenance or closed.
+//
+// - websocket: implements the server side of the WebSocket protocol with the
+// standard library only.
 //
 // - workers: provides a worker type that can process orders from a terminal
-// and return change using a cash register.
+// and return change using a cash register, and a Pool type that runs a resizable
+// number of workers on the same terminal. Idle workers can steal orders from
+// the busiest terminal.
 package internal
@@ -0,0 +1,183 @@
+package events
+
+import (
+	"sync"
+
+	"github.com/azhovan/currywurst/internal/orders"
+)
+
+// Define the default sizes of the broker
+const (
+	DefaultHistorySize = 1024 // The number of events kept to resume the subscriptions
+	DefaultBufferSize  = 64   // The number of events a subscriber can fall behind before it is dropped
+)
+
+// Event is a transition of an order, along with a snapshot of the order right after it.
+type Event struct {
+	ID       uint64 // The sequence number of the event, it increases with every event published by the broker
+	Snapshot orders.Snapshot
+}
+
+// Filter reports whether a subscriber is interested in the given snapshot.
+type Filter func(orders.Snapshot) bool
+
+// ForOrder returns a filter that matches the events of the order with the given id.
+func ForOrder(id string) Filter {
+	return func(s orders.Snapshot) bool {
+		return s.ID == id
+	}
+}
+
+// ForTerminal returns a filter that matches the events of the orders sent to the terminal with the given id.
+func ForTerminal(id string) Filter {
+	return func(s orders.Snapshot) bool {
+		return s.TerminalID == id
+	}
+}
+
+// Broker fans out the transitions of the orders to the subscribers.
+// It keeps the most recent events, so a subscriber that lost its connection can resume
+// from the last event it received. It is safe for concurrent use.
+type Broker struct {
+	mu          sync.Mutex
+	seq         uint64
+	history     []Event
+	historySize int
+	bufferSize  int
+	subscribers map[*Subscription]struct{}
+}
+
+// Option is a function that modifies the broker
+type Option func(*Broker)
+
+// WithHistorySize sets the number of events kept to resume the subscriptions
+func WithHistorySize(size int) Option {
+	return func(b *Broker) {
+		b.historySize = size
+	}
+}
+
+// WithBufferSize sets the number of events a subscriber can fall behind before it is dropped
+func WithBufferSize(size int) Option {
+	return func(b *Broker) {
+		b.bufferSize = size
+	}
+}
+
+// NewBroker returns a new broker with the given options.
+func NewBroker(opts ...Option) *Broker {
+	b := &Broker{
+		historySize: DefaultHistorySize,
+		bufferSize:  DefaultBufferSize,
+		subscribers: map[*Subscription]struct{}{},
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Track publishes an event after every transition of the order.
+func (b *Broker) Track(order *orders.Order) {
+	order.Observe(b.Publish)
+}
+
+// Publish sends an event with the given snapshot to the interested subscribers.
+// It never blocks, a subscriber that fell too far behind is dropped and its channel is closed,
+// so it can resume from the last event it received.
+func (b *Broker) Publish(snapshot orders.Snapshot) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	event := Event{ID: b.seq, Snapshot: snapshot}
+
+	b.history = append(b.history, event)
+	if len(b.history) > b.historySize {
+		b.history = b.history[len(b.history)-b.historySize:]
+	}
+
+	for sub := range b.subscribers {
+		if !sub.filter(snapshot) {
+			continue
+		}
+		select {
+		case sub.c <- event:
+		default:
+			b.drop(sub)
+		}
+	}
+}
+
+// Subscribe returns a subscription to the events that match the filter and are published from now on.
+func (b *Broker) Subscribe(filter Filter) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.subscribe(filter, b.seq)
+}
+
+// SubscribeSince returns a subscription to the events that match the filter and have an id greater than
+// the given one. The past events that are still kept by the broker are listed in the Replay field of the
+// subscription, the events that are published from now on are sent to its channel.
+func (b *Broker) SubscribeSince(filter Filter, since uint64) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.subscribe(filter, since)
+}
+
+// subscribe registers a new subscription, the caller must hold the lock
+func (b *Broker) subscribe(filter Filter, since uint64) *Subscription {
+	sub := &Subscription{
+		broker: b,
+		filter: filter,
+		c:      make(chan Event, b.bufferSize),
+	}
+	for _, event := range b.history {
+		if event.ID > since && filter(event.Snapshot) {
+			sub.Replay = append(sub.Replay, event)
+		}
+	}
+	b.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// drop removes the subscription and closes its channel, the caller must hold the lock
+func (b *Broker) drop(sub *Subscription) {
+	if _, ok := b.subscribers[sub]; !ok {
+		return
+	}
+	delete(b.subscribers, sub)
+	close(sub.c)
+}
+
+// Close drops all the subscriptions, i.e. when the server shuts down.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subscribers {
+		b.drop(sub)
+	}
+}
+
+// Subscription is a stream of the events that match a filter.
+type Subscription struct {
+	broker *Broker
+	filter Filter
+	c      chan Event
+
+	// Replay lists the past events the subscription resumed from, oldest first.
+	Replay []Event
+}
+
+// Events returns the channel the events are sent to. The channel is closed once the subscription is closed,
+// or when the subscriber fell too far behind.
+func (s *Subscription) Events() <-chan Event {
+	return s.c
+}
+
+// Close stops the subscription, it is safe to call it more than once.
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.drop(s)
+}
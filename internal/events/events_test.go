@@ -0,0 +1,106 @@
+package events
+
+import (
+	"context"
+	"testing"
+
+	"github.com/azhovan/currywurst/internal/orders"
+)
+
+func TestBroker_Subscribe(t *testing.T) {
+	b := NewBroker()
+
+	order := orders.NewOrder(context.TODO(), 50, orders.Vegan)
+	order.TerminalID = "terminal-1"
+	other := orders.NewOrder(context.TODO(), 50, orders.Vegan)
+	other.TerminalID = "terminal-2"
+	b.Track(order)
+	b.Track(other)
+
+	sub := b.Subscribe(ForOrder(order.ID))
+	defer sub.Close()
+
+	other.Transition(orders.StateQueued)
+	order.Transition(orders.StateQueued)
+
+	event := <-sub.Events()
+	if event.Snapshot.ID != order.ID || event.Snapshot.State != orders.StateQueued {
+		t.Errorf("got event of order %s in state %s, want order %s in state %s", event.Snapshot.ID, event.Snapshot.State, order.ID, orders.StateQueued)
+	}
+	if event.ID != 2 {
+		t.Errorf("got event id:%d, want:%d", event.ID, 2)
+	}
+	if len(sub.Replay) != 0 {
+		t.Errorf("got %d replayed events, want:%d", len(sub.Replay), 0)
+	}
+}
+
+func TestBroker_SubscribeSince(t *testing.T) {
+	b := NewBroker(WithHistorySize(3))
+
+	order := orders.NewOrder(context.TODO(), 50, orders.Vegan)
+	order.TerminalID = "terminal-1"
+	b.Track(order)
+
+	order.Transition(orders.StateQueued)     // 1
+	order.Transition(orders.StateValidating) // 2
+	order.Transition(orders.StatePaying)     // 3
+	order.Fail(orders.ErrOrderTimeout)       // 4
+
+	tests := []struct {
+		name  string
+		since uint64
+		want  []uint64
+	}{
+		{"resume after the last event", 4, nil},
+		{"resume after an event", 2, []uint64{3, 4}},
+		{"resume after an event that is no longer kept", 0, []uint64{2, 3, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := b.SubscribeSince(ForTerminal("terminal-1"), tt.since)
+			defer sub.Close()
+
+			if len(sub.Replay) != len(tt.want) {
+				t.Fatalf("got %d replayed events, want:%d", len(sub.Replay), len(tt.want))
+			}
+			for i, event := range sub.Replay {
+				if event.ID != tt.want[i] {
+					t.Errorf("got replayed event id:%d, want:%d", event.ID, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBroker_DropSlowSubscriber(t *testing.T) {
+	b := NewBroker(WithBufferSize(1))
+
+	order := orders.NewOrder(context.TODO(), 50, orders.Vegan)
+	b.Track(order)
+
+	sub := b.Subscribe(ForOrder(order.ID))
+	order.Transition(orders.StateQueued)
+	order.Transition(orders.StateValidating)
+
+	if event, ok := <-sub.Events(); !ok || event.Snapshot.State != orders.StateQueued {
+		t.Fatalf("got event in state %s, want:%s", event.Snapshot.State, orders.StateQueued)
+	}
+	if _, ok := <-sub.Events(); ok {
+		t.Errorf("the channel of a slow subscriber is not closed")
+	}
+
+	// closing a dropped subscription is a no-op
+	sub.Close()
+}
+
+func TestBroker_Close(t *testing.T) {
+	b := NewBroker()
+	sub := b.Subscribe(ForTerminal("terminal-1"))
+
+	b.Close()
+	if _, ok := <-sub.Events(); ok {
+		t.Errorf("the channel of the subscription is not closed")
+	}
+}
@@ -0,0 +1,181 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/azhovan/currywurst/internal/clock"
+)
+
+// DefaultRetention is the time the result of a request is kept by default, once it is complete.
+const DefaultRetention = 24 * time.Hour
+
+// DefaultInProgressTTL is the time a request in progress keeps its key by default, i.e. when the request
+// never completes because the handler panicked. It outlasts the longest time an order takes to be ready.
+const DefaultInProgressTTL = 15 * time.Minute
+
+// sweepInterval is the time between two sweeps of the expired keys
+const sweepInterval = time.Minute
+
+// ErrKeyReused is the error returned when a key is used again with a different request.
+var ErrKeyReused = errors.New("idempotency key reused with a different request")
+
+// Response is the response of a request, as it was written to the client.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Entry is the state of the request that first used a key.
+type Entry struct {
+	fingerprint string
+
+	mu        sync.Mutex
+	orderID   string
+	response  *Response
+	expiresAt time.Time
+}
+
+// SetOrderID records the id of the order the request placed, so a repeated request
+// can be pointed to the order while it is in progress.
+func (e *Entry) SetOrderID(id string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.orderID = id
+}
+
+// OrderID returns the id of the order the request placed, it is empty until the order is placed.
+func (e *Entry) OrderID() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.orderID
+}
+
+// Response returns the response of the request, once the request is complete.
+func (e *Entry) Response() (Response, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.response == nil {
+		return Response{}, false
+	}
+	return *e.response, true
+}
+
+// expired checks if the key of the entry is expired at the given time
+func (e *Entry) expired(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return !now.Before(e.expiresAt)
+}
+
+// Store keeps the requests by their idempotency keys. The key of a complete request expires after
+// the retention of the store, the key of a request in progress after its TTL. It is safe for concurrent use.
+type Store struct {
+	mu         sync.Mutex
+	clock      clock.Clock
+	retention  time.Duration
+	inProgress time.Duration
+	entries    map[string]*Entry
+	nextSweep  time.Time
+}
+
+// Option is a function that modifies the store
+type Option func(*Store)
+
+// WithInProgressTTL sets the time a request in progress keeps its key
+func WithInProgressTTL(ttl time.Duration) Option {
+	return func(s *Store) {
+		s.inProgress = ttl
+	}
+}
+
+// NewStore returns a new empty store, that keeps the result of a request for the given retention.
+func NewStore(clock clock.Clock, retention time.Duration, opts ...Option) *Store {
+	s := &Store{
+		clock:      clock,
+		retention:  retention,
+		inProgress: DefaultInProgressTTL,
+		entries:    map[string]*Entry{},
+	}
+
+	// apply the options
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Begin looks up the given key. If the key is new, or expired, it returns a new entry and true,
+// and the caller is expected to handle the request and then Complete or Release the entry.
+// Otherwise it returns the entry of the first request and false, or ErrKeyReused
+// if the fingerprint of the first request is different.
+func (s *Store) Begin(key, fingerprint string) (*Entry, bool, error) {
+	now := s.clock.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// the key is expired on its own, the other keys are swept once in a while
+	if !now.Before(s.nextSweep) {
+		s.sweep(now)
+		s.nextSweep = now.Add(sweepInterval)
+	}
+
+	if entry, ok := s.entries[key]; ok && !entry.expired(now) {
+		if entry.fingerprint != fingerprint {
+			return nil, false, ErrKeyReused
+		}
+		return entry, false, nil
+	}
+
+	entry := &Entry{fingerprint: fingerprint, expiresAt: now.Add(s.inProgress)}
+	s.entries[key] = entry
+	return entry, true, nil
+}
+
+// Complete records the response of the request of the entry, the key expires after the retention of the store.
+func (s *Store) Complete(entry *Entry, response Response) {
+	now := s.clock.Now()
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	entry.response = &response
+	entry.expiresAt = now.Add(s.retention)
+}
+
+// Release forgets the key of the entry, i.e. when the request failed on the server side,
+// so the client can try again with the same key.
+func (s *Store) Release(key string, entry *Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.entries[key] == entry {
+		delete(s.entries, key)
+	}
+}
+
+// sweep forgets the expired keys, the caller must hold the lock
+func (s *Store) sweep(now time.Time) {
+	for key, entry := range s.entries {
+		if entry.expired(now) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+// contextKey is the type of the key of the entry in a context
+type contextKey struct{}
+
+// NewContext returns a copy of the context that carries the entry of the request.
+func NewContext(ctx context.Context, entry *Entry) context.Context {
+	return context.WithValue(ctx, contextKey{}, entry)
+}
+
+// FromContext returns the entry of the request carried by the context, if any.
+func FromContext(ctx context.Context) (*Entry, bool) {
+	entry, ok := ctx.Value(contextKey{}).(*Entry)
+	return entry, ok
+}
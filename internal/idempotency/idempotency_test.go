@@ -0,0 +1,115 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/azhovan/currywurst/internal/clock"
+)
+
+func TestStore_Begin(t *testing.T) {
+	s := NewStore(clock.System, time.Hour)
+
+	entry, first, err := s.Begin("key", "body")
+	if err != nil || !first {
+		t.Fatalf("store.Begin() got first:%t error:%v, want first:true error:nil", first, err)
+	}
+
+	// the request is in progress
+	entry.SetOrderID("order-1")
+	repeated, first, err := s.Begin("key", "body")
+	if err != nil || first || repeated != entry {
+		t.Fatalf("store.Begin() got first:%t error:%v, want the entry of the first request", first, err)
+	}
+	if _, ok := repeated.Response(); ok {
+		t.Errorf("entry.Response() got a response of a request in progress")
+	}
+	if repeated.OrderID() != "order-1" {
+		t.Errorf("entry.OrderID() got:%s, want:%s", repeated.OrderID(), "order-1")
+	}
+
+	// the same key with a different request
+	if _, _, err = s.Begin("key", "other body"); !errors.Is(err, ErrKeyReused) {
+		t.Errorf("store.Begin() got error:%v, want:%v", err, ErrKeyReused)
+	}
+
+	// the request is complete
+	s.Complete(entry, Response{StatusCode: http.StatusOK, Body: []byte("{}")})
+	repeated, _, _ = s.Begin("key", "body")
+	if response, ok := repeated.Response(); !ok || response.StatusCode != http.StatusOK {
+		t.Errorf("entry.Response() got:%+v, want the response of the first request", response)
+	}
+}
+
+func TestStore_Expire(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	c := clock.Func(func() time.Time { return now })
+	s := NewStore(c, time.Hour)
+
+	entry, _, _ := s.Begin("key", "body")
+	now = now.Add(DefaultInProgressTTL - time.Second)
+
+	// a request in progress keeps its key until its TTL
+	if _, first, _ := s.Begin("key", "body"); first {
+		t.Fatalf("store.Begin() forgot the key of a request in progress")
+	}
+
+	s.Complete(entry, Response{StatusCode: http.StatusOK})
+	now = now.Add(59 * time.Minute)
+	if _, first, _ := s.Begin("key", "body"); first {
+		t.Fatalf("store.Begin() forgot the key before the retention")
+	}
+
+	now = now.Add(time.Minute)
+	if _, first, err := s.Begin("key", "other body"); !first || err != nil {
+		t.Errorf("store.Begin() got first:%t error:%v, want the key to be expired", first, err)
+	}
+}
+
+func TestStore_ExpireInProgress(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	c := clock.Func(func() time.Time { return now })
+	s := NewStore(c, time.Hour, WithInProgressTTL(time.Minute))
+
+	// the request never completes, e.g. its handler panicked
+	s.Begin("key", "body")
+	s.Begin("other", "body")
+	now = now.Add(time.Minute)
+
+	if _, first, err := s.Begin("key", "other body"); !first || err != nil {
+		t.Errorf("store.Begin() got first:%t error:%v, want the key of the request in progress to be expired", first, err)
+	}
+
+	// the other key is swept even though it is not looked up
+	s.mu.Lock()
+	_, ok := s.entries["other"]
+	s.mu.Unlock()
+	if ok {
+		t.Errorf("store.Begin() kept the expired key of another request")
+	}
+}
+
+func TestStore_Release(t *testing.T) {
+	s := NewStore(clock.System, time.Hour)
+
+	entry, _, _ := s.Begin("key", "body")
+	s.Release("key", entry)
+
+	if _, first, _ := s.Begin("key", "body"); !first {
+		t.Errorf("store.Begin() kept the key of a released request")
+	}
+}
+
+func TestContext(t *testing.T) {
+	if _, ok := FromContext(context.Background()); ok {
+		t.Errorf("FromContext() found an entry in an empty context")
+	}
+
+	entry := &Entry{}
+	if got, ok := FromContext(NewContext(context.Background(), entry)); !ok || got != entry {
+		t.Errorf("FromContext() got:%v, want:%v", got, entry)
+	}
+}
@@ -0,0 +1,162 @@
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/azhovan/currywurst/internal/orders"
+)
+
+var (
+	// ErrOutOfStock is the error returned when there are not enough ingredients to make an order.
+	ErrOutOfStock = errors.New("sold out")
+
+	// ErrInvalidAmount is the error returned when an ingredient is restocked with a negative amount.
+	ErrInvalidAmount = errors.New("invalid ingredient amount")
+)
+
+// Ingredient is a custom type that represents an ingredient of the products
+type Ingredient string
+
+// Define the ingredients of the products
+const (
+	VeganSausage Ingredient = "vegan-sausage"
+	Sausage      Ingredient = "sausage"
+	Sauce        Ingredient = "sauce"
+	FriesPortion Ingredient = "fries"
+	DrinkBottle  Ingredient = "drink"
+)
+
+// Recipe is the list of ingredients, and their amounts, that are needed to make a single unit of a product.
+type Recipe map[Ingredient]int
+
+// DefaultRecipes returns the recipes of the products on the menu.
+func DefaultRecipes() map[orders.OrderType]Recipe {
+	return map[orders.OrderType]Recipe{
+		orders.Vegan:    {VeganSausage: 1, Sauce: 1},
+		orders.NonVegan: {Sausage: 1, Sauce: 1},
+		orders.Fries:    {FriesPortion: 1},
+		orders.Drink:    {DrinkBottle: 1},
+	}
+}
+
+// Inventory keeps the stock of the ingredients and the recipes of the products.
+// It is safe for concurrent use, the ingredients of an order are consumed atomically,
+// so two orders can never consume the same ingredients.
+// Products without a recipe do not need any ingredient and are always available.
+type Inventory struct {
+	mu      sync.Mutex
+	stock   map[Ingredient]int
+	recipes map[orders.OrderType]Recipe
+}
+
+// NewInventory returns a new inventory with the given recipes and initial stock.
+func NewInventory(recipes map[orders.OrderType]Recipe, stock map[Ingredient]int) *Inventory {
+	inv := &Inventory{
+		stock:   make(map[Ingredient]int, len(stock)),
+		recipes: recipes,
+	}
+	for ingredient, amount := range stock {
+		inv.stock[ingredient] = amount
+	}
+	return inv
+}
+
+// Consume takes the ingredients of the given items out of the stock.
+// Either all the ingredients are consumed or none of them, if any ingredient is
+// missing it returns an error that wraps ErrOutOfStock.
+func (inv *Inventory) Consume(items []orders.Item) error {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	needed, err := inv.needed(items)
+	if err != nil {
+		return err
+	}
+	for ingredient, amount := range needed {
+		inv.stock[ingredient] -= amount
+	}
+	return nil
+}
+
+// CanMake checks whether the given items, in their quantities, can be made with the current stock,
+// without consuming anything. If any ingredient is missing it returns an error that wraps ErrOutOfStock.
+// The stock may change before the items are consumed, so Consume may still fail afterwards.
+func (inv *Inventory) CanMake(items []orders.Item) error {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	_, err := inv.needed(items)
+	return err
+}
+
+// needed returns the ingredients the given items take, the recipes times the quantities, or an error that wraps
+// ErrOutOfStock naming the first item the stock falls short for. The caller must hold the lock.
+func (inv *Inventory) needed(items []orders.Item) (map[Ingredient]int, error) {
+	needed := map[Ingredient]int{}
+	for _, item := range items {
+		for ingredient, amount := range inv.recipes[item.OrderType] {
+			needed[ingredient] += amount * item.Quantity
+			if needed[ingredient] > inv.stock[ingredient] {
+				return nil, fmt.Errorf("%s is %w", item.OrderType, ErrOutOfStock)
+			}
+		}
+	}
+	return needed, nil
+}
+
+// Release gives the ingredients of the given items back to the stock,
+// i.e. when the payment of an order fails after its ingredients were consumed.
+func (inv *Inventory) Release(items []orders.Item) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	for _, item := range items {
+		for ingredient, amount := range inv.recipes[item.OrderType] {
+			inv.stock[ingredient] += amount * item.Quantity
+		}
+	}
+}
+
+// Restock adds the given amounts of ingredients to the stock.
+// It returns ErrInvalidAmount, without changing the stock, if any amount is negative.
+func (inv *Inventory) Restock(amounts map[Ingredient]int) error {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	for _, amount := range amounts {
+		if amount < 0 {
+			return ErrInvalidAmount
+		}
+	}
+	for ingredient, amount := range amounts {
+		inv.stock[ingredient] += amount
+	}
+	return nil
+}
+
+// Available reports whether at least one unit of the given product can be made with the current stock.
+func (inv *Inventory) Available(product orders.OrderType) bool {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	for ingredient, amount := range inv.recipes[product] {
+		if inv.stock[ingredient] < amount {
+			return false
+		}
+	}
+	return true
+}
+
+// Stock returns a copy of the current stock of the ingredients.
+func (inv *Inventory) Stock() map[Ingredient]int {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	stock := make(map[Ingredient]int, len(inv.stock))
+	for ingredient, amount := range inv.stock {
+		stock[ingredient] = amount
+	}
+	return stock
+}
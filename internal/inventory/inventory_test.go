@@ -0,0 +1,121 @@
+package inventory
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/azhovan/currywurst/internal/orders"
+)
+
+func TestInventory_Consume(t *testing.T) {
+	inv := NewInventory(DefaultRecipes(), map[Ingredient]int{VeganSausage: 2, Sausage: 1, Sauce: 2})
+
+	// there is not enough sauce for the whole basket, so nothing is consumed
+	err := inv.Consume([]orders.Item{
+		{OrderType: orders.Vegan, Quantity: 2},
+		{OrderType: orders.NonVegan, Quantity: 1},
+	})
+	if !errors.Is(err, ErrOutOfStock) {
+		t.Fatalf("inventory.Consume() got error:%v, want:%v", err, ErrOutOfStock)
+	}
+	want := map[Ingredient]int{VeganSausage: 2, Sausage: 1, Sauce: 2}
+	if got := inv.Stock(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("inventory.Stock() got:%v, want:%v", got, want)
+	}
+
+	err = inv.Consume([]orders.Item{{OrderType: orders.Vegan, Quantity: 2}})
+	if err != nil {
+		t.Fatalf("inventory.Consume() got error:%v, want nil", err)
+	}
+	want = map[Ingredient]int{VeganSausage: 0, Sausage: 1, Sauce: 0}
+	if got := inv.Stock(); !reflect.DeepEqual(got, want) {
+		t.Errorf("inventory.Stock() got:%v, want:%v", got, want)
+	}
+}
+
+func TestInventory_Available(t *testing.T) {
+	inv := NewInventory(DefaultRecipes(), map[Ingredient]int{VeganSausage: 1, Sauce: 1})
+
+	if !inv.Available(orders.Vegan) {
+		t.Errorf("inventory.Available(%s) got false, want true", orders.Vegan)
+	}
+	if inv.Available(orders.NonVegan) {
+		t.Errorf("inventory.Available(%s) got true, want false", orders.NonVegan)
+	}
+
+	// the vegan currywurst is sold out once its ingredients are consumed
+	if err := inv.Consume([]orders.Item{{OrderType: orders.Vegan, Quantity: 1}}); err != nil {
+		t.Fatalf("inventory.Consume() got error:%v, want nil", err)
+	}
+	if inv.Available(orders.Vegan) {
+		t.Errorf("inventory.Available(%s) got true, want false", orders.Vegan)
+	}
+
+	// and it is available again once it is restocked
+	if err := inv.Restock(map[Ingredient]int{VeganSausage: 10, Sauce: 10}); err != nil {
+		t.Fatalf("inventory.Restock() got error:%v, want nil", err)
+	}
+	if !inv.Available(orders.Vegan) {
+		t.Errorf("inventory.Available(%s) got false, want true", orders.Vegan)
+	}
+}
+
+func TestInventory_Restock(t *testing.T) {
+	inv := NewInventory(DefaultRecipes(), map[Ingredient]int{Sauce: 1})
+
+	err := inv.Restock(map[Ingredient]int{Sauce: 5, Sausage: -1})
+	if !errors.Is(err, ErrInvalidAmount) {
+		t.Fatalf("inventory.Restock() got error:%v, want:%v", err, ErrInvalidAmount)
+	}
+	if got := inv.Stock()[Sauce]; got != 1 {
+		t.Errorf("inventory.Stock() got sauce:%d, want:%d", got, 1)
+	}
+}
+
+func TestInventory_ConcurrentConsume(t *testing.T) {
+	inv := NewInventory(DefaultRecipes(), map[Ingredient]int{Sausage: 10, Sauce: 10})
+
+	var mu sync.Mutex
+	var consumed int
+	wg := sync.WaitGroup{}
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if inv.Consume([]orders.Item{{OrderType: orders.NonVegan, Quantity: 1}}) == nil {
+				mu.Lock()
+				consumed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if consumed != 10 {
+		t.Errorf("got %d consumed orders, want:%d", consumed, 10)
+	}
+	if got := inv.Stock()[Sausage]; got != 0 {
+		t.Errorf("inventory.Stock() got sausage:%d, want:%d", got, 0)
+	}
+}
+
+func TestInventory_CanMake(t *testing.T) {
+	inv := NewInventory(DefaultRecipes(), map[Ingredient]int{VeganSausage: 2, Sauce: 5})
+
+	if err := inv.CanMake([]orders.Item{{OrderType: orders.Vegan, Quantity: 2}}); err != nil {
+		t.Errorf("inventory.CanMake() got error:%v, want nil", err)
+	}
+
+	// the quantities of the basket add up, one sausage short of the lines
+	items := []orders.Item{{OrderType: orders.Vegan, Quantity: 2}, {OrderType: orders.Vegan, Quantity: 1}}
+	if err := inv.CanMake(items); !errors.Is(err, ErrOutOfStock) {
+		t.Errorf("inventory.CanMake() got error:%v, want:%v", err, ErrOutOfStock)
+	}
+
+	// nothing is consumed by the check
+	if stock := inv.Stock(); stock[VeganSausage] != 2 || stock[Sauce] != 5 {
+		t.Errorf("inventory.Stock() got:%v, want the stock unchanged", stock)
+	}
+}
@@ -0,0 +1,161 @@
+package journal
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/azhovan/currywurst/internal/orders"
+)
+
+// Kind is the kind of an entry of the journal.
+type Kind string
+
+// Define the kinds of the entries
+const (
+	KindPayment  Kind = "payment"  // An order was paid, the entry has the per-line breakdown of the order
+	KindRecovery Kind = "recovery" // An order left in flight when the server stopped was resolved on start
+)
+
+// Entry is a line of the journal, it is written as a line of JSON.
+type Entry struct {
+	At         time.Time  `json:"at"`
+	Kind       Kind       `json:"kind"`
+	OrderID    string     `json:"orderId"`
+	TerminalID string     `json:"terminalId,omitempty"`
+	Lines      []Line     `json:"lines,omitempty"`
+	Discounts  []Discount `json:"discounts,omitempty"`
+	Subtotal   int        `json:"subtotal"`         // The price of the lines in cents, before the discounts
+	Total      int        `json:"total"`            // The price of the order in cents, after the discounts
+	Inserted   int        `json:"inserted"`         // The money inserted by the customer in cents
+	Returned   int        `json:"returned"`         // The money given back to the customer in cents
+	From       string     `json:"from,omitempty"`   // The state a recovered order was left in
+	To         string     `json:"to,omitempty"`     // The state a recovered order is moved to
+	Reason     string     `json:"reason,omitempty"` // The reason a recovered order is moved
+}
+
+// Line is a single line of the order of an entry.
+type Line struct {
+	OrderType string `json:"orderType"`
+	Quantity  int    `json:"quantity"`
+	UnitPrice int    `json:"unitPrice"`
+	Total     int    `json:"total"`
+}
+
+// Discount is a discount granted to the order of an entry.
+type Discount struct {
+	Rule   string `json:"rule"`
+	Amount int    `json:"amount"`
+}
+
+// Payment returns the entry of the paid order of the given snapshot, recorded at the given time.
+func Payment(s orders.Snapshot, at time.Time) Entry {
+	return newEntry(KindPayment, s, at)
+}
+
+// Track records the payment of the order in the journal as soon as the order is paid, i.e. once it is preparing,
+// at the time of that transition. It does not wait for the customer to get a receipt, so an order whose customer is
+// gone is accounted for as well. The order is paid whether or not the journal takes it, an entry that can not be
+// recorded is logged with the given logger. The worker that paid the order waits for the entry to be on the disk.
+func Track(j *Journal, order *orders.Order, logger *slog.Logger) {
+	order.Observe(func(s orders.Snapshot) {
+		if s.State != orders.StatePreparing {
+			return
+		}
+		if err := j.Record(Payment(s, s.UpdatedAt())); err != nil {
+			logger.Error("payment not recorded in the journal", slog.String("order_id", s.ID), slog.Any("err", err))
+		}
+	})
+}
+
+// Recovery returns the entry of the decision taken for an order that was left in the given state when the server
+// stopped, recorded at the given time. The snapshot is the order once it is recovered, the money given back
+// to the customer is its refund.
+func Recovery(s orders.Snapshot, from orders.State, reason string, at time.Time) Entry {
+	e := newEntry(KindRecovery, s, at)
+	e.From = from.String()
+	e.To = s.State.String()
+	e.Reason = reason
+	return e
+}
+
+// newEntry returns an entry of the given kind for the order of the snapshot, with the per-line breakdown of the order
+func newEntry(kind Kind, s orders.Snapshot, at time.Time) Entry {
+	e := Entry{
+		At:         at,
+		Kind:       kind,
+		OrderID:    s.ID,
+		TerminalID: s.TerminalID,
+		Lines:      make([]Line, 0, len(s.Items)),
+		Subtotal:   s.Subtotal,
+		Total:      s.Total,
+		Inserted:   s.Inserted,
+		Returned:   s.Returned.Cents,
+	}
+	for _, item := range s.Items {
+		e.Lines = append(e.Lines, Line{
+			OrderType: item.OrderType.String(),
+			Quantity:  item.Quantity,
+			UnitPrice: item.UnitPrice,
+			Total:     item.Total(),
+		})
+	}
+	for _, discount := range s.Discounts {
+		e.Discounts = append(e.Discounts, Discount{Rule: discount.Rule, Amount: discount.Amount})
+	}
+	return e
+}
+
+// Journal is an append-only record of the money the stand took and gave back, and of the orders resolved after
+// a restart, one entry per line of JSON.
+// Unlike the order store, which keeps the latest snapshot of every order, the journal is never rewritten,
+// so it can be handed to the accountant as it is. It is safe for concurrent use.
+type Journal struct {
+	mu   sync.Mutex
+	w    io.Writer
+	file *os.File // the file the journal is written to, it is synced after every entry
+}
+
+// New returns a journal that writes its entries to the given writer.
+func New(w io.Writer) *Journal {
+	return &Journal{w: w}
+}
+
+// Open opens the journal in the file at the given path, the entries are appended to the file.
+func Open(path string) (*Journal, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &Journal{w: file, file: file}, nil
+}
+
+// Record appends the entry to the journal, it returns once the entry is on the disk.
+func (j *Journal) Record(e Entry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if _, err = j.w.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if j.file != nil {
+		return j.file.Sync()
+	}
+	return nil
+}
+
+// Close closes the file of the journal, if it has one.
+func (j *Journal) Close() error {
+	if j.file == nil {
+		return nil
+	}
+	return j.file.Close()
+}
@@ -0,0 +1,91 @@
+package journal
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/azhovan/currywurst/internal/cashregister"
+	"github.com/azhovan/currywurst/internal/orders"
+)
+
+func TestJournal_Payment(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.log")
+	j, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() got error:%v, want nil", err)
+	}
+
+	order := orders.NewBasketOrder(context.TODO(), 100, []orders.Item{
+		{OrderType: orders.Vegan, Quantity: 2},
+		{OrderType: orders.Drink, Quantity: 1},
+	})
+	order.Discounts = []orders.Discount{{Rule: "second vegan half price", Amount: 15}}
+	order.Returned = cashregister.ReturnedAmount{Cents: 35, Formatted: "35 Cent"}
+
+	at := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 2; i++ {
+		if err := j.Record(Payment(order.Snapshot(), at)); err != nil {
+			t.Fatalf("journal.Record() got error:%v, want nil", err)
+		}
+	}
+	j.Close()
+
+	// the entries are appended, one per line
+	file, _ := os.Open(path)
+	defer file.Close()
+	var entries []Entry
+	for scanner := bufio.NewScanner(file); scanner.Scan(); {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("json.Unmarshal() got error:%v, want nil", err)
+		}
+		entries = append(entries, e)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("the journal holds %d entries, want:%d", len(entries), 2)
+	}
+
+	e := entries[0]
+	if e.Kind != KindPayment || e.OrderID != order.ID || !e.At.Equal(at) {
+		t.Errorf("entry got kind:%s order:%s at:%v, want:%s %s %v", e.Kind, e.OrderID, e.At, KindPayment, order.ID, at)
+	}
+	if len(e.Lines) != 2 || e.Lines[0] != (Line{OrderType: "vegan", Quantity: 2, UnitPrice: 30, Total: 60}) {
+		t.Errorf("entry got lines:%+v, want the per-line breakdown of the order", e.Lines)
+	}
+	if e.Subtotal != 80 || e.Total != 65 || e.Inserted != 100 || e.Returned != 35 {
+		t.Errorf("entry got subtotal:%d total:%d inserted:%d returned:%d, want:80 65 100 35", e.Subtotal, e.Total, e.Inserted, e.Returned)
+	}
+}
+
+func TestTrack(t *testing.T) {
+	var buf bytes.Buffer
+	order := orders.NewOrder(context.TODO(), 50, orders.Vegan)
+	Track(New(&buf), order, slog.Default())
+
+	order.Transition(orders.StateQueued)
+	order.Transition(orders.StateValidating)
+	order.Transition(orders.StatePaying)
+	if buf.Len() != 0 {
+		t.Fatalf("the journal got %q before the order is paid, want nothing", buf.String())
+	}
+
+	// the payment is recorded once the order is paid, at the time it was paid
+	order.Paid(cashregister.ReturnedAmount{Cents: 20, Formatted: "20 Cent"})
+	order.Transition(orders.StateReady)
+	order.Collect()
+
+	var e Entry
+	if err := json.Unmarshal(buf.Bytes(), &e); err != nil {
+		t.Fatalf("the journal got %q, want a single payment", buf.String())
+	}
+	if e.Kind != KindPayment || e.OrderID != order.ID || e.Returned != 20 || !e.At.Equal(order.Snapshot().History[4].At) {
+		t.Errorf("entry got %+v, want the payment of the order at the time it was paid", e)
+	}
+}
@@ -0,0 +1,314 @@
+package liveness
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/azhovan/currywurst/internal/clock"
+	"github.com/azhovan/currywurst/internal/terminals"
+)
+
+// DefaultMissed is the number of heartbeats a kiosk can miss by default, before its terminal is degraded.
+const DefaultMissed = 3
+
+// ReasonMissedHeartbeats is the reason a terminal is paused with, when its kiosk stopped sending heartbeats.
+const ReasonMissedHeartbeats = "kiosk missed heartbeats"
+
+// ErrInvalidHardware is the error returned when a heartbeat reports an unknown level of a supply.
+var ErrInvalidHardware = errors.New("invalid hardware status")
+
+// Level is the level of a supply of a kiosk, i.e. the paper of the printer or the coins of a tube.
+type Level string
+
+// Define the levels of a supply
+const (
+	LevelOK    Level = "ok"    // There is plenty left
+	LevelLow   Level = "low"   // It has to be refilled soon
+	LevelEmpty Level = "empty" // It has run out
+)
+
+// IsValid checks if the level is one of the levels of a supply
+func (l Level) IsValid() bool {
+	switch l {
+	case LevelOK, LevelLow, LevelEmpty:
+		return true
+	default:
+		return false
+	}
+}
+
+// Hardware is the status of the hardware of a kiosk, as reported by its last heartbeat.
+type Hardware struct {
+	PrinterPaper Level         // The paper left in the receipt printer
+	CoinTubes    map[int]Level // The coins left in the tubes of the coin changer, by the value of the coin in cents
+	DoorOpen     bool          // Whether the service door of the kiosk is open
+}
+
+// Validate checks that the levels of the supplies are known, a supply that is not reported is left empty.
+func (h Hardware) Validate() error {
+	if h.PrinterPaper != "" && !h.PrinterPaper.IsValid() {
+		return fmt.Errorf("%w: printer paper %q", ErrInvalidHardware, h.PrinterPaper)
+	}
+	for coin, level := range h.CoinTubes {
+		if coin <= 0 || !level.IsValid() {
+			return fmt.Errorf("%w: coin tube %d %q", ErrInvalidHardware, coin, level)
+		}
+	}
+	return nil
+}
+
+// Health is the liveness of the kiosk of a terminal.
+type Health struct {
+	TerminalID string
+	LastSeen   time.Time // The time of the last heartbeat of the kiosk
+	Hardware   Hardware  // The hardware status of the last heartbeat
+	Degraded   bool      // Whether the kiosk missed too many heartbeats
+}
+
+// kiosk is the state of the kiosk of a terminal, as the monitor knows it
+type kiosk struct {
+	lastSeen time.Time
+	hardware Hardware
+	degraded bool
+}
+
+// Monitor keeps track of the heartbeats of the kiosks attached to the terminals. A kiosk that misses
+// too many heartbeats degrades its terminal, and optionally the terminal is paused until the kiosk is back.
+// Only the terminals whose kiosk has sent a heartbeat are tracked. It is safe for concurrent use.
+type Monitor struct {
+	interval  time.Duration       // The time between two heartbeats of a kiosk
+	missed    int                 // The number of heartbeats a kiosk can miss, before its terminal is degraded
+	clock     clock.Clock         // The clock that tells the time the heartbeats arrive at
+	terminals *terminals.Registry // optional, the degraded terminals are not paused when it is nil
+
+	mu     sync.Mutex
+	kiosks map[string]*kiosk
+
+	// changes serialises the pauses and resumes of the terminals, each of them checks again whether the kiosk is
+	// degraded while it holds it, so a heartbeat that arrives while a terminal is paused is not undone
+	changes sync.Mutex
+}
+
+// Option is a function that modifies the monitor
+type Option func(*Monitor)
+
+// WithMissed sets the number of heartbeats a kiosk can miss, before its terminal is degraded
+func WithMissed(missed int) Option {
+	return func(m *Monitor) {
+		m.missed = missed
+	}
+}
+
+// WithClock sets the clock that tells the time the heartbeats arrive at
+func WithClock(c clock.Clock) Option {
+	return func(m *Monitor) {
+		m.clock = c
+	}
+}
+
+// WithAutoPause pauses the open terminals of the registry once they are degraded, with ReasonMissedHeartbeats.
+// The terminal is resumed by the next heartbeat of its kiosk, unless the staff switched its state in the meantime.
+func WithAutoPause(registry *terminals.Registry) Option {
+	return func(m *Monitor) {
+		m.terminals = registry
+	}
+}
+
+// NewMonitor creates and returns a new monitor of the kiosks, that send a heartbeat every interval.
+func NewMonitor(interval time.Duration, opts ...Option) (*Monitor, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid heartbeat interval: %v", interval)
+	}
+
+	m := &Monitor{
+		interval: interval,
+		missed:   DefaultMissed,
+		clock:    clock.System,
+		kiosks:   map[string]*kiosk{},
+	}
+
+	// apply the options
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	if m.missed < 1 {
+		return nil, fmt.Errorf("invalid number of missed heartbeats: %d", m.missed)
+	}
+	return m, nil
+}
+
+// Interval returns the time between two heartbeats of a kiosk.
+func (m *Monitor) Interval() time.Duration {
+	return m.interval
+}
+
+// Beat records a heartbeat of the kiosk of the terminal with the given id, along with its hardware status.
+// A degraded terminal is no longer degraded, and resumed if it was paused by the monitor.
+// It returns ErrInvalidHardware if the hardware status is not valid.
+func (m *Monitor) Beat(terminalId string, hardware Hardware) error {
+	if err := hardware.Validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	k, ok := m.kiosks[terminalId]
+	if !ok {
+		k = &kiosk{}
+		m.kiosks[terminalId] = k
+	}
+	recovered := k.degraded
+	k.lastSeen = m.clock.Now()
+	k.hardware = hardware
+	k.degraded = false
+	m.mu.Unlock()
+
+	if recovered {
+		m.resume(terminalId)
+	}
+	return nil
+}
+
+// Check marks the terminals whose kiosk missed too many heartbeats as degraded, and pauses them if the monitor
+// pauses the degraded terminals. It returns the ids of the terminals that are degraded by this check.
+func (m *Monitor) Check() []string {
+	now := m.clock.Now()
+
+	m.mu.Lock()
+	var degraded []string
+	for id, k := range m.kiosks {
+		if !k.degraded && m.overdue(k, now) {
+			k.degraded = true
+			degraded = append(degraded, id)
+		}
+	}
+	m.mu.Unlock()
+
+	// the terminals are paused without the lock held, a heartbeat is not held up by them
+	sort.Strings(degraded)
+	for _, id := range degraded {
+		m.pause(id)
+	}
+	return degraded
+}
+
+// Run checks the heartbeats every interval, until the context is done.
+func (m *Monitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Check()
+		}
+	}
+}
+
+// Health returns the liveness of the kiosk of the terminal with the given id,
+// or false if the kiosk has not sent a heartbeat yet.
+func (m *Monitor) Health(terminalId string) (Health, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	k, ok := m.kiosks[terminalId]
+	if !ok {
+		return Health{}, false
+	}
+	return m.health(terminalId, k, m.clock.Now()), true
+}
+
+// Forget stops tracking the kiosk of the terminal with the given id, i.e. once the terminal is removed.
+func (m *Monitor) Forget(terminalId string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.kiosks, terminalId)
+}
+
+// Rename tracks the kiosk of the terminal with the given id under its new id, once the terminal is renamed.
+// A degraded kiosk stays degraded, so the terminal it paused is resumed by its next heartbeat to the new id.
+func (m *Monitor) Rename(terminalId, newId string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	k, ok := m.kiosks[terminalId]
+	if !ok {
+		return
+	}
+	delete(m.kiosks, terminalId)
+	m.kiosks[newId] = k
+}
+
+// isDegraded checks if the kiosk of the terminal with the given id is tracked and degraded
+func (m *Monitor) isDegraded(terminalId string) (degraded, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	k, ok := m.kiosks[terminalId]
+	if !ok {
+		return false, false
+	}
+	return k.degraded, true
+}
+
+// health returns the liveness of the kiosk, a kiosk that is overdue is degraded even before it is checked.
+// The caller must hold the lock.
+func (m *Monitor) health(terminalId string, k *kiosk, now time.Time) Health {
+	return Health{
+		TerminalID: terminalId,
+		LastSeen:   k.lastSeen,
+		Hardware:   k.hardware,
+		Degraded:   k.degraded || m.overdue(k, now),
+	}
+}
+
+// overdue checks if the kiosk missed more heartbeats than it can
+func (m *Monitor) overdue(k *kiosk, now time.Time) bool {
+	return now.Sub(k.lastSeen) > time.Duration(m.missed)*m.interval
+}
+
+// pause pauses the terminal with the given id if it is open, when the monitor pauses the degraded terminals
+func (m *Monitor) pause(terminalId string) {
+	if m.terminals == nil {
+		return
+	}
+	m.changes.Lock()
+	defer m.changes.Unlock()
+
+	// a heartbeat may have arrived since the check
+	if degraded, _ := m.isDegraded(terminalId); !degraded {
+		return
+	}
+	terminal, ok := m.terminals.Get(terminalId)
+	if !ok || terminal.Status().State != terminals.StateOpen {
+		return
+	}
+	terminal.SetState(terminals.StatePaused, ReasonMissedHeartbeats)
+}
+
+// resume opens the terminal with the given id, if it is still paused by the monitor
+func (m *Monitor) resume(terminalId string) {
+	if m.terminals == nil {
+		return
+	}
+	m.changes.Lock()
+	defer m.changes.Unlock()
+
+	// a check may have degraded the kiosk again since the heartbeat
+	if degraded, ok := m.isDegraded(terminalId); !ok || degraded {
+		return
+	}
+	terminal, ok := m.terminals.Get(terminalId)
+	if !ok {
+		return
+	}
+	if status := terminal.Status(); status.State == terminals.StatePaused && status.Reason == ReasonMissedHeartbeats {
+		terminal.SetState(terminals.StateOpen, "")
+	}
+}
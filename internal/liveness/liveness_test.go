@@ -0,0 +1,139 @@
+package liveness
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/azhovan/currywurst/internal/clock"
+	"github.com/azhovan/currywurst/internal/terminals"
+)
+
+func TestMonitor(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	c := clock.Func(func() time.Time { return now })
+	registry, _ := terminals.NewRegistry(10, nil)
+	terminal, _ := registry.Add("terminal-0")
+
+	monitor, err := NewMonitor(10*time.Second, WithMissed(3), WithClock(c), WithAutoPause(registry))
+	if err != nil {
+		t.Fatalf("NewMonitor() got error:%v, want nil", err)
+	}
+	if _, ok := monitor.Health("terminal-0"); ok {
+		t.Errorf("monitor.Health() got a kiosk that has not sent a heartbeat")
+	}
+
+	hardware := Hardware{PrinterPaper: LevelLow, CoinTubes: map[int]Level{50: LevelOK, 10: LevelEmpty}, DoorOpen: true}
+	if err = monitor.Beat("terminal-0", hardware); err != nil {
+		t.Fatalf("monitor.Beat() got error:%v, want nil", err)
+	}
+	health, ok := monitor.Health("terminal-0")
+	if !ok || !health.LastSeen.Equal(now) || health.Hardware.PrinterPaper != LevelLow || !health.Hardware.DoorOpen || health.Degraded {
+		t.Errorf("monitor.Health() got:%+v, want the last heartbeat", health)
+	}
+
+	// three missed heartbeats are tolerated
+	now = now.Add(30 * time.Second)
+	if degraded := monitor.Check(); len(degraded) != 0 {
+		t.Errorf("monitor.Check() got degraded:%v, want none", degraded)
+	}
+
+	// the fourth one degrades the terminal, and pauses it
+	now = now.Add(time.Second)
+	if degraded := monitor.Check(); len(degraded) != 1 || degraded[0] != "terminal-0" {
+		t.Fatalf("monitor.Check() got degraded:%v, want:%v", degraded, []string{"terminal-0"})
+	}
+	if health, _ = monitor.Health("terminal-0"); !health.Degraded {
+		t.Errorf("monitor.Health() got a kiosk that is not degraded")
+	}
+	if status := terminal.Status(); status.State != terminals.StatePaused || status.Reason != ReasonMissedHeartbeats {
+		t.Errorf("terminal.Status() got:%+v, want paused with:%s", status, ReasonMissedHeartbeats)
+	}
+	if degraded := monitor.Check(); len(degraded) != 0 {
+		t.Errorf("monitor.Check() got degraded:%v again", degraded)
+	}
+
+	// the kiosk is back, the terminal is resumed
+	monitor.Beat("terminal-0", Hardware{})
+	if health, _ = monitor.Health("terminal-0"); health.Degraded {
+		t.Errorf("monitor.Health() got a degraded kiosk after a heartbeat")
+	}
+	if state := terminal.Status().State; state != terminals.StateOpen {
+		t.Errorf("terminal.Status() got:%s, want:%s", state, terminals.StateOpen)
+	}
+
+	// a terminal the staff switched in the meantime is left as it is
+	now = now.Add(time.Minute)
+	monitor.Check()
+	terminal.SetState(terminals.StateMaintenance, "coin acceptor jammed")
+	monitor.Beat("terminal-0", Hardware{})
+	if state := terminal.Status().State; state != terminals.StateMaintenance {
+		t.Errorf("terminal.Status() got:%s, want:%s", state, terminals.StateMaintenance)
+	}
+
+	monitor.Forget("terminal-0")
+	if _, ok = monitor.Health("terminal-0"); ok {
+		t.Errorf("monitor.Health() got a kiosk that is forgotten")
+	}
+}
+
+func TestMonitor_Invalid(t *testing.T) {
+	if _, err := NewMonitor(0); err == nil {
+		t.Errorf("NewMonitor() got nil error, want an invalid interval")
+	}
+	if _, err := NewMonitor(time.Second, WithMissed(0)); err == nil {
+		t.Errorf("NewMonitor() got nil error, want an invalid number of missed heartbeats")
+	}
+
+	monitor, _ := NewMonitor(time.Second)
+	if err := monitor.Beat("terminal-0", Hardware{PrinterPaper: "half"}); !errors.Is(err, ErrInvalidHardware) {
+		t.Errorf("monitor.Beat() got error:%v, want:%v", err, ErrInvalidHardware)
+	}
+	if err := monitor.Beat("terminal-0", Hardware{CoinTubes: map[int]Level{-1: LevelOK}}); !errors.Is(err, ErrInvalidHardware) {
+		t.Errorf("monitor.Beat() got error:%v, want:%v", err, ErrInvalidHardware)
+	}
+}
+
+func TestMonitor_Rename(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	c := clock.Func(func() time.Time { return now })
+	registry, _ := terminals.NewRegistry(10, nil)
+	terminal, _ := registry.Add("terminal-0")
+	monitor, _ := NewMonitor(10*time.Second, WithMissed(3), WithClock(c), WithAutoPause(registry))
+
+	monitor.Beat("terminal-0", Hardware{})
+	now = now.Add(time.Minute)
+	monitor.Check()
+
+	// the paused terminal is renamed, the kiosk is still degraded under the new id
+	registry.Rename("terminal-0", "window")
+	monitor.Rename("terminal-0", "window")
+	if _, ok := monitor.Health("terminal-0"); ok {
+		t.Errorf("monitor.Health() got a kiosk under the old id")
+	}
+	if health, ok := monitor.Health("window"); !ok || !health.Degraded {
+		t.Errorf("monitor.Health() got:%+v, want a degraded kiosk under the new id", health)
+	}
+
+	monitor.Beat("window", Hardware{})
+	if state := terminal.Status().State; state != terminals.StateOpen {
+		t.Errorf("terminal.Status() got:%s, want:%s", state, terminals.StateOpen)
+	}
+}
+
+func TestMonitor_BeatBeforePause(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	c := clock.Func(func() time.Time { return now })
+	registry, _ := terminals.NewRegistry(10, nil)
+	terminal, _ := registry.Add("terminal-0")
+	monitor, _ := NewMonitor(10*time.Second, WithMissed(3), WithClock(c), WithAutoPause(registry))
+
+	// the kiosk is degraded, but a heartbeat arrives before the check pauses the terminal
+	monitor.Beat("terminal-0", Hardware{})
+	monitor.kiosks["terminal-0"].degraded = true
+	monitor.Beat("terminal-0", Hardware{})
+	monitor.pause("terminal-0")
+	if state := terminal.Status().State; state != terminals.StateOpen {
+		t.Errorf("terminal.Status() got:%s, want:%s", state, terminals.StateOpen)
+	}
+}
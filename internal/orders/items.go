@@ -0,0 +1,27 @@
+package orders
+
+// MaxQuantity is the maximum number of units of a single order type that can be ordered in one line.
+// It protects the total price from overflowing and the kitchen from unrealistic orders.
+const MaxQuantity = 99
+
+// Item represents a single line of a basket order, i.e. two vegan currywursts.
+type Item struct {
+	OrderType OrderType // The type of the currywurst, i.e. vegan, non-vegan
+	Quantity  int       // The number of units ordered
+	UnitPrice int       // The price of a single unit in cents
+
+	// PriceOverride is the name of the time based price override, i.e. a happy hour,
+	// that changed the unit price of the item, if any.
+	PriceOverride string
+}
+
+// Total returns the price of the line in cents.
+func (i Item) Total() int {
+	return i.UnitPrice * i.Quantity
+}
+
+// Discount represents a price reduction applied to an order by a pricing rule.
+type Discount struct {
+	Rule   string // The name of the rule that granted the discount
+	Amount int    // The amount of the discount in cents
+}
@@ -33,6 +33,32 @@ func TestOrder_Validate(t *testing.T) {
 			order:   (*Order)(nil),
 			wantErr: ErrOrderNil,
 		},
+		{
+			name: "valid basket order",
+			order: NewBasketOrder(context.TODO(), 130, []Item{
+				{OrderType: Vegan, Quantity: 2},
+				{OrderType: NonVegan, Quantity: 2},
+			}),
+			wantErr: nil,
+		},
+		{
+			name: "basket order with invalid price",
+			order: NewBasketOrder(context.TODO(), 100, []Item{
+				{OrderType: Vegan, Quantity: 2},
+				{OrderType: NonVegan, Quantity: 2},
+			}),
+			wantErr: &ErrInvalidOrder{inserted: 100, price: 130},
+		},
+		{
+			name:    "basket order with invalid quantity",
+			order:   NewBasketOrder(context.TODO(), 100, []Item{{OrderType: Vegan, Quantity: 0}}),
+			wantErr: ErrInvalidQuantity,
+		},
+		{
+			name:    "empty basket order",
+			order:   NewBasketOrder(context.TODO(), 100, nil),
+			wantErr: ErrEmptyOrder,
+		},
 	}
 
 	for _, tt := range tests {
@@ -56,7 +82,7 @@ func TestOrder_WaitWithTimeout(t *testing.T) {
 	defer cancel()
 
 	order := NewOrder(ctx, 50, Vegan)
-	err := order.WaitWithTimeout(time.Millisecond * 2)
+	err := order.WaitWithTimeout(time.Second)
 	if !errors.Is(err, ErrOrderCancelled) {
 		t.Errorf("order.WaitWithTimeout() got error :%v, want:%v", err, ErrOrderCancelled)
 	}
@@ -64,8 +90,14 @@ func TestOrder_WaitWithTimeout(t *testing.T) {
 
 func TestNewOrder(t *testing.T) {
 	order := NewOrder(context.TODO(), 50, Vegan)
-	if order.OrderType != Vegan {
-		t.Errorf("order.NewOrder() got orderType:%v, want:%v", order.OrderType, Vegan)
+	if len(order.Items) != 1 {
+		t.Fatalf("order.NewOrder() got %d items, want:%d", len(order.Items), 1)
+	}
+	if order.Items[0].OrderType != Vegan {
+		t.Errorf("order.NewOrder() got orderType:%v, want:%v", order.Items[0].OrderType, Vegan)
+	}
+	if order.Items[0].Quantity != 1 {
+		t.Errorf("order.NewOrder() got quantity:%d, want:%d", order.Items[0].Quantity, 1)
 	}
 	if order.Inserted != 50 {
 		t.Errorf("order.NewOrder() got Inserted:%d, want:%d", order.Inserted, 50)
@@ -73,6 +105,26 @@ func TestNewOrder(t *testing.T) {
 	if order.Error != nil {
 		t.Errorf("order.NewOrder() got error :%v, expected nil", order.Error)
 	}
+	if order.ID == "" {
+		t.Errorf("order.NewOrder() got an empty id")
+	}
+	if other := NewOrder(context.TODO(), 50, Vegan); other.ID == order.ID {
+		t.Errorf("order.NewOrder() got the same id:%s for two orders", order.ID)
+	}
+}
+
+func TestOrder_Total(t *testing.T) {
+	order := NewBasketOrder(context.TODO(), 200, []Item{
+		{OrderType: Vegan, Quantity: 3},
+		{OrderType: NonVegan, Quantity: 1},
+	})
+
+	if got, want := order.Total(), 3*30+35; got != want {
+		t.Errorf("order.Total() got:%d, want:%d", got, want)
+	}
+	if got, want := order.Items[0].Total(), 3*30; got != want {
+		t.Errorf("item.Total() got:%d, want:%d", got, want)
+	}
 }
 
 func TestOrder_Cancel(t *testing.T) {
@@ -118,7 +170,164 @@ func TestOrder_Cancel(t *testing.T) {
 				default:
 					t.Errorf("order.cancel() didn't cancel the context")
 				}
+
+				// the inserted money is returned in full
+				if tt.order.Returned.Cents != tt.order.Inserted {
+					t.Errorf("order.Cancel() returned:%d, want:%d", tt.order.Returned.Cents, tt.order.Inserted)
+				}
+			}
+		})
+	}
+}
+
+func TestOrder_Transition(t *testing.T) {
+	order := NewOrder(context.TODO(), 50, Vegan)
+	if order.State() != StateReceived {
+		t.Fatalf("order.State() got:%s, want:%s", order.State(), StateReceived)
+	}
+
+	lifecycle := []State{StateQueued, StateValidating, StatePaying, StatePreparing, StateReady, StateCollected}
+	for _, state := range lifecycle {
+		if err := order.Transition(state); err != nil {
+			t.Fatalf("order.Transition(%s) got error:%v, want nil", state, err)
+		}
+	}
+
+	history := order.History()
+	if len(history) != len(lifecycle)+1 {
+		t.Fatalf("order.History() got %d transitions, want:%d", len(history), len(lifecycle)+1)
+	}
+	for i, transition := range history[1:] {
+		if transition.From != history[i].To || transition.To != lifecycle[i] {
+			t.Errorf("order.History()[%d] got:%s->%s, want:%s->%s", i+1, transition.From, transition.To, history[i].To, lifecycle[i])
+		}
+		if transition.At.Before(history[i].At) {
+			t.Errorf("order.History()[%d] got a timestamp before the previous transition", i+1)
+		}
+	}
+
+	// a collected order is final
+	err := order.Transition(StateFailed)
+	var transitionErr *TransitionError
+	if !errors.As(err, &transitionErr) || !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("order.Transition(%s) got error:%v, want:%v", StateFailed, err, ErrInvalidTransition)
+	}
+	if transitionErr.From != StateCollected || transitionErr.To != StateFailed {
+		t.Errorf("order.Transition() got error from %s to %s, want from %s to %s", transitionErr.From, transitionErr.To, StateCollected, StateFailed)
+	}
+}
+
+func TestOrder_InvalidTransition(t *testing.T) {
+	tests := []struct {
+		name string
+		path []State
+		to   State
+	}{
+		{name: "skip the queue", path: nil, to: StatePaying},
+		{name: "ready before paid", path: []State{StateQueued, StateValidating}, to: StateReady},
+		{name: "cancel while paying", path: []State{StateQueued, StateValidating, StatePaying}, to: StateCancelled},
+		{name: "collect a failed order", path: []State{StateFailed}, to: StateCollected},
+		{name: "go back", path: []State{StateQueued, StateValidating}, to: StateQueued},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := NewOrder(context.TODO(), 50, Vegan)
+			for _, state := range tt.path {
+				if err := order.Transition(state); err != nil {
+					t.Fatalf("order.Transition(%s) got error:%v, want nil", state, err)
+				}
+			}
+
+			before := order.State()
+			if err := order.Transition(tt.to); !errors.Is(err, ErrInvalidTransition) {
+				t.Fatalf("order.Transition(%s) got error:%v, want:%v", tt.to, err, ErrInvalidTransition)
+			}
+			if order.State() != before {
+				t.Errorf("order.State() got:%s, want:%s", order.State(), before)
 			}
 		})
 	}
 }
+
+func TestOrder_CancelPaidOrder(t *testing.T) {
+	order := NewOrder(context.TODO(), 50, Vegan)
+	for _, state := range []State{StateQueued, StateValidating, StatePaying} {
+		if err := order.Transition(state); err != nil {
+			t.Fatalf("order.Transition(%s) got error:%v, want nil", state, err)
+		}
+	}
+
+	if err := order.Cancel(); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("order.Cancel() got error:%v, want:%v", err, ErrInvalidTransition)
+	}
+	if cancelled, _ := order.IsCancelled(); cancelled {
+		t.Errorf("order.Cancel() cancelled the context of an order that is being paid")
+	}
+}
+
+func TestOrder_Done(t *testing.T) {
+	order := NewOrder(context.TODO(), 50, Vegan)
+	order.Transition(StateQueued)
+	order.Transition(StateValidating)
+
+	select {
+	case <-order.Done():
+		t.Fatalf("order.Done() is closed before the order is settled")
+	default:
+	}
+
+	wantErr := errors.New("boom")
+	if err := order.Fail(wantErr); err != nil {
+		t.Fatalf("order.Fail() got error:%v, want nil", err)
+	}
+
+	select {
+	case <-order.Done():
+	default:
+		t.Fatalf("order.Done() is not closed once the order is settled")
+	}
+	if err := order.WaitWithTimeout(time.Second); err != nil {
+		t.Errorf("order.WaitWithTimeout() got error:%v, want nil", err)
+	}
+	if order.Error != wantErr {
+		t.Errorf("order.Error got:%v, want:%v", order.Error, wantErr)
+	}
+}
+
+func TestOrder_Recover(t *testing.T) {
+	order := NewOrder(context.TODO(), 50, Vegan)
+	order.Transition(StateQueued)
+	order.Transition(StateValidating)
+	order.Transition(StatePaying)
+
+	// the server stopped while the order was being paid
+	restored := Restore(order.Snapshot())
+	if restored.ID != order.ID || restored.State() != StatePaying || restored.Inserted != 50 {
+		t.Fatalf("Restore() got order %s in state %s, want order %s in state %s", restored.ID, restored.State(), order.ID, StatePaying)
+	}
+	select {
+	case <-restored.Done():
+		t.Fatalf("Restore() closed the done channel of an order that is not settled")
+	default:
+	}
+
+	if err := restored.Recover(StateFailed, "server stopped"); err != nil {
+		t.Fatalf("order.Recover() got error:%v, want nil", err)
+	}
+	if restored.Returned.Cents != 50 {
+		t.Errorf("order.Recover() refunded %d cents, want:%d", restored.Returned.Cents, 50)
+	}
+	if restored.Error == nil || restored.Error.Error() != "server stopped" {
+		t.Errorf("order.Error got:%v, want:%s", restored.Error, "server stopped")
+	}
+	history := restored.History()
+	if last := history[len(history)-1]; last.To != StateFailed || last.Reason != "server stopped" {
+		t.Errorf("order.History() got last transition:%+v, want to %s with the reason", last, StateFailed)
+	}
+
+	// a settled order can not be recovered again
+	if err := restored.Recover(StateCancelled, "server stopped"); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("order.Recover() got error:%v, want:%v", err, ErrInvalidTransition)
+	}
+}
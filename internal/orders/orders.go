@@ -2,8 +2,11 @@ package orders
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/azhovan/currywurst/internal/cashregister"
@@ -25,6 +28,12 @@ var (
 
 	// ErrOrderNil is the error returned when the order is nil.
 	ErrOrderNil = errors.New("order is nil")
+
+	// ErrEmptyOrder is the error returned when the order does not contain any item.
+	ErrEmptyOrder = errors.New("order has no items")
+
+	// ErrInvalidQuantity is the error returned when the quantity of an item is out of range.
+	ErrInvalidQuantity = errors.New("invalid item quantity")
 )
 
 // Order represents the struct to hold the input parameters for the order.
@@ -49,40 +58,221 @@ type Order struct {
 	ctx    context.Context
 	cancel context.CancelFunc
 
-	OrderType OrderType // The type of the currywurst, i.e. vegan, non-vegan
-	Inserted  int       // The amount of money inserted by the customer in cents
+	// mu guards the lifecycle of the order, the state, the history and the status fields.
+	mu      sync.Mutex
+	state   State
+	history []Transition
+	// done is closed once the order is settled, that is ready, failed or cancelled,
+	// so the customer can stop waiting for it.
+	done chan struct{}
+	// observers are notified with a snapshot of the order after every transition.
+	observers []func(Snapshot)
+
+	ID         string        // The unique identifier of the order, assigned at creation
+	TerminalID string        // The id of the terminal the order is sent to
+	Items      []Item        // The lines of the order, each one with its own order type and quantity
+	Coupon     string        // The coupon code entered by the customer, if any
+	Discounts  []Discount    // The discounts granted by the pricing rules, evaluated before payment
+	Inserted   int           // The amount of money inserted by the customer in cents
+	EatIn      bool          // Whether the order is eaten in or taken away, it decides the VAT rate of the food
+	Priority   Priority      // The priority class of the order in the queue of the terminal, normal when empty
+	Payment    PaymentMethod // The way the order is paid, cash when empty
 }
 
-// OrderStatus holds all the information related to the order status.
-// The customer can listen to the Ready channel to get notified
-// when the order is ready or when there is an error.
-// The customer can also check the Error field to see the details of the error, if any.
-// The Ready channel is buffered with a capacity of 1, so the
-// cash register can send a value to it without blocking.
+// OrderStatus holds all the information related to the outcome of the order.
+// The fields are set by the worker before the order is settled, so the customer
+// can read them once WaitWithTimeout returns.
+// The customer can check the Error field to see the details of the error, if any.
 type OrderStatus struct {
-	Ready    chan bool                   // A channel to signal the customer when the order is ready (true) or when there is an error (false).
 	Error    error                       // Any error related to the order or payment.
 	Returned cashregister.ReturnedAmount // The amount of money returned to the customer
 }
 
-// NewOrder returns a new instance of the Order with the given values.
+// NewOrder returns a new instance of the Order with a single unit of the given order type.
 func NewOrder(ctx context.Context, inserted int, orderType OrderType) *Order {
+	return NewBasketOrder(ctx, inserted, []Item{{OrderType: orderType, Quantity: 1}})
+}
+
+// NewBasketOrder returns a new instance of the Order with the given items.
+// The unit price of every item is taken from its order type; items with an unknown
+// order type are kept as they are and rejected later by Validate.
+func NewBasketOrder(ctx context.Context, inserted int, items []Item) *Order {
 	ctx, cancel := context.WithCancel(ctx)
+
+	basket := make([]Item, len(items))
+	for i, item := range items {
+		if orderType := pkg.GetOrderType(item.OrderType.String()); orderType != nil {
+			item.UnitPrice = orderType.Price()
+		}
+		basket[i] = item
+	}
+
 	return &Order{
-		ctx:       ctx,
-		cancel:    cancel,
-		Inserted:  inserted,
-		OrderType: orderType,
+		ID:       NewID(),
+		ctx:      ctx,
+		cancel:   cancel,
+		Inserted: inserted,
+		Items:    basket,
 		OrderStatus: OrderStatus{
-			Ready:    make(chan bool, 1),
 			Error:    nil,
 			Returned: cashregister.ReturnedAmount{},
 		},
+		state:   StateReceived,
+		history: []Transition{{To: StateReceived, At: time.Now()}},
+		done:    make(chan struct{}),
 	}
 }
 
-// The Cancel method prevents the order from being processed by workers.
-// it returns an error when context is not cancellable or missing.
+// NewID returns a new random identifier for an order.
+func NewID() string {
+	b := make([]byte, 8)
+	// crypto/rand.Read never returns an error on the supported platforms
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// State returns the current state of the order.
+func (o *Order) State() State {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.state
+}
+
+// History returns the transitions of the order, from the oldest to the newest.
+func (o *Order) History() []Transition {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	history := make([]Transition, len(o.history))
+	copy(history, o.history)
+	return history
+}
+
+// Done returns a channel that is closed once the order is settled, that is ready, failed or cancelled.
+func (o *Order) Done() <-chan struct{} {
+	return o.done
+}
+
+// Observe registers an observer that is notified with a snapshot of the order after every transition.
+// The observers are called in the order of the transitions, while the order is locked,
+// so they must be quick and must not call the methods of the order.
+func (o *Order) Observe(observer func(Snapshot)) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.observers = append(o.observers, observer)
+}
+
+// Transition moves the order to the given state.
+// It returns a *TransitionError if the state can not be reached from the current state.
+func (o *Order) Transition(to State) error {
+	if o == nil {
+		return ErrOrderNil
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.transition(to)
+}
+
+// transition moves the order to the given state, the caller must hold the lock
+func (o *Order) transition(to State) error {
+	return o.transitionWithReason(to, "")
+}
+
+// transitionWithReason moves the order to the given state and records why, the caller must hold the lock
+func (o *Order) transitionWithReason(to State, reason string) error {
+	if !canTransition(o.state, to) {
+		return &TransitionError{From: o.state, To: to}
+	}
+
+	o.history = append(o.history, Transition{From: o.state, To: to, At: time.Now(), Reason: reason})
+	o.state = to
+	// the customer is notified once, when the order is settled
+	if to.isSettled() {
+		close(o.done)
+	}
+
+	if len(o.observers) > 0 {
+		snapshot := o.snapshot()
+		for _, observer := range o.observers {
+			observer(snapshot)
+		}
+	}
+	return nil
+}
+
+// Fail moves the order to the failed state and records the error that caused it.
+func (o *Order) Fail(err error) error {
+	if o == nil {
+		return ErrOrderNil
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	// the error is set before the transition, so the observers see it
+	if !canTransition(o.state, StateFailed) {
+		return &TransitionError{From: o.state, To: StateFailed}
+	}
+	o.Error = err
+	return o.transition(StateFailed)
+}
+
+// Paid records the change returned to the customer and moves the order to the preparing state.
+func (o *Order) Paid(returned cashregister.ReturnedAmount) error {
+	if o == nil {
+		return ErrOrderNil
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	// the change is set before the transition, so the observers see it
+	if !canTransition(o.state, StatePreparing) {
+		return &TransitionError{From: o.state, To: StatePreparing}
+	}
+	o.Returned = returned
+	return o.transition(StatePreparing)
+}
+
+// Recover resolves an order that was left in flight when the server stopped, by moving it to the given state
+// and recording the reason in its history. The payment of an order that is cancelled or failed this way
+// can not be trusted, so the inserted money is returned in full. A failed order records the reason as its error.
+func (o *Order) Recover(to State, reason string) error {
+	if o == nil {
+		return ErrOrderNil
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	// the refund and the error are set before the transition, so the observers see them
+	if !canTransition(o.state, to) {
+		return &TransitionError{From: o.state, To: to}
+	}
+	if to == StateCancelled || to == StateFailed {
+		o.Returned = cashregister.Refund(o.Inserted)
+	}
+	if to == StateFailed {
+		o.Error = errors.New(reason)
+	}
+	if err := o.transitionWithReason(to, reason); err != nil {
+		return err
+	}
+
+	if to == StateCancelled {
+		o.cancel()
+	}
+	return nil
+}
+
+// Collect moves a ready order to the collected state, once the customer has taken it.
+func (o *Order) Collect() error {
+	return o.Transition(StateCollected)
+}
+
+// The Cancel method prevents the order from being processed by workers and moves it to the cancelled state.
+// The order has not been paid yet, so the inserted money is returned in full.
+// it returns an error when context is not cancellable or missing, or a *TransitionError
+// when the order can no longer be cancelled because it is being paid or already settled.
+// Cancelling an order that is already cancelled is not an error.
 func (o *Order) Cancel() error {
 	if o == nil {
 		return ErrOrderNil
@@ -91,6 +281,19 @@ func (o *Order) Cancel() error {
 		return ErrOrderWithInvalidCtx
 	}
 
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.state != StateCancelled {
+		// the refund is set before the transition, so the observers see it
+		if !canTransition(o.state, StateCancelled) {
+			return &TransitionError{From: o.state, To: StateCancelled}
+		}
+		o.Returned = cashregister.Refund(o.Inserted)
+		if err := o.transition(StateCancelled); err != nil {
+			return err
+		}
+	}
+
 	o.cancel()
 	return nil
 }
@@ -115,24 +318,55 @@ func (o *Order) IsCancelled() (bool, error) {
 	}
 }
 
-// WaitWithTimeout waits for the order to be ready or timeout within the given duration.
-// It returns nil if the order is ready, ErrOrderCancelled if the order is cancelled by the customer or the terminal,
-// or ErrOrderTimeout if the order could not be completed within the timeout.
+// WaitWithTimeout waits for the order to be settled or timeout within the given duration.
+// It returns nil if the order is ready or failed (the Error field tells why), ErrOrderCancelled if the order
+// is cancelled by the customer or the terminal, or ErrOrderTimeout if the order could not be completed within the timeout.
 func (o *Order) WaitWithTimeout(timeout time.Duration) error {
 	if o == nil {
 		return ErrOrderNil
 	}
 
-	for {
-		select {
-		case <-time.NewTimer(timeout).C:
-			return ErrOrderTimeout
-		case <-o.ctx.Done():
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return ErrOrderTimeout
+	case <-o.ctx.Done():
+		return ErrOrderCancelled
+	case <-o.done:
+		if o.State() == StateCancelled {
 			return ErrOrderCancelled
-		case <-o.Ready:
-			return nil
 		}
+		return nil
+	}
+}
+
+// Subtotal returns the price of the whole order in cents before any discount.
+func (o *Order) Subtotal() int {
+	if o == nil {
+		return 0
+	}
+
+	var subtotal int
+	for _, item := range o.Items {
+		subtotal += item.Total()
 	}
+	return subtotal
+}
+
+// Total returns the price of the whole order in cents, that is the subtotal minus the discounts.
+// The total never goes below zero.
+func (o *Order) Total() int {
+	if o == nil {
+		return 0
+	}
+
+	total := o.Subtotal()
+	for _, discount := range o.Discounts {
+		total -= discount.Amount
+	}
+	return max(total, 0)
 }
 
 // Validate validates the different aspects of the order
@@ -143,13 +377,21 @@ func (o *Order) Validate() error {
 		return ErrOrderNil
 	}
 
-	orderType := pkg.GetOrderType(o.OrderType.String())
-	if orderType == nil {
-		return ErrInvalidOrderType
+	if len(o.Items) == 0 {
+		return ErrEmptyOrder
+	}
+
+	for _, item := range o.Items {
+		if pkg.GetOrderType(item.OrderType.String()) == nil {
+			return ErrInvalidOrderType
+		}
+		if item.Quantity <= 0 || item.Quantity > MaxQuantity {
+			return ErrInvalidQuantity
+		}
 	}
 
-	if o.Inserted < orderType.Price() {
-		return &ErrInvalidOrder{inserted: o.Inserted, price: orderType.Price()}
+	if o.Inserted < o.Total() {
+		return &ErrInvalidOrder{inserted: o.Inserted, price: o.Total()}
 	}
 
 	return nil
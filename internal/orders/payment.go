@@ -0,0 +1,22 @@
+package orders
+
+// PaymentMethod is a custom type that represents the way an order is paid.
+type PaymentMethod string
+
+// Define the payment methods
+const (
+	PaymentCash PaymentMethod = "cash" // The customer inserts the money and gets the change back, it is the method of an order without one
+)
+
+// String returns the name of the payment method as a string
+func (p PaymentMethod) String() string {
+	if p == "" {
+		return string(PaymentCash)
+	}
+	return string(p)
+}
+
+// IsValid reports whether the payment method is one the terminals accept, or empty
+func (p PaymentMethod) IsValid() bool {
+	return p == PaymentCash || p == ""
+}
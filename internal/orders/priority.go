@@ -0,0 +1,41 @@
+package orders
+
+// Priority is a custom type that represents the priority class of an order in the queue of a terminal.
+// The orders of a higher class skip the orders of a lower class.
+type Priority string
+
+// Define the priority classes, from the lowest to the highest
+const (
+	PriorityNormal        Priority = "normal"        // The regular customers, it is the class of an order without one
+	PriorityStaff         Priority = "staff"         // The staff meals
+	PriorityPreOrder      Priority = "pre-order"     // The orders placed ahead of time, to be picked up on arrival
+	PriorityAccessibility Priority = "accessibility" // The customers who can not wait in line
+)
+
+// priorityRanks holds the rank of every priority class, a higher rank is served first
+var priorityRanks = map[Priority]int{
+	PriorityNormal:        0,
+	PriorityStaff:         1,
+	PriorityPreOrder:      2,
+	PriorityAccessibility: 3,
+}
+
+// String returns the name of the priority class as a string
+func (p Priority) String() string {
+	if p == "" {
+		return string(PriorityNormal)
+	}
+	return string(p)
+}
+
+// Rank returns the rank of the priority class, a higher rank is served first.
+// An order without a priority class has the rank of PriorityNormal.
+func (p Priority) Rank() int {
+	return priorityRanks[p]
+}
+
+// IsValid reports whether the priority is one of the priority classes, or empty
+func (p Priority) IsValid() bool {
+	_, ok := priorityRanks[p]
+	return ok || p == ""
+}
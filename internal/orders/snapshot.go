@@ -0,0 +1,116 @@
+package orders
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/azhovan/currywurst/internal/cashregister"
+)
+
+// Snapshot is a point in time copy of an order. Unlike the order, it is a plain value
+// that is safe to keep and share after the order moved on, i.e. in an order store.
+type Snapshot struct {
+	ID         string
+	TerminalID string
+	Items      []Item
+	Coupon     string
+	Discounts  []Discount
+	EatIn      bool
+	Priority   Priority
+	Payment    PaymentMethod
+	Inserted   int
+	Subtotal   int
+	Total      int
+	Returned   cashregister.ReturnedAmount
+	State      State
+	History    []Transition
+	Error      string
+}
+
+// CreatedAt returns the time the order was created at.
+func (s Snapshot) CreatedAt() time.Time {
+	if len(s.History) == 0 {
+		return time.Time{}
+	}
+	return s.History[0].At
+}
+
+// UpdatedAt returns the time of the last transition of the order.
+func (s Snapshot) UpdatedAt() time.Time {
+	if len(s.History) == 0 {
+		return time.Time{}
+	}
+	return s.History[len(s.History)-1].At
+}
+
+// Snapshot returns a point in time copy of the order.
+func (o *Order) Snapshot() Snapshot {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.snapshot()
+}
+
+// snapshot returns a point in time copy of the order, the caller must hold the lock
+func (o *Order) snapshot() Snapshot {
+	s := Snapshot{
+		ID:         o.ID,
+		TerminalID: o.TerminalID,
+		Items:      append([]Item(nil), o.Items...),
+		Coupon:     o.Coupon,
+		Discounts:  append([]Discount(nil), o.Discounts...),
+		EatIn:      o.EatIn,
+		Priority:   o.Priority,
+		Payment:    o.Payment,
+		Inserted:   o.Inserted,
+		Subtotal:   o.Subtotal(),
+		Total:      o.Total(),
+		Returned:   o.Returned,
+		State:      o.state,
+		History:    append([]Transition(nil), o.history...),
+	}
+	if o.Error != nil {
+		s.Error = o.Error.Error()
+	}
+	return s
+}
+
+// Restore rebuilds an order out of its snapshot, i.e. to resolve an order that was left in flight
+// when the server stopped. The restored order has no observers, and it is no longer tied to any request.
+func Restore(s Snapshot) *Order {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	o := &Order{
+		ctx:        ctx,
+		cancel:     cancel,
+		state:      s.State,
+		history:    append([]Transition(nil), s.History...),
+		done:       make(chan struct{}),
+		ID:         s.ID,
+		TerminalID: s.TerminalID,
+		Items:      append([]Item(nil), s.Items...),
+		Coupon:     s.Coupon,
+		Discounts:  append([]Discount(nil), s.Discounts...),
+		Inserted:   s.Inserted,
+		EatIn:      s.EatIn,
+		Priority:   s.Priority,
+		Payment:    s.Payment,
+	}
+	o.Returned = s.Returned
+	if s.Error != "" {
+		o.Error = errors.New(s.Error)
+	}
+
+	// the customer waiting for a settled order has nothing left to wait for
+	settled := s.State.isSettled()
+	for _, t := range s.History {
+		settled = settled || t.To.isSettled()
+	}
+	if settled {
+		close(o.done)
+	}
+	if s.State == StateCancelled {
+		cancel()
+	}
+	return o
+}
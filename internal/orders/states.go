@@ -0,0 +1,95 @@
+package orders
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidTransition is the error returned when an order is moved to a state that
+// can not be reached from its current state.
+var ErrInvalidTransition = errors.New("invalid order state transition")
+
+// State is a custom type that represents a state of the order lifecycle
+type State string
+
+// Define the states of the order lifecycle, in the order they are usually reached
+const (
+	StateReceived   State = "received"   // The order has been created out of the customer's request
+	StateQueued     State = "queued"     // The order is waiting in the terminal's queue
+	StateValidating State = "validating" // A worker took the order and validates it
+	StatePaying     State = "paying"     // The order is being paid, it can no longer be cancelled
+	StatePreparing  State = "preparing"  // The order has been paid and is being prepared
+	StateReady      State = "ready"      // The order is ready and the change has been returned
+	StateCollected  State = "collected"  // The customer collected the order
+	StateCancelled  State = "cancelled"  // The order was cancelled before it was paid
+	StateFailed     State = "failed"     // The order could not be completed, the Error field tells why
+)
+
+// String returns the name of the state as a string
+func (s State) String() string {
+	return string(s)
+}
+
+// IsFinal reports whether the state is the last state of the lifecycle, no transition leaves a final state.
+func (s State) IsFinal() bool {
+	return len(transitions[s]) == 0
+}
+
+// IsValid reports whether the state is a state of the order lifecycle
+func (s State) IsValid() bool {
+	_, ok := transitions[s]
+	return ok
+}
+
+// isSettled reports whether the customer waiting for the order can be answered,
+// that is the order is either ready or it will never be
+func (s State) isSettled() bool {
+	return s == StateReady || s == StateFailed || s == StateCancelled
+}
+
+// transitions holds the states that can be reached from every state
+var transitions = map[State][]State{
+	StateReceived:   {StateQueued, StateCancelled, StateFailed},
+	StateQueued:     {StateValidating, StateCancelled, StateFailed},
+	StateValidating: {StatePaying, StateCancelled, StateFailed},
+	StatePaying:     {StatePreparing, StateFailed},
+	StatePreparing:  {StateReady, StateFailed},
+	StateReady:      {StateCollected},
+	StateCollected:  {},
+	StateCancelled:  {},
+	StateFailed:     {},
+}
+
+// canTransition reports whether the to state can be reached from the from state
+func canTransition(from, to State) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Transition records a change of the state of an order.
+type Transition struct {
+	From   State     // The state the order left, empty for the initial state
+	To     State     // The state the order entered
+	At     time.Time // The time of the transition
+	Reason string    // Why the order was moved, only set when it is not obvious, i.e. after a restart
+}
+
+// TransitionError is a custom error type that indicates that a transition is not allowed.
+type TransitionError struct {
+	From, To State
+}
+
+// Error returns the error message for TransitionError.
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("%s from %s to %s", ErrInvalidTransition, e.From, e.To)
+}
+
+// Unwrap returns ErrInvalidTransition, so the error can be checked with errors.Is.
+func (e *TransitionError) Unwrap() error {
+	return ErrInvalidTransition
+}
@@ -7,6 +7,8 @@ type OrderType string
 const (
 	Vegan    OrderType = "vegan"
 	NonVegan OrderType = "non-vegan"
+	Fries    OrderType = "fries"
+	Drink    OrderType = "drink"
 )
 
 // String returns the name of the order type as a string
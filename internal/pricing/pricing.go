@@ -0,0 +1,149 @@
+package pricing
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/azhovan/currywurst/internal/orders"
+)
+
+// ErrInvalidCoupon is the error returned when the customer enters a coupon code that no rule accepts.
+var ErrInvalidCoupon = errors.New("invalid coupon code")
+
+// Rule is a pricing rule that can be evaluated over a basket before payment.
+type Rule interface {
+	// Name returns the name of the rule, it is listed next to the discount it grants.
+	Name() string
+	// Priority returns the priority of the rule, rules with a lower priority are evaluated first.
+	Priority() int
+	// Apply evaluates the rule over the basket and returns the discount it grants in cents.
+	Apply(b *Basket) int
+}
+
+// Engine evaluates a set of pricing rules over the basket of an order.
+// The rules are evaluated in a deterministic order: by priority first and by name second,
+// so the same basket always gets the same discounts regardless of how the rules were registered.
+type Engine struct {
+	rules   []Rule
+	coupons map[string]bool
+}
+
+// NewEngine returns a new pricing engine with the given rules.
+func NewEngine(rules ...Rule) *Engine {
+	sorted := make([]Rule, len(rules))
+	copy(sorted, rules)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Priority() != sorted[j].Priority() {
+			return sorted[i].Priority() < sorted[j].Priority()
+		}
+		return sorted[i].Name() < sorted[j].Name()
+	})
+
+	coupons := map[string]bool{}
+	for _, rule := range sorted {
+		if c, ok := rule.(*coupon); ok {
+			coupons[normalizeCoupon(c.code)] = true
+		}
+	}
+
+	return &Engine{rules: sorted, coupons: coupons}
+}
+
+// Apply evaluates the rules over the basket of the order and stores the granted discounts with the order.
+// It returns ErrInvalidCoupon if the order has a coupon code that is not accepted by any rule.
+func (e *Engine) Apply(order *orders.Order) error {
+	if order == nil {
+		return orders.ErrOrderNil
+	}
+
+	if order.Coupon != "" && !e.coupons[normalizeCoupon(order.Coupon)] {
+		return ErrInvalidCoupon
+	}
+
+	basket := newBasket(order)
+	var discounts []orders.Discount
+	for _, rule := range e.rules {
+		// a discount can never exceed what is left to be paid
+		amount := min(rule.Apply(basket), basket.total)
+		if amount <= 0 {
+			continue
+		}
+
+		basket.total -= amount
+		discounts = append(discounts, orders.Discount{Rule: rule.Name(), Amount: amount})
+	}
+
+	order.Discounts = discounts
+	return nil
+}
+
+// Basket is the view of an order the rules are evaluated over.
+// Rules that discount single units, like combos, take the units out of the basket,
+// so a unit is never discounted twice by two of those rules.
+type Basket struct {
+	coupon string
+	units  map[orders.OrderType]int // the units that have not been taken by any rule yet
+	prices map[orders.OrderType]int // the unit price of every order type in the basket
+	total  int                      // the amount left to be paid after the discounts granted so far
+}
+
+// newBasket creates a basket out of the items of the given order
+func newBasket(order *orders.Order) *Basket {
+	b := &Basket{
+		coupon: normalizeCoupon(order.Coupon),
+		units:  map[orders.OrderType]int{},
+		prices: map[orders.OrderType]int{},
+		total:  order.Subtotal(),
+	}
+	for _, item := range order.Items {
+		if item.Quantity <= 0 {
+			continue
+		}
+		b.units[item.OrderType] += item.Quantity
+		b.prices[item.OrderType] = item.UnitPrice
+	}
+
+	return b
+}
+
+// Available returns the number of units of the given order type that have not been taken by any rule yet.
+func (b *Basket) Available(orderType orders.OrderType) int {
+	return b.units[orderType]
+}
+
+// UnitPrice returns the price of a single unit of the given order type in cents.
+func (b *Basket) UnitPrice(orderType orders.OrderType) int {
+	return b.prices[orderType]
+}
+
+// Take takes up to n units of the given order type out of the basket and returns how many were taken.
+func (b *Basket) Take(orderType orders.OrderType, n int) int {
+	taken := min(n, b.units[orderType])
+	if taken <= 0 {
+		return 0
+	}
+
+	b.units[orderType] -= taken
+	return taken
+}
+
+// Total returns the amount left to be paid in cents, after the discounts granted so far.
+func (b *Basket) Total() int {
+	return b.total
+}
+
+// Coupon returns the coupon code entered by the customer, if any.
+func (b *Basket) Coupon() string {
+	return b.coupon
+}
+
+// normalizeCoupon makes the coupon codes case-insensitive
+func normalizeCoupon(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// percentOf returns the given percentage of the amount in cents, rounded half up
+func percentOf(amount, percent int) int {
+	return (amount*percent + 50) / 100
+}
@@ -0,0 +1,153 @@
+package pricing
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/azhovan/currywurst/internal/orders"
+)
+
+func TestEngine_Apply(t *testing.T) {
+	menu := NewCombo("menu", 10, 60, orders.Vegan, orders.Fries, orders.Drink)
+	secondHalfPrice := NewBuyXGetY("second vegan half price", 20, orders.Vegan, 1, 1, 50)
+	coupon := NewCoupon("CURRY10", NewPercentageDiscount("curry10", 30, "", 10))
+
+	tests := []struct {
+		name          string
+		items         []orders.Item
+		coupon        string
+		rules         []Rule
+		wantDiscounts []orders.Discount
+		wantTotal     int
+		wantErr       error
+	}{
+		{
+			name:      "no rules",
+			items:     []orders.Item{{OrderType: orders.Vegan, Quantity: 2}},
+			wantTotal: 60,
+		},
+		{
+			name: "combo",
+			items: []orders.Item{
+				{OrderType: orders.Vegan, Quantity: 1},
+				{OrderType: orders.Fries, Quantity: 1},
+				{OrderType: orders.Drink, Quantity: 1},
+			},
+			rules:         []Rule{menu},
+			wantDiscounts: []orders.Discount{{Rule: "menu", Amount: 15}},
+			wantTotal:     60,
+		},
+		{
+			name: "combo is applied once per complete set",
+			items: []orders.Item{
+				{OrderType: orders.Vegan, Quantity: 3},
+				{OrderType: orders.Fries, Quantity: 2},
+				{OrderType: orders.Drink, Quantity: 2},
+			},
+			rules:         []Rule{menu},
+			wantDiscounts: []orders.Discount{{Rule: "menu", Amount: 30}},
+			wantTotal:     150,
+		},
+		{
+			name:          "buy one get one half price",
+			items:         []orders.Item{{OrderType: orders.Vegan, Quantity: 3}},
+			rules:         []Rule{secondHalfPrice},
+			wantDiscounts: []orders.Discount{{Rule: "second vegan half price", Amount: 15}},
+			wantTotal:     75,
+		},
+		{
+			name: "units taken by a combo are not discounted twice",
+			items: []orders.Item{
+				{OrderType: orders.Vegan, Quantity: 2},
+				{OrderType: orders.Fries, Quantity: 1},
+				{OrderType: orders.Drink, Quantity: 1},
+			},
+			// registered in reverse order, the priority decides
+			rules:         []Rule{secondHalfPrice, menu},
+			wantDiscounts: []orders.Discount{{Rule: "menu", Amount: 15}},
+			wantTotal:     90,
+		},
+		{
+			name:          "coupon applies on what is left to be paid",
+			items:         []orders.Item{{OrderType: orders.Vegan, Quantity: 2}},
+			coupon:        "curry10",
+			rules:         []Rule{coupon, secondHalfPrice},
+			wantDiscounts: []orders.Discount{{Rule: "second vegan half price", Amount: 15}, {Rule: "curry10", Amount: 5}},
+			wantTotal:     40,
+		},
+		{
+			name:      "coupon without code",
+			items:     []orders.Item{{OrderType: orders.Vegan, Quantity: 1}},
+			rules:     []Rule{coupon},
+			wantTotal: 30,
+		},
+		{
+			name:    "unknown coupon",
+			items:   []orders.Item{{OrderType: orders.Vegan, Quantity: 1}},
+			coupon:  "FREEWURST",
+			rules:   []Rule{coupon},
+			wantErr: ErrInvalidCoupon,
+		},
+		{
+			name:          "fixed discount is capped by the total",
+			items:         []orders.Item{{OrderType: orders.Drink, Quantity: 1}},
+			rules:         []Rule{NewFixedDiscount("welcome", 10, 50, 0)},
+			wantDiscounts: []orders.Discount{{Rule: "welcome", Amount: 20}},
+			wantTotal:     0,
+		},
+		{
+			name:      "fixed discount below the minimum total",
+			items:     []orders.Item{{OrderType: orders.Drink, Quantity: 1}},
+			rules:     []Rule{NewFixedDiscount("big spender", 10, 10, 100)},
+			wantTotal: 20,
+		},
+		{
+			name:          "percentage discount on a product",
+			items:         []orders.Item{{OrderType: orders.Fries, Quantity: 2}, {OrderType: orders.Drink, Quantity: 1}},
+			rules:         []Rule{NewPercentageDiscount("fries day", 10, orders.Fries, 20)},
+			wantDiscounts: []orders.Discount{{Rule: "fries day", Amount: 10}},
+			wantTotal:     60,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := orders.NewBasketOrder(context.TODO(), 500, tt.items)
+			order.Coupon = tt.coupon
+
+			err := NewEngine(tt.rules...).Apply(order)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("engine.Apply() got error:%v, want:%v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if !reflect.DeepEqual(order.Discounts, tt.wantDiscounts) {
+				t.Errorf("engine.Apply() got discounts:%v, want:%v", order.Discounts, tt.wantDiscounts)
+			}
+			if order.Total() != tt.wantTotal {
+				t.Errorf("order.Total() got:%d, want:%d", order.Total(), tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestNewEngine_DeterministicPriority(t *testing.T) {
+	a := NewFixedDiscount("a", 10, 1, 0)
+	b := NewFixedDiscount("b", 10, 1, 0)
+	c := NewFixedDiscount("c", 5, 1, 0)
+
+	engine := NewEngine(b, a, c)
+	var got []string
+	for _, rule := range engine.rules {
+		got = append(got, rule.Name())
+	}
+
+	want := []string{"c", "a", "b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("NewEngine() got rules order:%v, want:%v", got, want)
+	}
+}
@@ -0,0 +1,179 @@
+package pricing
+
+import (
+	"github.com/azhovan/currywurst/internal/orders"
+)
+
+// combo sells a fixed set of products for a fixed price, i.e. currywurst + fries + drink.
+type combo struct {
+	name     string
+	priority int
+	products map[orders.OrderType]int
+	price    int
+}
+
+// NewCombo returns a rule that sells the given products together for the given price in cents.
+// A product can be listed more than once to require several units of it.
+// The combo is applied as many times as the basket contains complete sets of the products.
+func NewCombo(name string, priority, price int, products ...orders.OrderType) Rule {
+	set := map[orders.OrderType]int{}
+	for _, product := range products {
+		set[product]++
+	}
+	return &combo{name: name, priority: priority, products: set, price: price}
+}
+
+// Name returns the name of the combo
+func (c *combo) Name() string { return c.name }
+
+// Priority returns the priority of the combo
+func (c *combo) Priority() int { return c.priority }
+
+// Apply takes every complete set of the products out of the basket and discounts
+// the difference between the regular price of the set and the combo price
+func (c *combo) Apply(b *Basket) int {
+	if len(c.products) == 0 {
+		return 0
+	}
+
+	// the number of complete sets in the basket
+	sets := -1
+	regular := 0
+	for product, quantity := range c.products {
+		n := b.Available(product) / quantity
+		if sets == -1 || n < sets {
+			sets = n
+		}
+		regular += b.UnitPrice(product) * quantity
+	}
+
+	// the combo is not cheaper than buying the products separately, leave them for the other rules
+	if sets <= 0 || regular <= c.price {
+		return 0
+	}
+
+	for product, quantity := range c.products {
+		b.Take(product, sets*quantity)
+	}
+	return sets * (regular - c.price)
+}
+
+// buyXGetY discounts some units of a product when buying others, i.e. the second vegan currywurst half price.
+type buyXGetY struct {
+	name     string
+	priority int
+	product  orders.OrderType
+	buy, get int
+	percent  int
+}
+
+// NewBuyXGetY returns a rule that discounts `get` units of the product by the given percentage
+// for every `buy` units of it in the basket. A percentage of 100 makes the discounted units free.
+func NewBuyXGetY(name string, priority int, product orders.OrderType, buy, get, percent int) Rule {
+	return &buyXGetY{name: name, priority: priority, product: product, buy: buy, get: get, percent: clampPercent(percent)}
+}
+
+// Name returns the name of the rule
+func (r *buyXGetY) Name() string { return r.name }
+
+// Priority returns the priority of the rule
+func (r *buyXGetY) Priority() int { return r.priority }
+
+// Apply takes every group of buy+get units out of the basket and discounts the get units of each group
+func (r *buyXGetY) Apply(b *Basket) int {
+	if r.buy < 0 || r.get <= 0 {
+		return 0
+	}
+
+	groups := b.Available(r.product) / (r.buy + r.get)
+	if groups == 0 {
+		return 0
+	}
+
+	b.Take(r.product, groups*(r.buy+r.get))
+	return percentOf(groups*r.get*b.UnitPrice(r.product), r.percent)
+}
+
+// percentage discounts either the units of a product or the whole basket by a percentage.
+type percentage struct {
+	name     string
+	priority int
+	product  orders.OrderType
+	percent  int
+}
+
+// NewPercentageDiscount returns a rule that discounts the units of the given product by the given percentage.
+// An empty product discounts whatever is left to be paid for the whole basket instead.
+func NewPercentageDiscount(name string, priority int, product orders.OrderType, percent int) Rule {
+	return &percentage{name: name, priority: priority, product: product, percent: clampPercent(percent)}
+}
+
+// Name returns the name of the rule
+func (r *percentage) Name() string { return r.name }
+
+// Priority returns the priority of the rule
+func (r *percentage) Priority() int { return r.priority }
+
+// Apply discounts the units of the product that have not been taken by another rule yet,
+// or the amount left to be paid when the rule applies to the whole basket
+func (r *percentage) Apply(b *Basket) int {
+	if r.product == "" {
+		return percentOf(b.Total(), r.percent)
+	}
+
+	units := b.Take(r.product, b.Available(r.product))
+	return percentOf(units*b.UnitPrice(r.product), r.percent)
+}
+
+// fixed discounts a fixed amount from the basket.
+type fixed struct {
+	name     string
+	priority int
+	amount   int
+	minTotal int
+}
+
+// NewFixedDiscount returns a rule that discounts the given amount in cents from the basket,
+// as long as the amount left to be paid is at least minTotal cents.
+func NewFixedDiscount(name string, priority, amount, minTotal int) Rule {
+	return &fixed{name: name, priority: priority, amount: amount, minTotal: minTotal}
+}
+
+// Name returns the name of the rule
+func (r *fixed) Name() string { return r.name }
+
+// Priority returns the priority of the rule
+func (r *fixed) Priority() int { return r.priority }
+
+// Apply discounts the fixed amount if the basket reaches the minimum total
+func (r *fixed) Apply(b *Basket) int {
+	if b.Total() < r.minTotal {
+		return 0
+	}
+	return r.amount
+}
+
+// coupon wraps a rule, so that it only applies when the customer enters the coupon code.
+type coupon struct {
+	Rule
+	code string
+}
+
+// NewCoupon returns a rule that only applies the given rule when the customer enters the coupon code.
+// Coupon codes are case-insensitive.
+func NewCoupon(code string, rule Rule) Rule {
+	return &coupon{Rule: rule, code: code}
+}
+
+// Apply applies the wrapped rule if the basket has the coupon code
+func (c *coupon) Apply(b *Basket) int {
+	if b.Coupon() != normalizeCoupon(c.code) {
+		return 0
+	}
+	return c.Rule.Apply(b)
+}
+
+// clampPercent keeps the percentage in the range of 0 to 100
+func clampPercent(percent int) int {
+	return max(0, min(percent, 100))
+}
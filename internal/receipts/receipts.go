@@ -0,0 +1,191 @@
+package receipts
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/azhovan/currywurst/internal/orders"
+	"github.com/azhovan/currywurst/internal/tax"
+)
+
+// ErrReceiptNotFound is the error returned when there is no receipt for the given order id.
+var ErrReceiptNotFound = errors.New("receipt not found")
+
+// width is the number of characters of a line of the plain text receipt
+const width = 40
+
+// Receipt is the itemised receipt of a paid order.
+type Receipt struct {
+	OrderID   string       `json:"orderId"`
+	IssuedAt  time.Time    `json:"issuedAt"`
+	EatIn     bool         `json:"eatIn"`
+	Items     []Line       `json:"items"`
+	Discounts []Discount   `json:"discounts"`
+	Taxes     []tax.Amount `json:"taxes"`
+	Total     int          `json:"total"`    // The gross total in cents, after the discounts
+	Paid      int          `json:"paid"`     // The amount of money inserted by the customer in cents
+	Returned  int          `json:"returned"` // The change returned to the customer in cents
+}
+
+// Line is a single item of the receipt.
+type Line struct {
+	OrderType string `json:"orderType"`
+	Quantity  int    `json:"quantity"`
+	UnitPrice int    `json:"unitPrice"`
+	Total     int    `json:"total"`
+	TaxRate   int    `json:"taxRate"`
+}
+
+// Discount is a discount listed on the receipt.
+type Discount struct {
+	Rule   string `json:"rule"`
+	Amount int    `json:"amount"`
+}
+
+// New creates the receipt of the given paid order, issued at the given time.
+func New(order *orders.Order, issuedAt time.Time) *Receipt {
+	r := &Receipt{
+		OrderID:   order.ID,
+		IssuedAt:  issuedAt,
+		EatIn:     order.EatIn,
+		Items:     make([]Line, 0, len(order.Items)),
+		Discounts: make([]Discount, 0, len(order.Discounts)),
+		Taxes:     tax.Compute(order),
+		Total:     order.Total(),
+		Paid:      order.Inserted,
+		Returned:  order.Returned.Cents,
+	}
+
+	for _, item := range order.Items {
+		r.Items = append(r.Items, Line{
+			OrderType: item.OrderType.String(),
+			Quantity:  item.Quantity,
+			UnitPrice: item.UnitPrice,
+			Total:     item.Total(),
+			TaxRate:   tax.ItemRate(order, item),
+		})
+	}
+	for _, discount := range order.Discounts {
+		r.Discounts = append(r.Discounts, Discount{Rule: discount.Rule, Amount: discount.Amount})
+	}
+
+	return r
+}
+
+// Rebuild recreates the receipt of a paid order out of its snapshot, i.e. once the server restarted
+// and the receipts kept in memory are gone. The receipt is dated at the time the order was made ready,
+// which is when it was issued in the first place. It returns ErrReceiptNotFound if the order was never made ready.
+func Rebuild(snapshot orders.Snapshot) (*Receipt, error) {
+	for _, t := range snapshot.History {
+		if t.To == orders.StateReady {
+			return New(orders.Restore(snapshot), t.At), nil
+		}
+	}
+	return nil, ErrReceiptNotFound
+}
+
+// Text renders the receipt as plain text, the way it is printed by the terminal.
+// Every VAT rate is marked with a letter, that is printed next to the items taxed at that rate.
+func (r *Receipt) Text() string {
+	letters := map[int]string{}
+	for i, amount := range r.Taxes {
+		letters[amount.Rate] = string(rune('A' + i))
+	}
+
+	consumption := "Take-away"
+	if r.EatIn {
+		consumption = "Eat-in"
+	}
+
+	var b strings.Builder
+	b.WriteString(center("CURRYWURST") + "\n")
+	b.WriteString(columns("Order "+r.OrderID, "") + "\n")
+	b.WriteString(columns(r.IssuedAt.Format("2006-01-02 15:04"), consumption) + "\n")
+	b.WriteString(strings.Repeat("-", width) + "\n")
+
+	for _, line := range r.Items {
+		item := fmt.Sprintf("%d x %s", line.Quantity, line.OrderType)
+		b.WriteString(columns(item, fmt.Sprintf("%s %s", euros(line.Total), letters[line.TaxRate])) + "\n")
+		if line.Quantity > 1 {
+			b.WriteString(columns(fmt.Sprintf("  @ %s", euros(line.UnitPrice)), "") + "\n")
+		}
+	}
+	for _, discount := range r.Discounts {
+		b.WriteString(columns(discount.Rule, euros(-discount.Amount)+"  ") + "\n")
+	}
+
+	b.WriteString(strings.Repeat("-", width) + "\n")
+	b.WriteString(columns("Total", euros(r.Total)+"  ") + "\n")
+	b.WriteString(columns("Paid", euros(r.Paid)+"  ") + "\n")
+	b.WriteString(columns("Change", euros(r.Returned)+"  ") + "\n")
+	b.WriteString("\n")
+
+	b.WriteString(fmt.Sprintf("%-8s%10s%10s%10s\n", "VAT", "Net", "VAT", "Gross"))
+	for _, amount := range r.Taxes {
+		rate := fmt.Sprintf("%s %d%%", letters[amount.Rate], amount.Rate)
+		b.WriteString(fmt.Sprintf("%-8s%10s%10s%10s\n", rate, euros(amount.Net), euros(amount.Tax), euros(amount.Gross)))
+	}
+
+	return b.String()
+}
+
+// Store keeps the receipts in memory by order id, it is safe for concurrent use.
+type Store struct {
+	mu       sync.RWMutex
+	receipts map[string]*Receipt
+}
+
+// NewStore returns a new empty receipt store.
+func NewStore() *Store {
+	return &Store{receipts: map[string]*Receipt{}}
+}
+
+// Put stores the receipt, replacing any receipt of the same order.
+func (s *Store) Put(r *Receipt) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.receipts[r.OrderID] = r
+}
+
+// Get returns the receipt of the given order, or ErrReceiptNotFound if there is none.
+func (s *Store) Get(orderID string) (*Receipt, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	r, ok := s.receipts[orderID]
+	if !ok {
+		return nil, ErrReceiptNotFound
+	}
+	return r, nil
+}
+
+// euros formats the given amount in cents as euros, i.e. 1.50
+func euros(cents int) string {
+	sign := ""
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// columns aligns the left text to the left and the right text to the right of a receipt line
+func columns(left, right string) string {
+	padding := width - len(left) - len(right)
+	if padding < 1 {
+		padding = 1
+	}
+	return strings.TrimRight(left+strings.Repeat(" ", padding)+right, " ")
+}
+
+// center centers the text on a receipt line
+func center(text string) string {
+	padding := (width - len(text)) / 2
+	if padding < 0 {
+		padding = 0
+	}
+	return strings.Repeat(" ", padding) + text
+}
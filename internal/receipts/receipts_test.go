@@ -0,0 +1,87 @@
+package receipts
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/azhovan/currywurst/internal/cashregister"
+	"github.com/azhovan/currywurst/internal/orders"
+)
+
+func TestReceipt_Text(t *testing.T) {
+	order := orders.NewBasketOrder(context.TODO(), 100, []orders.Item{
+		{OrderType: orders.Vegan, Quantity: 2},
+		{OrderType: orders.Drink, Quantity: 1},
+	})
+	order.Discounts = []orders.Discount{{Rule: "second vegan half price", Amount: 15}}
+	order.Returned = cashregister.ReturnedAmount{Cents: 35, Formatted: "35 Cent"}
+
+	receipt := New(order, time.Date(2023, time.October, 16, 12, 30, 0, 0, time.UTC))
+	text := receipt.Text()
+
+	for _, want := range []string{
+		"Order " + order.ID,
+		"2023-10-16 12:30",
+		"Take-away",
+		"2 x vegan",
+		"0.60 A",
+		"0.20 B",
+		"second vegan half price",
+		"-0.15",
+		"Total",
+		"0.65",
+		"Change",
+		"0.35",
+		"A 7%",
+		"B 19%",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("receipt.Text() does not contain %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	order := orders.NewOrder(context.TODO(), 30, orders.Vegan)
+	store := NewStore()
+
+	_, err := store.Get(order.ID)
+	if !errors.Is(err, ErrReceiptNotFound) {
+		t.Fatalf("store.Get() got error:%v, want:%v", err, ErrReceiptNotFound)
+	}
+
+	store.Put(New(order, time.Now()))
+	receipt, err := store.Get(order.ID)
+	if err != nil {
+		t.Fatalf("store.Get() got error:%v, want nil", err)
+	}
+	if receipt.OrderID != order.ID {
+		t.Errorf("store.Get() got receipt of order:%s, want:%s", receipt.OrderID, order.ID)
+	}
+}
+
+func TestRebuild(t *testing.T) {
+	order := orders.NewOrder(context.TODO(), 50, orders.Vegan)
+	if _, err := Rebuild(order.Snapshot()); !errors.Is(err, ErrReceiptNotFound) {
+		t.Fatalf("Rebuild() of an unpaid order got error:%v, want:%v", err, ErrReceiptNotFound)
+	}
+
+	for _, state := range []orders.State{orders.StateQueued, orders.StateValidating, orders.StatePaying} {
+		order.Transition(state)
+	}
+	order.Paid(cashregister.ReturnedAmount{Cents: 20, Formatted: "20 Cent"})
+	order.Transition(orders.StateReady)
+	order.Collect()
+
+	snapshot := order.Snapshot()
+	receipt, err := Rebuild(snapshot)
+	if err != nil {
+		t.Fatalf("Rebuild() got error:%v, want nil", err)
+	}
+	if want := New(order, snapshot.History[len(snapshot.History)-2].At); receipt.Text() != want.Text() {
+		t.Errorf("Rebuild() got:\n%s\nwant:\n%s", receipt.Text(), want.Text())
+	}
+}
@@ -0,0 +1,143 @@
+package recovery
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/azhovan/currywurst/internal/journal"
+	"github.com/azhovan/currywurst/internal/orders"
+	"github.com/azhovan/currywurst/internal/store"
+)
+
+// Policy tells what happens to the orders that were paid, but not handed over, when the server stopped.
+type Policy int
+
+// Define the policies of the paid orders
+const (
+	// Requeue hands the paid orders back to the kitchen, they are left preparing until the caller puts them
+	// on their terminal again, where they are prepared and become ready for collection.
+	Requeue Policy = iota
+	// Refund fails the paid orders and returns the inserted money in full.
+	Refund
+)
+
+// String returns the name of the policy
+func (p Policy) String() string {
+	switch p {
+	case Requeue:
+		return "requeue"
+	case Refund:
+		return "refund"
+	default:
+		return "unknown"
+	}
+}
+
+// Define the reasons recorded in the history of the recovered orders
+const (
+	ReasonNotPaid  = "server stopped before the order was paid"
+	ReasonPaying   = "server stopped while the order was being paid, the payment is refunded"
+	ReasonRequeued = "server stopped while the order was being prepared, the order is prepared again"
+	ReasonRefunded = "server stopped while the order was being prepared, the payment is refunded"
+)
+
+// Decision is what happens to an order that was left in flight when the server stopped.
+type Decision struct {
+	OrderID string
+	From    orders.State // The state the order was left in
+	To      orders.State // The state the order is moved to, a re-queued order stays preparing
+	Reason  string
+}
+
+// Requeued checks if the order of the decision is handed back to the kitchen, the caller is expected
+// to put it on its terminal once the workers run.
+func (d Decision) Requeued() bool {
+	return d.From == orders.StatePreparing && d.To == orders.StatePreparing
+}
+
+// Decide returns the decision for the order of the given snapshot, or false if the order needs none.
+// The decision only depends on the state of the order and the policy, so it is the same on every start:
+//
+//   - the orders that were not paid yet are cancelled, nothing was taken from the customer.
+//   - the orders that were being paid are failed and refunded, the payment may or may not have happened.
+//   - the orders that were paid and being prepared are re-queued, so they stay preparing, or refunded,
+//     according to the policy.
+//   - the orders that are ready or final are left as they are.
+func Decide(s orders.Snapshot, policy Policy) (Decision, bool) {
+	d := Decision{OrderID: s.ID, From: s.State}
+
+	switch s.State {
+	case orders.StateReceived, orders.StateQueued, orders.StateValidating:
+		d.To, d.Reason = orders.StateCancelled, ReasonNotPaid
+	case orders.StatePaying:
+		d.To, d.Reason = orders.StateFailed, ReasonPaying
+	case orders.StatePreparing:
+		if policy == Refund {
+			d.To, d.Reason = orders.StateFailed, ReasonRefunded
+		} else {
+			d.To, d.Reason = orders.StatePreparing, ReasonRequeued
+		}
+	default:
+		return Decision{}, false
+	}
+	return d, true
+}
+
+// Run resolves the orders of the store that were left in flight when the server stopped, and returns
+// the decisions that were made, the oldest order first. It is meant to run on start, before any order is placed.
+//
+// Every decision is logged and recorded in the journal, if there is one. A cancelled or failed order is saved
+// in the store with a transition that carries the reason, so the history of the order tells why it was moved.
+// A re-queued order is left as it is, the caller hands it back to the kitchen. An order that can not be recovered
+// stops the run.
+func Run(s store.OrderStore, j *journal.Journal, logger *slog.Logger, policy Policy) ([]Decision, error) {
+	snapshots, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var decisions []Decision
+	for _, snapshot := range snapshots {
+		d, ok := Decide(snapshot, policy)
+		if !ok {
+			continue
+		}
+
+		recovered := snapshot
+		if !d.Requeued() {
+			order := orders.Restore(snapshot)
+			if err = order.Recover(d.To, d.Reason); err != nil {
+				return decisions, err
+			}
+			recovered = order.Snapshot()
+			if err = s.Save(recovered); err != nil {
+				return decisions, err
+			}
+		}
+
+		if j != nil {
+			if err = j.Record(journal.Recovery(recovered, d.From, d.Reason, time.Now())); err != nil {
+				return decisions, err
+			}
+		}
+		logger.Info("recovered order",
+			slog.String("order_id", d.OrderID),
+			slog.String("terminal_id", snapshot.TerminalID),
+			slog.String("from", d.From.String()),
+			slog.String("to", d.To.String()),
+			slog.String("reason", d.Reason),
+			slog.Int("refunded_cents", refunded(recovered)),
+			slog.String("policy", policy.String()),
+		)
+		decisions = append(decisions, d)
+	}
+	return decisions, nil
+}
+
+// refunded returns the money given back to the customer of a recovered order, a re-queued order keeps its change
+func refunded(s orders.Snapshot) int {
+	if s.State != orders.StateCancelled && s.State != orders.StateFailed {
+		return 0
+	}
+	return s.Returned.Cents
+}
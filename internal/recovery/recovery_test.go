@@ -0,0 +1,119 @@
+package recovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/azhovan/currywurst/internal/cashregister"
+	"github.com/azhovan/currywurst/internal/journal"
+	"github.com/azhovan/currywurst/internal/orders"
+	"github.com/azhovan/currywurst/internal/store"
+)
+
+// orderIn returns an order that got as far as the given state, saved in the given store
+func orderIn(t *testing.T, s store.OrderStore, state orders.State) *orders.Order {
+	t.Helper()
+
+	order := orders.NewOrder(context.TODO(), 50, orders.Vegan)
+	if err := store.Track(s, order, slog.Default()); err != nil {
+		t.Fatalf("store.Track() got error:%v, want nil", err)
+	}
+	for _, next := range []orders.State{orders.StateQueued, orders.StateValidating, orders.StatePaying, orders.StatePreparing, orders.StateReady} {
+		if order.State() == state {
+			break
+		}
+		if next == orders.StatePreparing {
+			order.Paid(cashregister.ReturnedAmount{Cents: 20, Formatted: "20 Cent"})
+			continue
+		}
+		order.Transition(next)
+	}
+	return order
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name         string
+		state        orders.State
+		policy       Policy
+		wantState    orders.State
+		wantReturned int
+		wantDecision bool
+	}{
+		{"received order is cancelled", orders.StateReceived, Requeue, orders.StateCancelled, 50, true},
+		{"queued order is cancelled", orders.StateQueued, Requeue, orders.StateCancelled, 50, true},
+		{"order being paid is refunded", orders.StatePaying, Requeue, orders.StateFailed, 50, true},
+		{"paid order is re-queued", orders.StatePreparing, Requeue, orders.StatePreparing, 20, true},
+		{"paid order is refunded", orders.StatePreparing, Refund, orders.StateFailed, 50, true},
+		{"ready order is left alone", orders.StateReady, Refund, orders.StateReady, 20, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := store.NewMemory()
+			order := orderIn(t, s, tt.state)
+			var buf bytes.Buffer
+
+			decisions, err := Run(s, journal.New(&buf), slog.New(slog.NewTextHandler(io.Discard, nil)), tt.policy)
+			if err != nil {
+				t.Fatalf("Run() got error:%v, want nil", err)
+			}
+
+			snapshot, _ := s.Get(order.ID)
+			if snapshot.State != tt.wantState {
+				t.Errorf("Run() left the order in state:%s, want:%s", snapshot.State, tt.wantState)
+			}
+			if snapshot.Returned.Cents != tt.wantReturned {
+				t.Errorf("Run() returned %d cents, want:%d", snapshot.Returned.Cents, tt.wantReturned)
+			}
+
+			if !tt.wantDecision {
+				if len(decisions) != 0 || buf.Len() != 0 {
+					t.Errorf("Run() got decisions:%v journal:%q, want none", decisions, buf.String())
+				}
+				return
+			}
+			if len(decisions) != 1 || decisions[0].From != tt.state || decisions[0].To != tt.wantState {
+				t.Fatalf("Run() got decisions:%v, want the order moved from %s to %s", decisions, tt.state, tt.wantState)
+			}
+
+			// the decision is recorded in the journal
+			var entry journal.Entry
+			if err := json.Unmarshal(buf.Bytes(), &entry); err != nil || entry.Kind != journal.KindRecovery ||
+				entry.OrderID != order.ID || entry.To != tt.wantState.String() || entry.Reason != decisions[0].Reason {
+				t.Errorf("Run() recorded %q in the journal, want the decision", buf.String())
+			}
+
+			// the decision of a cancelled or failed order is recorded in the history of the order as well,
+			// a re-queued order is handed back to the kitchen as it is
+			if decisions[0].Requeued() {
+				return
+			}
+			if last := snapshot.History[len(snapshot.History)-1]; last.Reason != decisions[0].Reason {
+				t.Errorf("Run() recorded the reason:%q, want:%q", last.Reason, decisions[0].Reason)
+			}
+		})
+	}
+}
+
+func TestRun_Deterministic(t *testing.T) {
+	s := store.NewMemory()
+	orderIn(t, s, orders.StateQueued)
+	paid := orderIn(t, s, orders.StatePreparing)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	decisions, err := Run(s, nil, logger, Requeue)
+	if err != nil || len(decisions) != 2 {
+		t.Fatalf("Run() got %d decisions error:%v, want 2 decisions", len(decisions), err)
+	}
+
+	// the cancelled order is resolved, the re-queued order is re-queued again until it is ready
+	decisions, err = Run(s, nil, logger, Requeue)
+	if err != nil || len(decisions) != 1 || decisions[0].OrderID != paid.ID || !decisions[0].Requeued() {
+		t.Errorf("Run() got decisions:%v error:%v, want the paid order re-queued", decisions, err)
+	}
+}
@@ -0,0 +1,120 @@
+package routing
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/azhovan/currywurst/internal/terminals"
+)
+
+// ErrNoTerminal is the error returned when there is no terminal that accepts orders.
+var ErrNoTerminal = errors.New("no terminal accepts orders")
+
+// Policy is the way the router chooses a terminal.
+type Policy string
+
+// Define the routing policies
+const (
+	// LeastQueued chooses the terminal with the fewest queued orders, ties go to the first terminal by id.
+	LeastQueued Policy = "least-queued"
+	// RoundRobin chooses the terminals one after the other.
+	RoundRobin Policy = "round-robin"
+	// ConsistentHash chooses the terminal by the key of the customer, so a customer keeps getting the same
+	// terminal, and only the customers of a terminal that is added or removed move to another one.
+	ConsistentHash Policy = "consistent-hash"
+)
+
+// replicas is the number of points every terminal has on the hash ring, so the keys are spread evenly
+const replicas = 64
+
+// Router chooses the terminal an order is sent to, when the customer did not choose one.
+// Only the terminals that accept orders, that is neither paused nor closed, are chosen.
+// It is safe for concurrent use.
+type Router struct {
+	policy Policy
+	// next is the number of orders routed round-robin so far
+	next atomic.Uint64
+}
+
+// NewRouter creates and returns a new router with the given policy.
+func NewRouter(policy Policy) (*Router, error) {
+	switch policy {
+	case LeastQueued, RoundRobin, ConsistentHash:
+	default:
+		return nil, fmt.Errorf("invalid routing policy: %q", policy)
+	}
+	return &Router{policy: policy}, nil
+}
+
+// Policy returns the policy of the router.
+func (r *Router) Policy() Policy {
+	return r.policy
+}
+
+// Route chooses a terminal of the registry and returns its id along with the terminal.
+// The key identifies the customer, it is only used by the ConsistentHash policy.
+// It returns ErrNoTerminal if no terminal accepts orders.
+func (r *Router) Route(registry *terminals.Registry, key string) (string, *terminals.Terminal, error) {
+	var ids []string
+	candidates := map[string]*terminals.Terminal{}
+	for _, id := range registry.IDs() {
+		terminal, ok := registry.Get(id)
+		// only the open terminals accept orders, the paused, closed and maintained ones are left out
+		if !ok || terminal.Status().State != terminals.StateOpen {
+			continue
+		}
+		ids = append(ids, id)
+		candidates[id] = terminal
+	}
+	if len(ids) == 0 {
+		return "", nil, ErrNoTerminal
+	}
+
+	var id string
+	switch r.policy {
+	case RoundRobin:
+		id = ids[(r.next.Add(1)-1)%uint64(len(ids))]
+	case ConsistentHash:
+		id = onRing(ids, key)
+	default:
+		id = ids[0]
+		for _, candidate := range ids[1:] {
+			if candidates[candidate].Len() < candidates[id].Len() {
+				id = candidate
+			}
+		}
+	}
+	return id, candidates[id], nil
+}
+
+// onRing returns the id whose point on the hash ring comes first after the hash of the key
+func onRing(ids []string, key string) string {
+	type point struct {
+		hash uint32
+		id   string
+	}
+
+	ring := make([]point, 0, len(ids)*replicas)
+	for _, id := range ids {
+		for i := 0; i < replicas; i++ {
+			ring = append(ring, point{crc32.ChecksumIEEE([]byte(id + "#" + strconv.Itoa(i))), id})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool {
+		if ring[i].hash != ring[j].hash {
+			return ring[i].hash < ring[j].hash
+		}
+		return ring[i].id < ring[j].id
+	})
+
+	hash := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(ring), func(i int) bool { return ring[i].hash >= hash })
+	if i == len(ring) {
+		i = 0
+	}
+	return ring[i].id
+}
@@ -0,0 +1,93 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/azhovan/currywurst/internal/orders"
+	"github.com/azhovan/currywurst/internal/terminals"
+)
+
+// newRegistry returns a registry of the given terminals, without workers
+func newRegistry(t *testing.T, ids ...string) *terminals.Registry {
+	t.Helper()
+
+	registry, err := terminals.NewRegistry(10, nil)
+	if err != nil {
+		t.Fatalf("terminals.NewRegistry() got error:%v, want nil", err)
+	}
+	for _, id := range ids {
+		if _, err = registry.Add(id); err != nil {
+			t.Fatalf("registry.Add() got error:%v, want nil", err)
+		}
+	}
+	return registry
+}
+
+func TestRouter_LeastQueued(t *testing.T) {
+	registry := newRegistry(t, "terminal-0", "terminal-1", "terminal-2")
+	busy, _ := registry.Get("terminal-0")
+	busy.Put(orders.NewOrder(context.TODO(), 50, orders.Vegan))
+	paused, _ := registry.Get("terminal-1")
+	paused.Pause()
+
+	router, _ := NewRouter(LeastQueued)
+	id, terminal, err := router.Route(registry, "")
+	if err != nil {
+		t.Fatalf("router.Route() got error:%v, want nil", err)
+	}
+	if want, _ := registry.Get("terminal-2"); id != "terminal-2" || terminal != want {
+		t.Errorf("router.Route() got:%s, want:%s", id, "terminal-2")
+	}
+}
+
+func TestRouter_RoundRobin(t *testing.T) {
+	registry := newRegistry(t, "terminal-0", "terminal-1", "terminal-2")
+	router, _ := NewRouter(RoundRobin)
+
+	for i, want := range []string{"terminal-0", "terminal-1", "terminal-2", "terminal-0"} {
+		if id, _, _ := router.Route(registry, ""); id != want {
+			t.Errorf("router.Route() #%d got:%s, want:%s", i, id, want)
+		}
+	}
+}
+
+func TestRouter_ConsistentHash(t *testing.T) {
+	registry := newRegistry(t, "terminal-0", "terminal-1", "terminal-2")
+	router, _ := NewRouter(ConsistentHash)
+
+	before := map[string]string{}
+	for i := 0; i < 300; i++ {
+		key := "customer-" + strconv.Itoa(i)
+		before[key], _, _ = router.Route(registry, key)
+		if again, _, _ := router.Route(registry, key); again != before[key] {
+			t.Fatalf("router.Route() sent %s to %s and then to %s", key, before[key], again)
+		}
+	}
+
+	// only the customers of the new terminal move
+	registry.Add("terminal-3")
+	for key, id := range before {
+		if moved, _, _ := router.Route(registry, key); moved != id && moved != "terminal-3" {
+			t.Errorf("router.Route() moved %s from %s to %s", key, id, moved)
+		}
+	}
+}
+
+func TestRouter_NoTerminal(t *testing.T) {
+	registry := newRegistry(t, "terminal-0", "terminal-1")
+	terminal, _ := registry.Get("terminal-0")
+	terminal.Close()
+	maintained, _ := registry.Get("terminal-1")
+	maintained.SetState(terminals.StateMaintenance, "coin acceptor jammed")
+
+	router, _ := NewRouter(LeastQueued)
+	if _, _, err := router.Route(registry, ""); !errors.Is(err, ErrNoTerminal) {
+		t.Errorf("router.Route() got error:%v, want:%v", err, ErrNoTerminal)
+	}
+	if _, err := NewRouter("random"); err == nil {
+		t.Errorf("NewRouter() got nil error, want an invalid policy")
+	}
+}
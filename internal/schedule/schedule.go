@@ -0,0 +1,272 @@
+package schedule
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/azhovan/currywurst/internal/clock"
+	"github.com/azhovan/currywurst/internal/orders"
+)
+
+// lookAhead is the number of days ahead that are searched for the next opening time.
+const lookAhead = 14
+
+// TimeOfDay is a time of the day, in minutes since midnight.
+type TimeOfDay int
+
+// At returns the time of the day for the given hour and minute.
+func At(hour, minute int) TimeOfDay {
+	return TimeOfDay(hour*60 + minute)
+}
+
+// String returns the time of the day in the 15:04 format.
+func (t TimeOfDay) String() string {
+	return fmt.Sprintf("%02d:%02d", int(t)/60, int(t)%60)
+}
+
+// timeOfDay returns the time of the day of the given time
+func timeOfDay(t time.Time) TimeOfDay {
+	return At(t.Hour(), t.Minute())
+}
+
+// Window is a period of time within a day, from Start (inclusive) to End (exclusive).
+// A window that ends before it starts spans midnight, i.e. from 22:00 to 02:00.
+type Window struct {
+	Start, End TimeOfDay
+}
+
+// spansMidnight reports whether the window continues on the next day
+func (w Window) spansMidnight() bool {
+	return w.End <= w.Start
+}
+
+// Holiday is a day the stand is closed. A zero Year makes the holiday recur every year.
+type Holiday struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// matches reports whether the holiday falls on the day of the given time
+func (h Holiday) matches(t time.Time) bool {
+	year, month, day := t.Date()
+	return (h.Year == 0 || h.Year == year) && h.Month == month && h.Day == day
+}
+
+// PriceOverride changes the unit price of the products within a time window,
+// i.e. a happy hour discount or a late-night surcharge.
+type PriceOverride struct {
+	Name    string           // The name of the override, it is listed next to the overridden items
+	Days    []time.Weekday   // The days the override applies on, every day when empty
+	Window  Window           // The time window the override applies within
+	Product orders.OrderType // The product whose price is overridden, every product when empty
+	Percent int              // The percentage added to the unit price, negative for a discount
+}
+
+// applies reports whether the override applies to the product at the given time
+func (p PriceOverride) applies(product orders.OrderType, t time.Time) bool {
+	if p.Product != "" && p.Product != product {
+		return false
+	}
+	return within(p.Days, p.Window, t)
+}
+
+// ClosedError is the error returned when an order is placed while the stand is closed.
+type ClosedError struct {
+	Reason  string    // Why the stand is closed, i.e. a holiday or outside the opening hours
+	OpensAt time.Time // The next opening time, zero if the stand does not open within the next two weeks
+}
+
+// Error returns the error message for ClosedError.
+func (e *ClosedError) Error() string {
+	if e.OpensAt.IsZero() {
+		return fmt.Sprintf("the stand is closed (%s)", e.Reason)
+	}
+	return fmt.Sprintf("the stand is closed (%s), it opens on %s", e.Reason, e.OpensAt.Format("Mon 02 Jan 15:04"))
+}
+
+// Schedule holds the opening hours, the holidays and the price overrides of the stand.
+// A schedule without opening hours is open around the clock.
+type Schedule struct {
+	clock     clock.Clock
+	location  *time.Location
+	hours     map[time.Weekday][]Window
+	holidays  []Holiday
+	overrides []PriceOverride
+}
+
+// Option is a function that modifies the schedule
+type Option func(*Schedule)
+
+// WithLocation sets the time zone the schedule is defined in, it defaults to the local time zone.
+func WithLocation(location *time.Location) Option {
+	return func(s *Schedule) {
+		s.location = location
+	}
+}
+
+// WithOpeningHours sets the opening hours for the given day of the week.
+// A day without opening hours is a closing day, as long as any other day has opening hours.
+func WithOpeningHours(day time.Weekday, windows ...Window) Option {
+	return func(s *Schedule) {
+		s.hours[day] = append(s.hours[day], windows...)
+	}
+}
+
+// WithHolidays adds days the stand is closed on, regardless of the opening hours.
+func WithHolidays(holidays ...Holiday) Option {
+	return func(s *Schedule) {
+		s.holidays = append(s.holidays, holidays...)
+	}
+}
+
+// WithPriceOverrides adds price overrides to the schedule.
+// When several overrides apply to the same product, the first one wins.
+func WithPriceOverrides(overrides ...PriceOverride) Option {
+	return func(s *Schedule) {
+		s.overrides = append(s.overrides, overrides...)
+	}
+}
+
+// NewSchedule creates a new schedule that tells the time using the given clock.
+func NewSchedule(c clock.Clock, opts ...Option) *Schedule {
+	s := &Schedule{
+		clock:    c,
+		location: time.Local,
+		hours:    map[time.Weekday][]Window{},
+	}
+
+	// apply the options
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// now returns the current time in the location of the schedule
+func (s *Schedule) now() time.Time {
+	return s.clock.Now().In(s.location)
+}
+
+// CheckOpen returns nil if the stand is open right now, or a *ClosedError otherwise.
+func (s *Schedule) CheckOpen() error {
+	now := s.now()
+	if s.isHoliday(now) {
+		return &ClosedError{Reason: "holiday", OpensAt: s.nextOpening(now)}
+	}
+	if !s.isOpen(now) {
+		return &ClosedError{Reason: "outside opening hours", OpensAt: s.nextOpening(now)}
+	}
+	return nil
+}
+
+// Apply overrides the unit prices of the items of the order, according to the price overrides
+// that apply right now. It should be called before the pricing rules are evaluated over the basket.
+func (s *Schedule) Apply(order *orders.Order) {
+	if order == nil {
+		return
+	}
+
+	now := s.now()
+	for i, item := range order.Items {
+		for _, override := range s.overrides {
+			if !override.applies(item.OrderType, now) {
+				continue
+			}
+
+			// the adjustment is rounded half away from zero, so a discount and a surcharge of
+			// the same percentage move the price by the same amount
+			adjustment := item.UnitPrice * override.Percent
+			if adjustment >= 0 {
+				adjustment = (adjustment + 50) / 100
+			} else {
+				adjustment = (adjustment - 50) / 100
+			}
+
+			order.Items[i].UnitPrice = max(item.UnitPrice+adjustment, 0)
+			order.Items[i].PriceOverride = override.Name
+			break
+		}
+	}
+}
+
+// isHoliday reports whether the given time falls on a holiday
+func (s *Schedule) isHoliday(t time.Time) bool {
+	for _, holiday := range s.holidays {
+		if holiday.matches(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// isOpen reports whether the given time is within the opening hours, regardless of the holidays
+func (s *Schedule) isOpen(t time.Time) bool {
+	if len(s.hours) == 0 {
+		return true
+	}
+
+	// the opening hours of the day before may continue after midnight,
+	// so the windows of every day are checked
+	for day, windows := range s.hours {
+		for _, window := range windows {
+			if within([]time.Weekday{day}, window, t) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// nextOpening returns the next time after t the stand opens, or the zero time if it does not
+// open within the look ahead period
+func (s *Schedule) nextOpening(t time.Time) time.Time {
+	year, month, day := t.Date()
+	for d := 0; d <= lookAhead; d++ {
+		midnight := time.Date(year, month, day+d, 0, 0, 0, 0, s.location)
+		if s.isHoliday(midnight) {
+			continue
+		}
+
+		var next time.Time
+		for _, window := range s.hours[midnight.Weekday()] {
+			opening := midnight.Add(time.Duration(window.Start) * time.Minute)
+			if opening.After(t) && (next.IsZero() || opening.Before(next)) {
+				next = opening
+			}
+		}
+		if !next.IsZero() {
+			return next
+		}
+	}
+
+	return time.Time{}
+}
+
+// within reports whether the given time is within the window on one of the given days.
+// A window that spans midnight belongs to the day it starts on.
+func within(days []time.Weekday, window Window, t time.Time) bool {
+	tod := timeOfDay(t)
+	onDay := func(day time.Weekday) bool {
+		if len(days) == 0 {
+			return true
+		}
+		for _, d := range days {
+			if d == day {
+				return true
+			}
+		}
+		return false
+	}
+
+	if !window.spansMidnight() {
+		return onDay(t.Weekday()) && tod >= window.Start && tod < window.End
+	}
+
+	if tod >= window.Start {
+		return onDay(t.Weekday())
+	}
+	return tod < window.End && onDay(t.AddDate(0, 0, -1).Weekday())
+}
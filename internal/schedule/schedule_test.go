@@ -0,0 +1,167 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/azhovan/currywurst/internal/clock"
+	"github.com/azhovan/currywurst/internal/orders"
+)
+
+// fixedClock returns a clock that always tells the given time
+func fixedClock(t time.Time) clock.Clock {
+	return clock.Func(func() time.Time { return t })
+}
+
+func TestSchedule_CheckOpen(t *testing.T) {
+	opts := []Option{
+		WithLocation(time.UTC),
+		WithOpeningHours(time.Monday, Window{Start: At(11, 0), End: At(22, 0)}),
+		// friday night is open until 2 in the morning
+		WithOpeningHours(time.Friday, Window{Start: At(11, 0), End: At(2, 0)}),
+		WithOpeningHours(time.Saturday, Window{Start: At(12, 0), End: At(22, 0)}),
+		WithHolidays(Holiday{Month: time.December, Day: 25}),
+	}
+
+	tests := []struct {
+		name        string
+		now         time.Time
+		wantClosed  bool
+		wantReason  string
+		wantOpensAt time.Time
+	}{
+		{
+			name: "within opening hours",
+			now:  time.Date(2023, time.October, 16, 12, 0, 0, 0, time.UTC), // monday
+		},
+		{
+			name:        "before opening hours",
+			now:         time.Date(2023, time.October, 16, 10, 59, 0, 0, time.UTC),
+			wantClosed:  true,
+			wantReason:  "outside opening hours",
+			wantOpensAt: time.Date(2023, time.October, 16, 11, 0, 0, 0, time.UTC),
+		},
+		{
+			name:        "closing time is exclusive",
+			now:         time.Date(2023, time.October, 16, 22, 0, 0, 0, time.UTC),
+			wantClosed:  true,
+			wantReason:  "outside opening hours",
+			wantOpensAt: time.Date(2023, time.October, 20, 11, 0, 0, 0, time.UTC), // friday
+		},
+		{
+			name: "opening hours spanning midnight",
+			now:  time.Date(2023, time.October, 21, 1, 30, 0, 0, time.UTC), // saturday night
+		},
+		{
+			name:        "after opening hours spanning midnight",
+			now:         time.Date(2023, time.October, 21, 2, 0, 0, 0, time.UTC),
+			wantClosed:  true,
+			wantReason:  "outside opening hours",
+			wantOpensAt: time.Date(2023, time.October, 21, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name:        "recurring holiday",
+			now:         time.Date(2023, time.December, 25, 12, 0, 0, 0, time.UTC), // monday
+			wantClosed:  true,
+			wantReason:  "holiday",
+			wantOpensAt: time.Date(2023, time.December, 29, 11, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewSchedule(fixedClock(tt.now), opts...).CheckOpen()
+			if !tt.wantClosed {
+				if err != nil {
+					t.Fatalf("schedule.CheckOpen() got error:%v, want nil", err)
+				}
+				return
+			}
+
+			var closed *ClosedError
+			if !errors.As(err, &closed) {
+				t.Fatalf("schedule.CheckOpen() got error:%v, want:%T", err, closed)
+			}
+			if closed.Reason != tt.wantReason {
+				t.Errorf("schedule.CheckOpen() got reason:%s, want:%s", closed.Reason, tt.wantReason)
+			}
+			if !closed.OpensAt.Equal(tt.wantOpensAt) {
+				t.Errorf("schedule.CheckOpen() got opens at:%v, want:%v", closed.OpensAt, tt.wantOpensAt)
+			}
+		})
+	}
+}
+
+func TestSchedule_CheckOpenWithoutOpeningHours(t *testing.T) {
+	s := NewSchedule(fixedClock(time.Date(2023, time.October, 16, 3, 0, 0, 0, time.UTC)))
+	if err := s.CheckOpen(); err != nil {
+		t.Errorf("schedule.CheckOpen() got error:%v, want nil", err)
+	}
+}
+
+func TestSchedule_Apply(t *testing.T) {
+	opts := []Option{
+		WithLocation(time.UTC),
+		WithPriceOverrides(
+			PriceOverride{
+				Name:    "happy hour",
+				Days:    []time.Weekday{time.Monday, time.Tuesday},
+				Window:  Window{Start: At(15, 0), End: At(17, 0)},
+				Product: orders.Vegan,
+				Percent: -20,
+			},
+			PriceOverride{
+				Name:    "late night",
+				Window:  Window{Start: At(23, 0), End: At(3, 0)},
+				Percent: 10,
+			},
+		),
+	}
+
+	tests := []struct {
+		name         string
+		now          time.Time
+		wantPrices   []int
+		wantOverride []string
+	}{
+		{
+			name:         "happy hour",
+			now:          time.Date(2023, time.October, 16, 16, 0, 0, 0, time.UTC), // monday
+			wantPrices:   []int{24, 35},
+			wantOverride: []string{"happy hour", ""},
+		},
+		{
+			name:         "no happy hour on other days",
+			now:          time.Date(2023, time.October, 18, 16, 0, 0, 0, time.UTC), // wednesday
+			wantPrices:   []int{30, 35},
+			wantOverride: []string{"", ""},
+		},
+		{
+			name:         "late night surcharge after midnight",
+			now:          time.Date(2023, time.October, 18, 1, 0, 0, 0, time.UTC),
+			wantPrices:   []int{33, 39},
+			wantOverride: []string{"late night", "late night"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := orders.NewBasketOrder(context.TODO(), 100, []orders.Item{
+				{OrderType: orders.Vegan, Quantity: 1},
+				{OrderType: orders.NonVegan, Quantity: 1},
+			})
+
+			NewSchedule(fixedClock(tt.now), opts...).Apply(order)
+			for i, item := range order.Items {
+				if item.UnitPrice != tt.wantPrices[i] {
+					t.Errorf("item %d got unit price:%d, want:%d", i, item.UnitPrice, tt.wantPrices[i])
+				}
+				if item.PriceOverride != tt.wantOverride[i] {
+					t.Errorf("item %d got price override:%q, want:%q", i, item.PriceOverride, tt.wantOverride[i])
+				}
+			}
+		})
+	}
+}
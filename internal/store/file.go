@@ -0,0 +1,398 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/azhovan/currywurst/internal/orders"
+)
+
+// Define the names of the files of the file store
+const (
+	logFileName      = "orders.log"
+	snapshotFileName = "orders.snapshot"
+)
+
+// DefaultSnapshotEvery is the number of snapshots appended to the log before the log is compacted.
+const DefaultSnapshotEvery = 1000
+
+var (
+	// ErrCorrupted is the error returned when a file of the store can not be read back.
+	ErrCorrupted = errors.New("order store is corrupted")
+
+	// ErrStoreClosed is the error returned when a closed store is used.
+	ErrStoreClosed = errors.New("order store is closed")
+)
+
+// File is a durable order store that keeps the snapshots of the orders in a directory, so they survive restarts.
+//
+// Every snapshot is appended to a log as a line of JSON. Once the log holds enough records, the latest snapshot
+// of every order is written to a snapshot file and the log is started over, so the log does not grow forever.
+// On start the snapshot file is loaded and the log is replayed on top of it. A record that was cut short by a crash
+// is dropped from the end of the log. The latest snapshots are kept in memory as well, so reads never touch the disk.
+//
+// The records are written to the disk by a goroutine of the store, so a Save, which is called while the order
+// and its terminal are locked, does not wait for the disk: the records are queued without a limit, and the writer
+// takes all of them at once. Flush waits until the records are on the disk. A failed write is logged, and returned
+// by Flush and Close. The snapshots are still kept in memory afterwards, so the orders are served as they are.
+// It is safe for concurrent use.
+type File struct {
+	mu      sync.RWMutex
+	dir     string
+	log     *os.File
+	orders  map[string]orders.Snapshot
+	closed  bool
+	pending []record      // the records that wait to be appended to the log, in the order they were saved
+	wake    chan struct{} // tells the writer there are pending records, or the store is closed
+	done    chan struct{} // closed once the writer stopped
+	logger  *slog.Logger
+
+	errMu sync.Mutex
+	err   error // the first error of the writer
+
+	// snapshotEvery is the number of records appended to the log before it is compacted
+	snapshotEvery int
+	// sync tells whether the log is flushed to the disk after every record
+	sync bool
+}
+
+// record is a snapshot of an order as it is appended to the log, or a request to flush the log
+type record struct {
+	id      string
+	data    []byte
+	flushed chan error // set for a flush request, it gets the error of the writer once the log is flushed
+}
+
+// FileOption is a function that modifies the file store
+type FileOption func(*File)
+
+// WithSnapshotEvery sets the number of records appended to the log before it is compacted into a snapshot
+func WithSnapshotEvery(n int) FileOption {
+	return func(f *File) {
+		f.snapshotEvery = n
+	}
+}
+
+// WithoutSync stops flushing the log to the disk after every record. It is faster,
+// but the last records may be lost when the machine, not only the process, goes down.
+func WithoutSync() FileOption {
+	return func(f *File) {
+		f.sync = false
+	}
+}
+
+// WithLogger sets the logger the first failed write of the store is logged with
+func WithLogger(logger *slog.Logger) FileOption {
+	return func(f *File) {
+		f.logger = logger
+	}
+}
+
+// NewFile opens the file store in the given directory, the directory is created if it does not exist.
+// The orders that were saved before are loaded from the directory.
+func NewFile(dir string, opts ...FileOption) (*File, error) {
+	f := &File{
+		dir:           dir,
+		orders:        map[string]orders.Snapshot{},
+		wake:          make(chan struct{}, 1),
+		done:          make(chan struct{}),
+		logger:        slog.Default(),
+		snapshotEvery: DefaultSnapshotEvery,
+		sync:          true,
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	// the snapshot file is written at once, so it is never cut short
+	if _, err := f.load(snapshotFileName, false); err != nil {
+		return nil, err
+	}
+
+	// the log may end with a record that was cut short by a crash, it is dropped
+	logPath := filepath.Join(dir, logFileName)
+	valid, err := f.load(logFileName, true)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(logPath); err == nil && info.Size() > valid {
+		if err := os.Truncate(logPath, valid); err != nil {
+			return nil, err
+		}
+	}
+
+	f.log, err = os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	// the writer compacts the log on its own, so it keeps the latest record of every order
+	latest := make(map[string][]byte, len(f.orders))
+	for id, snapshot := range f.orders {
+		if latest[id], err = json.Marshal(snapshot); err != nil {
+			f.log.Close()
+			return nil, err
+		}
+	}
+	go f.write(latest)
+	return f, nil
+}
+
+// load reads the records of the given file into the store, and returns the size of the records that are complete.
+// A missing file has no records. When tolerateTail is set, a last record without a line break is ignored.
+func (f *File) load(name string, tolerateTail bool) (int64, error) {
+	file, err := os.Open(filepath.Join(f.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var valid int64
+	r := bufio.NewReader(file)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 && !tolerateTail {
+				return 0, fmt.Errorf("%w: %s ends with an incomplete record", ErrCorrupted, name)
+			}
+			return valid, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		var snapshot orders.Snapshot
+		if err := json.Unmarshal(bytes.TrimSpace(line), &snapshot); err != nil || snapshot.ID == "" {
+			return 0, fmt.Errorf("%w: invalid record at offset %d of %s", ErrCorrupted, valid, name)
+		}
+		f.orders[snapshot.ID] = snapshot
+		valid += int64(len(line))
+	}
+}
+
+// Save keeps the snapshot of an order and queues it for the writer, which appends it to the log
+// and compacts the log once it holds enough records. It never waits for the writer.
+func (f *File) Save(snapshot orders.Snapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return ErrStoreClosed
+	}
+	f.orders[snapshot.ID] = snapshot
+	f.pending = append(f.pending, record{id: snapshot.ID, data: data})
+	f.mu.Unlock()
+
+	f.notify()
+	return nil
+}
+
+// Flush waits until the snapshots saved so far are appended to the log, and flushed to the disk
+// unless the store is opened WithoutSync. It returns the error of the writer, if any.
+func (f *File) Flush() error {
+	flushed := make(chan error, 1)
+
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return ErrStoreClosed
+	}
+	f.pending = append(f.pending, record{flushed: flushed})
+	f.mu.Unlock()
+
+	f.notify()
+	return <-flushed
+}
+
+// notify wakes the writer up, a wake-up that is already pending covers the new records as well
+func (f *File) notify() {
+	select {
+	case f.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Get returns the latest snapshot of the order with the given id, or ErrOrderNotFound if there is none.
+func (f *File) Get(id string) (orders.Snapshot, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	snapshot, ok := f.orders[id]
+	if !ok {
+		return orders.Snapshot{}, ErrOrderNotFound
+	}
+	return snapshot, nil
+}
+
+// List returns the latest snapshot of every order, the oldest order first.
+func (f *File) List() ([]orders.Snapshot, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	snapshots := make([]orders.Snapshot, 0, len(f.orders))
+	for _, snapshot := range f.orders {
+		snapshots = append(snapshots, snapshot)
+	}
+	sortByCreation(snapshots)
+	return snapshots, nil
+}
+
+// Close waits for the writer, compacts the log, so the next start is quick, and closes the files of the store.
+func (f *File) Close() error {
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return nil
+	}
+	f.closed = true
+	f.mu.Unlock()
+
+	f.notify()
+
+	<-f.done
+	return f.writeErr()
+}
+
+// write appends the records to the log until the store is closed, it takes all the pending records at once
+// and syncs the log once for them. The latest record of every order is kept in latest.
+// Once a write failed nothing is appended anymore, so the log does not end up with a broken record
+// in the middle, but the log is still compacted on close, which writes every record it missed.
+func (f *File) write(latest map[string][]byte) {
+	defer close(f.done)
+
+	appended := 0
+	for range f.wake {
+		f.mu.Lock()
+		pending, closed := f.pending, f.closed
+		f.pending = nil
+		f.mu.Unlock()
+
+		var flushes []chan error
+		dirty := false
+		for _, r := range pending {
+			if r.flushed != nil {
+				flushes = append(flushes, r.flushed)
+				continue
+			}
+			latest[r.id] = r.data
+			if f.writeErr() != nil {
+				continue
+			}
+			if _, err := f.log.Write(append(r.data, '\n')); err != nil {
+				f.setErr(err)
+				continue
+			}
+			dirty = true
+
+			// the snapshot file is synced, so the log does not have to be
+			if appended++; f.snapshotEvery > 0 && appended >= f.snapshotEvery {
+				if err := f.compact(latest); err != nil {
+					f.setErr(err)
+				}
+				appended, dirty = 0, false
+			}
+		}
+
+		if dirty && f.sync {
+			if err := f.log.Sync(); err != nil {
+				f.setErr(err)
+			}
+		}
+
+		for _, flushed := range flushes {
+			flushed <- f.writeErr()
+		}
+
+		// nothing is saved once the store is closed, so these were the last records
+		if closed {
+			break
+		}
+	}
+
+	// the store is closed, everything is written to the snapshot file
+	err := f.compact(latest)
+	if closeErr := f.log.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		f.setErr(err)
+	}
+}
+
+// writeErr returns the first error of the writer
+func (f *File) writeErr() error {
+	f.errMu.Lock()
+	defer f.errMu.Unlock()
+	return f.err
+}
+
+// setErr records an error of the writer, only the first one is kept and logged
+func (f *File) setErr(err error) {
+	f.errMu.Lock()
+	defer f.errMu.Unlock()
+	if f.err == nil {
+		f.err = err
+		f.logger.Error("order log not written, the orders are only kept in memory until the store is closed",
+			slog.String("dir", f.dir),
+			slog.Any("err", err))
+	}
+}
+
+// compact writes the latest record of every order to the snapshot file and starts the log over,
+// it is only called by the writer.
+// The snapshot file is replaced atomically. If the process dies before the log is started over,
+// the log is replayed on top of the new snapshot file, which leads to the same snapshots.
+func (f *File) compact(latest map[string][]byte) error {
+	ids := make([]string, 0, len(latest))
+	for id := range latest {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	tmpPath := filepath.Join(f.dir, snapshotFileName+".tmp")
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(tmp)
+	for _, id := range ids {
+		w.Write(append(latest[id], '\n'))
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, filepath.Join(f.dir, snapshotFileName)); err != nil {
+		return err
+	}
+
+	return f.log.Truncate(0)
+}
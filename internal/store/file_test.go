@@ -0,0 +1,230 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/azhovan/currywurst/internal/cashregister"
+	"github.com/azhovan/currywurst/internal/orders"
+)
+
+// paidOrder returns an order that went through its whole lifecycle, tracked by the given store
+func paidOrder(t *testing.T, s OrderStore) *orders.Order {
+	t.Helper()
+
+	order := orders.NewOrder(context.TODO(), 50, orders.Vegan)
+	if err := Track(s, order, slog.Default()); err != nil {
+		t.Fatalf("Track() got error:%v, want nil", err)
+	}
+	order.Transition(orders.StateQueued)
+	order.Transition(orders.StateValidating)
+	order.Transition(orders.StatePaying)
+	order.Paid(cashregister.ReturnedAmount{Cents: 20, Formatted: "20 Cent"})
+	order.Transition(orders.StateReady)
+	return order
+}
+
+func TestFile_Reopen(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := NewFile(dir, WithoutSync())
+	if err != nil {
+		t.Fatalf("NewFile() got error:%v, want nil", err)
+	}
+	order := paidOrder(t, s)
+	if err := s.Flush(); err != nil {
+		t.Fatalf("file.Flush() got error:%v, want nil", err)
+	}
+
+	// the process exits without closing the store, the log is replayed
+	s, err = NewFile(dir)
+	if err != nil {
+		t.Fatalf("NewFile() got error:%v, want nil", err)
+	}
+	defer s.Close()
+
+	snapshot, err := s.Get(order.ID)
+	if err != nil {
+		t.Fatalf("file.Get() got error:%v, want nil", err)
+	}
+	if snapshot.State != orders.StateReady {
+		t.Errorf("file.Get() got state:%s, want:%s", snapshot.State, orders.StateReady)
+	}
+	if snapshot.Returned.Cents != 20 {
+		t.Errorf("file.Get() got returned:%d, want:%d", snapshot.Returned.Cents, 20)
+	}
+	if len(snapshot.History) != 6 {
+		t.Errorf("file.Get() got %d transitions, want:%d", len(snapshot.History), 6)
+	}
+	if snapshot.Items[0].OrderType != orders.Vegan || snapshot.Total != 30 {
+		t.Errorf("file.Get() got items:%v total:%d, want one vegan for 30", snapshot.Items, snapshot.Total)
+	}
+}
+
+func TestFile_Compact(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := NewFile(dir, WithSnapshotEvery(5))
+	if err != nil {
+		t.Fatalf("NewFile() got error:%v, want nil", err)
+	}
+	first := paidOrder(t, s)  // 6 records, the log is compacted once
+	second := paidOrder(t, s) // 6 more records, the log is compacted again and holds the last 2
+	if err := s.Flush(); err != nil {
+		t.Fatalf("file.Flush() got error:%v, want nil", err)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, logFileName))
+	if err != nil {
+		t.Fatalf("os.Stat() got error:%v, want nil", err)
+	}
+	if info.Size() == 0 {
+		t.Errorf("the log is empty, want the records appended since the last compaction")
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("file.Close() got error:%v, want nil", err)
+	}
+	if err := s.Save(first.Snapshot()); !errors.Is(err, ErrStoreClosed) {
+		t.Errorf("file.Save() got error:%v, want:%v", err, ErrStoreClosed)
+	}
+
+	// the log is empty after a clean close, everything is in the snapshot file
+	if info, _ := os.Stat(filepath.Join(dir, logFileName)); info.Size() != 0 {
+		t.Errorf("the log holds %d bytes after close, want it empty", info.Size())
+	}
+
+	s, err = NewFile(dir)
+	if err != nil {
+		t.Fatalf("NewFile() got error:%v, want nil", err)
+	}
+	defer s.Close()
+
+	for _, order := range []*orders.Order{first, second} {
+		if snapshot, err := s.Get(order.ID); err != nil || snapshot.State != orders.StateReady {
+			t.Errorf("file.Get() got state:%s error:%v, want state:%s", snapshot.State, err, orders.StateReady)
+		}
+	}
+}
+
+func TestFile_TornWrite(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := NewFile(dir)
+	if err != nil {
+		t.Fatalf("NewFile() got error:%v, want nil", err)
+	}
+	order := paidOrder(t, s)
+	s.Flush()
+
+	// the process died in the middle of a record
+	log, _ := os.OpenFile(filepath.Join(dir, logFileName), os.O_WRONLY|os.O_APPEND, 0o644)
+	log.Write([]byte(`{"ID":"cut-sh`))
+	log.Close()
+
+	s, err = NewFile(dir)
+	if err != nil {
+		t.Fatalf("NewFile() got error:%v, want nil", err)
+	}
+	if snapshot, err := s.Get(order.ID); err != nil || snapshot.State != orders.StateReady {
+		t.Errorf("file.Get() got state:%s error:%v, want state:%s", snapshot.State, err, orders.StateReady)
+	}
+
+	// the store keeps appending after the last complete record
+	next := paidOrder(t, s)
+	s.Close()
+
+	s, err = NewFile(dir)
+	if err != nil {
+		t.Fatalf("NewFile() got error:%v, want nil", err)
+	}
+	defer s.Close()
+	if _, err := s.Get(next.ID); err != nil {
+		t.Errorf("file.Get() got error:%v, want nil", err)
+	}
+}
+
+func TestFile_WriteError(t *testing.T) {
+	s, err := NewFile(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFile() got error:%v, want nil", err)
+	}
+
+	// the disk is gone under the store
+	s.log.Close()
+	order := orders.NewOrder(context.TODO(), 50, orders.Vegan)
+	s.Save(order.Snapshot())
+
+	if err := s.Flush(); err == nil {
+		t.Errorf("file.Flush() got error:nil, want the error of the write")
+	}
+
+	// the store keeps the orders in memory, they are still saved and served as they are
+	order.Transition(orders.StateQueued)
+	if err := s.Save(order.Snapshot()); err != nil {
+		t.Errorf("file.Save() got error:%v, want nil", err)
+	}
+	if got, err := s.Get(order.ID); err != nil || got.State != orders.StateQueued {
+		t.Errorf("file.Get() got state:%s error:%v, want:%s", got.State, err, orders.StateQueued)
+	}
+	if err := s.Close(); err == nil {
+		t.Errorf("file.Close() got error:nil, want the error of the write")
+	}
+}
+
+func TestFile_WriterHeldUp(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFile(dir)
+	if err != nil {
+		t.Fatalf("NewFile() got error:%v, want nil", err)
+	}
+
+	// the writer is held up, it can not tell whether a write failed, while far more records are saved
+	// than were ever buffered
+	s.errMu.Lock()
+	saved := make(chan struct{})
+	var last *orders.Order
+	go func() {
+		defer close(saved)
+		for i := 0; i < 5000; i++ {
+			last = orders.NewOrder(context.TODO(), 50, orders.Vegan)
+			s.Save(last.Snapshot())
+		}
+	}()
+	select {
+	case <-saved:
+	case <-time.After(5 * time.Second):
+		s.errMu.Unlock()
+		t.Fatalf("file.Save() blocked while the writer was held up")
+	}
+	if _, err := s.Get(last.ID); err != nil {
+		t.Errorf("file.Get() got error:%v, want nil", err)
+	}
+	s.errMu.Unlock()
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("file.Close() got error:%v, want nil", err)
+	}
+	reopened, err := NewFile(dir)
+	if err != nil {
+		t.Fatalf("NewFile() got error:%v, want nil", err)
+	}
+	defer reopened.Close()
+	if list, _ := reopened.List(); len(list) != 5000 {
+		t.Errorf("file.List() got %d orders, want:5000", len(list))
+	}
+}
+
+func TestFile_Corrupted(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, logFileName), []byte("not json\n"), 0o644)
+
+	if _, err := NewFile(dir); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("NewFile() got error:%v, want:%v", err, ErrCorrupted)
+	}
+}
@@ -0,0 +1,57 @@
+package store
+
+import (
+	"sync"
+
+	"github.com/azhovan/currywurst/internal/orders"
+)
+
+// Memory is an order store that keeps the snapshots of the orders in memory,
+// they are gone once the process exits. It is safe for concurrent use.
+type Memory struct {
+	mu     sync.RWMutex
+	orders map[string]orders.Snapshot
+}
+
+// NewMemory returns a new empty in-memory order store.
+func NewMemory() *Memory {
+	return &Memory{orders: map[string]orders.Snapshot{}}
+}
+
+// Save stores the snapshot of an order, replacing any older snapshot of the same order.
+func (m *Memory) Save(snapshot orders.Snapshot) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.orders[snapshot.ID] = snapshot
+	return nil
+}
+
+// Get returns the latest snapshot of the order with the given id, or ErrOrderNotFound if there is none.
+func (m *Memory) Get(id string) (orders.Snapshot, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	snapshot, ok := m.orders[id]
+	if !ok {
+		return orders.Snapshot{}, ErrOrderNotFound
+	}
+	return snapshot, nil
+}
+
+// List returns the latest snapshot of every order, the oldest order first.
+func (m *Memory) List() ([]orders.Snapshot, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	snapshots := make([]orders.Snapshot, 0, len(m.orders))
+	for _, snapshot := range m.orders {
+		snapshots = append(snapshots, snapshot)
+	}
+	sortByCreation(snapshots)
+	return snapshots, nil
+}
+
+// Close does nothing, the in-memory store holds no resources.
+func (m *Memory) Close() error {
+	return nil
+}
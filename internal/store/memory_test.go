@@ -0,0 +1,53 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/azhovan/currywurst/internal/orders"
+)
+
+func TestMemory_Get(t *testing.T) {
+	m := NewMemory()
+
+	_, err := m.Get("unknown")
+	if !errors.Is(err, ErrOrderNotFound) {
+		t.Fatalf("memory.Get() got error:%v, want:%v", err, ErrOrderNotFound)
+	}
+
+	order := orders.NewOrder(context.TODO(), 50, orders.Vegan)
+	if err = m.Save(order.Snapshot()); err != nil {
+		t.Fatalf("memory.Save() got error:%v, want nil", err)
+	}
+
+	snapshot, err := m.Get(order.ID)
+	if err != nil {
+		t.Fatalf("memory.Get() got error:%v, want nil", err)
+	}
+	if snapshot.ID != order.ID || snapshot.State != orders.StateReceived {
+		t.Errorf("memory.Get() got order %s in state %s, want order %s in state %s", snapshot.ID, snapshot.State, order.ID, orders.StateReceived)
+	}
+}
+
+func TestMemory_List(t *testing.T) {
+	m := NewMemory()
+
+	first := orders.NewOrder(context.TODO(), 50, orders.Vegan)
+	second := orders.NewOrder(context.TODO(), 50, orders.Fries)
+	// the second order is saved first, the list is still sorted by creation
+	m.Save(second.Snapshot())
+	m.Save(first.Snapshot())
+	m.Save(first.Snapshot())
+
+	snapshots, err := m.List()
+	if err != nil {
+		t.Fatalf("memory.List() got error:%v, want nil", err)
+	}
+	if len(snapshots) != 2 {
+		t.Fatalf("memory.List() got %d orders, want:%d", len(snapshots), 2)
+	}
+	if snapshots[0].ID != first.ID || snapshots[1].ID != second.ID {
+		t.Errorf("memory.List() got orders %s, %s, want %s, %s", snapshots[0].ID, snapshots[1].ID, first.ID, second.ID)
+	}
+}
@@ -0,0 +1,204 @@
+package store
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/azhovan/currywurst/internal/orders"
+)
+
+// Define the limits of a page of orders
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+)
+
+// ErrInvalidCursor is the error returned when the cursor of a query is not one returned by Find for the same sort.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Sort is the order the orders of a query are returned in.
+type Sort string
+
+// Define the sorts of a query
+const (
+	SortCreatedAsc  Sort = "createdAt"  // The oldest order first, the default
+	SortCreatedDesc Sort = "-createdAt" // The newest order first
+	SortTotalAsc    Sort = "total"      // The cheapest order first
+	SortTotalDesc   Sort = "-total"     // The most expensive order first
+)
+
+// IsValid reports whether the sort is one of the sorts of a query
+func (s Sort) IsValid() bool {
+	switch s {
+	case SortCreatedAsc, SortCreatedDesc, SortTotalAsc, SortTotalDesc:
+		return true
+	default:
+		return false
+	}
+}
+
+// Query selects the orders of a store. The zero value of a filter matches every order.
+type Query struct {
+	From       time.Time            // The orders created at or after the time
+	To         time.Time            // The orders created before the time
+	TerminalID string               // The orders sent to the terminal
+	States     []orders.State       // The orders in any of the states
+	Product    orders.OrderType     // The orders with at least one item of the type
+	Payment    orders.PaymentMethod // The orders paid with the method, an order without one is paid in cash
+	MinTotal   int                  // The orders that cost at least the amount, in cents
+	MaxTotal   *int                 // The orders that cost at most the amount, in cents, nil has no limit
+	Sort       Sort                 // The order of the orders, SortCreatedAsc by default
+	Limit      int                  // The size of the page, DefaultPageSize by default
+	Cursor     string               // The cursor of the previous page, empty for the first page
+}
+
+// Page is a page of the orders that match a query.
+type Page struct {
+	Orders []orders.Snapshot
+	// Next is the cursor of the next page, it is empty on the last page
+	Next string
+}
+
+// cursor points to the last order of a page. It holds the sort key of the order rather than its position,
+// so the pages stay consistent while new orders are saved.
+type cursor struct {
+	Sort  Sort      `json:"s"`
+	At    time.Time `json:"a,omitempty"`
+	Total int       `json:"t,omitempty"`
+	ID    string    `json:"i"`
+}
+
+// Find returns the page of the orders of the store that match the query.
+// It returns ErrInvalidCursor if the cursor was not returned by Find, or was returned for a different sort.
+func Find(s OrderStore, q Query) (Page, error) {
+	if q.Sort == "" {
+		q.Sort = SortCreatedAsc
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultPageSize
+	}
+	q.Limit = min(q.Limit, MaxPageSize)
+
+	var after *cursor
+	if q.Cursor != "" {
+		c, err := decodeCursor(q.Cursor)
+		if err != nil || c.Sort != q.Sort {
+			return Page{}, ErrInvalidCursor
+		}
+		after = &c
+	}
+
+	snapshots, err := s.List()
+	if err != nil {
+		return Page{}, err
+	}
+
+	matched := make([]orders.Snapshot, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		if q.matches(snapshot) {
+			matched = append(matched, snapshot)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		return less(q.Sort, keyOf(q.Sort, matched[i]), keyOf(q.Sort, matched[j]))
+	})
+
+	// the page starts right after the order of the cursor, even if that order no longer matches
+	start := 0
+	if after != nil {
+		start = sort.Search(len(matched), func(i int) bool {
+			return less(q.Sort, *after, keyOf(q.Sort, matched[i]))
+		})
+	}
+
+	end := min(start+q.Limit, len(matched))
+	page := Page{Orders: matched[start:end]}
+	if end < len(matched) {
+		page.Next = encodeCursor(keyOf(q.Sort, matched[end-1]))
+	}
+	return page, nil
+}
+
+// matches reports whether the snapshot matches the filters of the query
+func (q Query) matches(s orders.Snapshot) bool {
+	createdAt := s.CreatedAt()
+	if !q.From.IsZero() && createdAt.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !createdAt.Before(q.To) {
+		return false
+	}
+	if q.TerminalID != "" && s.TerminalID != q.TerminalID {
+		return false
+	}
+	if len(q.States) > 0 && !slices.Contains(q.States, s.State) {
+		return false
+	}
+	if q.Product != "" && !slices.ContainsFunc(s.Items, func(item orders.Item) bool { return item.OrderType == q.Product }) {
+		return false
+	}
+	if q.Payment != "" && s.Payment.String() != q.Payment.String() {
+		return false
+	}
+	if s.Total < q.MinTotal || (q.MaxTotal != nil && s.Total > *q.MaxTotal) {
+		return false
+	}
+	return true
+}
+
+// keyOf returns the sort key of the snapshot
+func keyOf(by Sort, s orders.Snapshot) cursor {
+	c := cursor{Sort: by, ID: s.ID}
+	if by == SortTotalAsc || by == SortTotalDesc {
+		c.Total = s.Total
+	} else {
+		c.At = s.CreatedAt()
+	}
+	return c
+}
+
+// less reports whether the key a comes before the key b, the orders with the same key are sorted by their id
+func less(by Sort, a, b cursor) bool {
+	switch by {
+	case SortCreatedDesc:
+		if !a.At.Equal(b.At) {
+			return a.At.After(b.At)
+		}
+	case SortTotalAsc:
+		if a.Total != b.Total {
+			return a.Total < b.Total
+		}
+	case SortTotalDesc:
+		if a.Total != b.Total {
+			return a.Total > b.Total
+		}
+	default:
+		if !a.At.Equal(b.At) {
+			return a.At.Before(b.At)
+		}
+	}
+	return a.ID < b.ID
+}
+
+// encodeCursor returns the opaque form of the cursor, that is handed to the client
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses the opaque form of the cursor
+func decodeCursor(value string) (cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return cursor{}, err
+	}
+	var c cursor
+	if err = json.Unmarshal(data, &c); err != nil || c.ID == "" {
+		return cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
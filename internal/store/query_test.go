@@ -0,0 +1,117 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/azhovan/currywurst/internal/orders"
+)
+
+// savedOrder saves an order of the given items, sent to the given terminal, in the given store
+func savedOrder(t *testing.T, s OrderStore, terminalId string, items ...orders.Item) orders.Snapshot {
+	t.Helper()
+
+	order := orders.NewBasketOrder(context.TODO(), 1000, items)
+	order.TerminalID = terminalId
+	snapshot := order.Snapshot()
+	if err := s.Save(snapshot); err != nil {
+		t.Fatalf("store.Save() got error:%v, want nil", err)
+	}
+	return snapshot
+}
+
+func TestFind(t *testing.T) {
+	s := NewMemory()
+	vegan := savedOrder(t, s, "terminal-1", orders.Item{OrderType: orders.Vegan, Quantity: 1})
+	fries := savedOrder(t, s, "terminal-2", orders.Item{OrderType: orders.Fries, Quantity: 1})
+	menu := savedOrder(t, s, "terminal-1", orders.Item{OrderType: orders.NonVegan, Quantity: 2}, orders.Item{OrderType: orders.Fries, Quantity: 1})
+	// an order that costs nothing, paid with another method than cash
+	free := savedOrder(t, s, "terminal-3")
+	free.Payment = "card"
+	s.Save(free)
+	zero := 0
+
+	tests := []struct {
+		name  string
+		query Query
+		want  []orders.Snapshot
+	}{
+		{"every order", Query{}, []orders.Snapshot{vegan, fries, menu, free}},
+		{"by terminal", Query{TerminalID: "terminal-1"}, []orders.Snapshot{vegan, menu}},
+		{"by product", Query{Product: orders.Fries}, []orders.Snapshot{fries, menu}},
+		{"by state", Query{States: []orders.State{orders.StateReady}}, nil},
+		{"by amount", Query{MinTotal: fries.Total + 1, MaxTotal: &vegan.Total}, []orders.Snapshot{vegan}},
+		{"free orders", Query{MaxTotal: &zero}, []orders.Snapshot{free}},
+		{"by payment", Query{Payment: orders.PaymentCash}, []orders.Snapshot{vegan, fries, menu}},
+		{"by time", Query{From: menu.CreatedAt()}, []orders.Snapshot{menu, free}},
+		{"before a time", Query{To: vegan.CreatedAt()}, nil},
+		{"newest first", Query{Sort: SortCreatedDesc}, []orders.Snapshot{free, menu, fries, vegan}},
+		{"most expensive first", Query{Sort: SortTotalDesc}, []orders.Snapshot{menu, vegan, fries, free}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := Find(s, tt.query)
+			if err != nil {
+				t.Fatalf("Find() got error:%v, want nil", err)
+			}
+			if len(page.Orders) != len(tt.want) {
+				t.Fatalf("Find() got %d orders, want:%d", len(page.Orders), len(tt.want))
+			}
+			for i := range tt.want {
+				if page.Orders[i].ID != tt.want[i].ID {
+					t.Errorf("Find() got order %s at %d, want:%s", page.Orders[i].ID, i, tt.want[i].ID)
+				}
+			}
+			if page.Next != "" {
+				t.Errorf("Find() got a next cursor on the last page")
+			}
+		})
+	}
+}
+
+func TestFind_Pages(t *testing.T) {
+	s := NewMemory()
+	var want []string
+	for i := 0; i < 5; i++ {
+		want = append(want, savedOrder(t, s, "terminal-1", orders.Item{OrderType: orders.Vegan, Quantity: 1}).ID)
+		time.Sleep(time.Millisecond)
+	}
+
+	var got []string
+	query := Query{Limit: 2, Sort: SortCreatedDesc}
+	for {
+		page, err := Find(s, query)
+		if err != nil {
+			t.Fatalf("Find() got error:%v, want nil", err)
+		}
+		for _, snapshot := range page.Orders {
+			got = append([]string{snapshot.ID}, got...)
+		}
+		if page.Next == "" {
+			break
+		}
+		// an order saved in the meantime does not shift the pages
+		savedOrder(t, s, "terminal-1", orders.Item{OrderType: orders.Vegan, Quantity: 1})
+		query.Cursor = page.Next
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("Find() got %d orders over all pages, want:%d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Find() got order %s at %d, want:%s", got[i], i, want[i])
+		}
+	}
+
+	// the cursor is bound to its sort
+	if _, err := Find(s, Query{Cursor: query.Cursor}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Find() got error:%v, want:%v", err, ErrInvalidCursor)
+	}
+	if _, err := Find(s, Query{Cursor: "not a cursor"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Find() got error:%v, want:%v", err, ErrInvalidCursor)
+	}
+}
@@ -0,0 +1,54 @@
+package store
+
+import (
+	"errors"
+	"log/slog"
+	"sort"
+
+	"github.com/azhovan/currywurst/internal/orders"
+)
+
+// ErrOrderNotFound is the error returned when there is no order with the given id in the store.
+var ErrOrderNotFound = errors.New("order not found")
+
+// OrderStore keeps the snapshots of the orders. It outlives the goroutines that process the orders,
+// so the orders can be looked up after the customer's request is gone.
+// The implementations are safe for concurrent use.
+type OrderStore interface {
+	// Save stores the snapshot of an order, replacing any older snapshot of the same order.
+	Save(snapshot orders.Snapshot) error
+	// Get returns the latest snapshot of the order with the given id, or ErrOrderNotFound if there is none.
+	Get(id string) (orders.Snapshot, error)
+	// List returns the latest snapshot of every order, the oldest order first.
+	List() ([]orders.Snapshot, error)
+	// Close releases the resources of the store, the store can not be used afterwards.
+	Close() error
+}
+
+// Track saves the order in the store and keeps it up to date, by saving a new snapshot after every transition.
+// The snapshot carries the items, the payment and the history of the order, so they are all written through the store.
+// The transition has already happened when its snapshot is saved, so a snapshot that can not be saved
+// is logged with the given logger, and the error of the first save is returned.
+func Track(s OrderStore, order *orders.Order, logger *slog.Logger) error {
+	order.Observe(func(snapshot orders.Snapshot) {
+		if err := s.Save(snapshot); err != nil {
+			logger.Error("order snapshot not saved",
+				slog.String("order_id", snapshot.ID),
+				slog.String("state", snapshot.State.String()),
+				slog.Any("err", err))
+		}
+	})
+	return s.Save(order.Snapshot())
+}
+
+// sortByCreation sorts the snapshots by the time the orders were created at, the oldest order first.
+// Orders created at the same time are sorted by their id, so the order of the list is stable.
+func sortByCreation(snapshots []orders.Snapshot) {
+	sort.Slice(snapshots, func(i, j int) bool {
+		a, b := snapshots[i].CreatedAt(), snapshots[j].CreatedAt()
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+		return snapshots[i].ID < snapshots[j].ID
+	})
+}
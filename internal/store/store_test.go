@@ -0,0 +1,35 @@
+package store
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/azhovan/currywurst/internal/orders"
+)
+
+func TestTrack(t *testing.T) {
+	m := NewMemory()
+	order := orders.NewOrder(context.TODO(), 50, orders.Vegan)
+	if err := Track(m, order, slog.Default()); err != nil {
+		t.Fatalf("Track() got error:%v, want nil", err)
+	}
+
+	order.Transition(orders.StateQueued)
+	order.Transition(orders.StateValidating)
+	order.Fail(orders.ErrInvalidOrderType)
+
+	snapshot, err := m.Get(order.ID)
+	if err != nil {
+		t.Fatalf("memory.Get() got error:%v, want nil", err)
+	}
+	if snapshot.State != orders.StateFailed {
+		t.Errorf("memory.Get() got state:%s, want:%s", snapshot.State, orders.StateFailed)
+	}
+	if snapshot.Error != orders.ErrInvalidOrderType.Error() {
+		t.Errorf("memory.Get() got error:%q, want:%q", snapshot.Error, orders.ErrInvalidOrderType.Error())
+	}
+	if len(snapshot.History) != 4 {
+		t.Errorf("memory.Get() got %d transitions, want:%d", len(snapshot.History), 4)
+	}
+}
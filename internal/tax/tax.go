@@ -0,0 +1,114 @@
+package tax
+
+import (
+	"sort"
+
+	"github.com/azhovan/currywurst/internal/orders"
+	"github.com/azhovan/currywurst/pkg"
+)
+
+// Define the German VAT rates in percent
+const (
+	ReducedRate  = 7  // The rate of the food that is taken away
+	StandardRate = 19 // The rate of the food that is eaten in and of the beverages
+)
+
+// Rate returns the VAT rate in percent of an order type of the given category.
+func Rate(category pkg.TaxCategory, eatIn bool) int {
+	if category == pkg.Food && !eatIn {
+		return ReducedRate
+	}
+	return StandardRate
+}
+
+// Amount is the VAT breakdown of the items of an order that are taxed at the same rate.
+// All the prices are gross prices, so Net + Tax always equals Gross.
+type Amount struct {
+	Rate  int `json:"rate"`  // The VAT rate in percent
+	Net   int `json:"net"`   // The net amount in cents
+	Tax   int `json:"tax"`   // The VAT amount in cents
+	Gross int `json:"gross"` // The gross amount in cents, after the discounts
+}
+
+// ItemRate returns the VAT rate in percent of the given item of the order.
+// Items of an unknown order type are taxed at the standard rate.
+func ItemRate(order *orders.Order, item orders.Item) int {
+	orderType := pkg.GetOrderType(item.OrderType.String())
+	if orderType == nil {
+		return StandardRate
+	}
+	return Rate(orderType.TaxCategory(), order.EatIn)
+}
+
+// Compute returns the VAT breakdown of the order per rate, sorted by rate.
+//
+// The discounts of the order apply to the whole basket, so they are allocated to the rates
+// in proportion to their gross amounts. The tax is computed once per rate on the gross total
+// of the rate and rounded half up, so the sum of the rates always matches the total of the order.
+func Compute(order *orders.Order) []Amount {
+	if order == nil {
+		return nil
+	}
+
+	gross := map[int]int{}
+	for _, item := range order.Items {
+		gross[ItemRate(order, item)] += item.Total()
+	}
+
+	rates := make([]int, 0, len(gross))
+	for rate := range gross {
+		rates = append(rates, rate)
+	}
+	sort.Ints(rates)
+
+	discounts := allocate(order.Subtotal()-order.Total(), rates, gross)
+
+	amounts := make([]Amount, 0, len(rates))
+	for _, rate := range rates {
+		g := gross[rate] - discounts[rate]
+		net := roundDiv(g*100, 100+rate)
+		amounts = append(amounts, Amount{Rate: rate, Net: net, Tax: g - net, Gross: g})
+	}
+
+	return amounts
+}
+
+// allocate splits the discount between the rates in proportion to their gross amounts.
+// The cents that are left by the integer division go to the rates with the largest remainders,
+// and to the lower rates first on a tie, so the allocation is deterministic and adds up to the discount.
+func allocate(discount int, rates []int, gross map[int]int) map[int]int {
+	allocated := map[int]int{}
+
+	var subtotal int
+	for _, rate := range rates {
+		subtotal += gross[rate]
+	}
+	if discount <= 0 || subtotal == 0 {
+		return allocated
+	}
+
+	remainders := make(map[int]int, len(rates))
+	left := discount
+	for _, rate := range rates {
+		allocated[rate] = discount * gross[rate] / subtotal
+		remainders[rate] = discount * gross[rate] % subtotal
+		left -= allocated[rate]
+	}
+
+	byRemainder := make([]int, len(rates))
+	copy(byRemainder, rates)
+	sort.SliceStable(byRemainder, func(i, j int) bool {
+		return remainders[byRemainder[i]] > remainders[byRemainder[j]]
+	})
+	for i := 0; left > 0; i++ {
+		allocated[byRemainder[i%len(byRemainder)]]++
+		left--
+	}
+
+	return allocated
+}
+
+// roundDiv divides a by b and rounds the result half up, both a and b are expected to be non-negative
+func roundDiv(a, b int) int {
+	return (2*a + b) / (2 * b)
+}
@@ -0,0 +1,104 @@
+package tax
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/azhovan/currywurst/internal/orders"
+	"github.com/azhovan/currywurst/pkg"
+)
+
+func TestRate(t *testing.T) {
+	tests := []struct {
+		category pkg.TaxCategory
+		eatIn    bool
+		want     int
+	}{
+		{category: pkg.Food, eatIn: false, want: ReducedRate},
+		{category: pkg.Food, eatIn: true, want: StandardRate},
+		{category: pkg.Beverage, eatIn: false, want: StandardRate},
+		{category: pkg.Beverage, eatIn: true, want: StandardRate},
+	}
+
+	for _, tt := range tests {
+		if got := Rate(tt.category, tt.eatIn); got != tt.want {
+			t.Errorf("Rate(%s, %t) got:%d, want:%d", tt.category, tt.eatIn, got, tt.want)
+		}
+	}
+}
+
+func TestCompute(t *testing.T) {
+	tests := []struct {
+		name      string
+		items     []orders.Item
+		eatIn     bool
+		discounts []orders.Discount
+		want      []Amount
+	}{
+		{
+			name:  "take-away food",
+			items: []orders.Item{{OrderType: orders.Vegan, Quantity: 1}},
+			want:  []Amount{{Rate: 7, Net: 28, Tax: 2, Gross: 30}},
+		},
+		{
+			name:  "eat-in food",
+			items: []orders.Item{{OrderType: orders.Vegan, Quantity: 1}},
+			eatIn: true,
+			want:  []Amount{{Rate: 19, Net: 25, Tax: 5, Gross: 30}},
+		},
+		{
+			name: "take-away food and drink with a discount",
+			items: []orders.Item{
+				{OrderType: orders.Vegan, Quantity: 1},
+				{OrderType: orders.Fries, Quantity: 1},
+				{OrderType: orders.Drink, Quantity: 1},
+			},
+			discounts: []orders.Discount{{Rule: "menu", Amount: 15}},
+			want: []Amount{
+				{Rate: 7, Net: 41, Tax: 3, Gross: 44},
+				{Rate: 19, Net: 13, Tax: 3, Gross: 16},
+			},
+		},
+		{
+			name: "eat-in food and drink share the standard rate",
+			items: []orders.Item{
+				{OrderType: orders.NonVegan, Quantity: 2},
+				{OrderType: orders.Drink, Quantity: 1},
+			},
+			eatIn: true,
+			want:  []Amount{{Rate: 19, Net: 76, Tax: 14, Gross: 90}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := orders.NewBasketOrder(context.TODO(), 500, tt.items)
+			order.EatIn = tt.eatIn
+			order.Discounts = tt.discounts
+
+			got := Compute(order)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Compute() got:%v, want:%v", got, tt.want)
+			}
+
+			var gross int
+			for _, amount := range got {
+				gross += amount.Gross
+			}
+			if gross != order.Total() {
+				t.Errorf("Compute() got gross total:%d, want:%d", gross, order.Total())
+			}
+		})
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	// 5 cents split between 25 and 20 cents gives 2.77 and 2.22 cents,
+	// the cent that is left goes to the largest remainder
+	got := allocate(5, []int{7, 19}, map[int]int{7: 25, 19: 20})
+	want := map[int]int{7: 3, 19: 2}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("allocate() got:%v, want:%v", got, want)
+	}
+}
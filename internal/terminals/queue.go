@@ -0,0 +1,115 @@
+package terminals
+
+import (
+	"container/heap"
+	"time"
+
+	"github.com/azhovan/currywurst/internal/orders"
+)
+
+// DefaultAging is the time an order waits in the queue to be served like an order of the next priority class.
+const DefaultAging = 30 * time.Second
+
+// queuedOrder is an order waiting in the queue of a terminal
+type queuedOrder struct {
+	order *orders.Order
+	rank  int       // The rank of the priority class of the order
+	at    time.Time // The time the order joined the queue
+	seq   uint64    // The position the order joined the queue at, it keeps the orders of a class in line
+}
+
+// orderQueue is the queue of the orders of a terminal, ordered by priority with aging.
+//
+// An order of a higher class skips the orders of a lower class, unless they have waited long enough:
+// every aging period an order waits counts as one class. It means an order of rank r that joined at t
+// is served as if it joined at t - r*aging, so no order waits more than (r*aging) longer than it would in line.
+// Without aging the classes are served strictly one after the other. Within a class the orders are served in line.
+// The caller must hold the lock of the terminal.
+type orderQueue struct {
+	items []*queuedOrder
+	byID  map[string]*queuedOrder // The queued orders by their id, an order that is not queued is found at once
+	aging time.Duration
+	seq   uint64
+}
+
+// push adds the order to the queue, at the given time
+func (q *orderQueue) push(order *orders.Order, at time.Time) {
+	q.seq++
+	heap.Push(q, &queuedOrder{order: order, rank: order.Priority.Rank(), at: at, seq: q.seq})
+}
+
+// pop removes and returns the first order of the queue, or nil if the queue is empty
+func (q *orderQueue) pop() *orders.Order {
+	if len(q.items) == 0 {
+		return nil
+	}
+	return heap.Pop(q).(*queuedOrder).order
+}
+
+// Len implements heap.Interface
+func (q *orderQueue) Len() int {
+	return len(q.items)
+}
+
+// Less implements heap.Interface, it tells whether the order i is served before the order j
+func (q *orderQueue) Less(i, j int) bool {
+	return servedBefore(q.items[i], q.items[j], q.aging)
+}
+
+// servedBefore tells whether the order a is served before the order b, with the given aging
+func servedBefore(a, b *queuedOrder, aging time.Duration) bool {
+	if aging > 0 {
+		// the time the orders are served as if they joined at
+		aAt := a.at.Add(-time.Duration(a.rank) * aging)
+		bAt := b.at.Add(-time.Duration(b.rank) * aging)
+		if !aAt.Equal(bAt) {
+			return aAt.Before(bAt)
+		}
+	} else if a.rank != b.rank {
+		return a.rank > b.rank
+	}
+	return a.seq < b.seq
+}
+
+// Swap implements heap.Interface
+func (q *orderQueue) Swap(i, j int) {
+	q.items[i], q.items[j] = q.items[j], q.items[i]
+}
+
+// Push implements heap.Interface
+func (q *orderQueue) Push(x any) {
+	item := x.(*queuedOrder)
+	q.items = append(q.items, item)
+	if q.byID == nil {
+		q.byID = map[string]*queuedOrder{}
+	}
+	q.byID[item.order.ID] = item
+}
+
+// Pop implements heap.Interface
+func (q *orderQueue) Pop() any {
+	last := q.items[len(q.items)-1]
+	q.items[len(q.items)-1] = nil
+	q.items = q.items[:len(q.items)-1]
+	delete(q.byID, last.order.ID)
+	return last
+}
+
+// find returns the queued order with the given id, or false if the order is not queued
+func (q *orderQueue) find(id string) (*queuedOrder, bool) {
+	item, ok := q.byID[id]
+	return item, ok
+}
+
+// position returns the place of the item among the given items starting at 1, the items that are served
+// before it are counted. The items are never changed once they are queued, so the caller may count them
+// on a copy of the queue, without the lock of the terminal.
+func position(item *queuedOrder, items []*queuedOrder, aging time.Duration) int {
+	position := 1
+	for _, other := range items {
+		if other != item && servedBefore(other, item, aging) {
+			position++
+		}
+	}
+	return position
+}
@@ -0,0 +1,201 @@
+package terminals
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrTerminalNotFound is the error returned when there is no terminal with the given id in the registry.
+	ErrTerminalNotFound = errors.New("terminal not found")
+
+	// ErrTerminalExists is the error returned when a terminal with the given id is already in the registry.
+	ErrTerminalExists = errors.New("terminal already exists")
+
+	// ErrInvalidTerminalID is the error returned when the id of a terminal is empty or can not be used in a URL path.
+	ErrInvalidTerminalID = errors.New("invalid terminal id")
+)
+
+// Workers are the workers that process the orders of a terminal, their number can be changed at runtime.
+type Workers interface {
+	// Size returns the number of workers.
+	Size() int
+	// Resize starts or stops workers until there are the given number of workers.
+	Resize(size int) error
+}
+
+// StartFunc starts the workers of a terminal that is added to the registry, and returns them.
+// The workers are expected to stop once the terminal is closed.
+type StartFunc func(t *Terminal) (Workers, error)
+
+// Registry keeps the terminals by their id, so they can be added, renamed and removed while the server is running.
+// Every terminal that is added is started with the start function of the registry, and every terminal that is
+// removed is closed, so its workers stop. It is safe for concurrent use.
+type Registry struct {
+	mu        sync.RWMutex
+	terminals map[string]*Terminal
+	workers   map[string]Workers // The workers of the terminals, by the same id
+	capacity  int                // The capacity of the terminals that are added
+	start     StartFunc          // Starts the workers of the terminals that are added
+}
+
+// NewRegistry creates and returns a new empty registry. The terminals that are added have the given capacity,
+// and are started with the given function.
+func NewRegistry(capacity int, start StartFunc) (*Registry, error) {
+	if capacity < 0 {
+		return nil, fmt.Errorf("invalid capacity: %d", capacity)
+	}
+
+	return &Registry{
+		terminals: map[string]*Terminal{},
+		workers:   map[string]Workers{},
+		capacity:  capacity,
+		start:     start,
+	}, nil
+}
+
+// Add creates a terminal with the given id, starts its workers and returns it.
+// It returns ErrTerminalExists if there is a terminal with the same id, or ErrInvalidTerminalID.
+func (r *Registry) Add(id string) (*Terminal, error) {
+	if err := ValidateID(id); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.terminals[id]; ok {
+		return nil, ErrTerminalExists
+	}
+
+	terminal, err := NewTerminal(r.capacity)
+	if err != nil {
+		return nil, err
+	}
+	if r.start != nil {
+		workers, err := r.start(terminal)
+		if err != nil {
+			terminal.Close()
+			return nil, err
+		}
+		r.workers[id] = workers
+	}
+	r.terminals[id] = terminal
+	return terminal, nil
+}
+
+// Get returns the terminal with the given id, or false if there is none.
+func (r *Registry) Get(id string) (*Terminal, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	terminal, ok := r.terminals[id]
+	return terminal, ok
+}
+
+// Workers returns the workers of the terminal with the given id, or false if there is no such terminal
+// or it has no workers.
+func (r *Registry) Workers(id string) (Workers, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	workers, ok := r.workers[id]
+	return workers, ok && workers != nil
+}
+
+// IDs returns the ids of the terminals, sorted.
+func (r *Registry) IDs() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := make([]string, 0, len(r.terminals))
+	for id := range r.terminals {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Len returns the number of terminals.
+func (r *Registry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.terminals)
+}
+
+// Busiest returns the terminal with the most queued orders, leaving out the given terminal and the terminals
+// under maintenance.
+// It returns nil if the queues of the other terminals are all empty.
+func (r *Registry) Busiest(except *Terminal) *Terminal {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var busiest *Terminal
+	most := 0
+	for _, terminal := range r.terminals {
+		// the queue of a terminal under maintenance is left as it is
+		if terminal == except || terminal.Status().State == StateMaintenance {
+			continue
+		}
+		if n := terminal.Len(); n > most {
+			busiest, most = terminal, n
+		}
+	}
+	return busiest
+}
+
+// Rename moves the terminal with the given id to a new id. The terminal keeps its queue and its workers.
+// It returns ErrTerminalNotFound, ErrTerminalExists if the new id is taken, or ErrInvalidTerminalID.
+func (r *Registry) Rename(id, newId string) error {
+	if err := ValidateID(newId); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	terminal, ok := r.terminals[id]
+	if !ok {
+		return ErrTerminalNotFound
+	}
+	if _, ok := r.terminals[newId]; ok {
+		return ErrTerminalExists
+	}
+
+	delete(r.terminals, id)
+	r.terminals[newId] = terminal
+	if workers, ok := r.workers[id]; ok {
+		delete(r.workers, id)
+		r.workers[newId] = workers
+	}
+	return nil
+}
+
+// Remove takes the terminal with the given id out of the registry and closes it with the given mode,
+// so its workers stop once they are done with it. It returns ErrTerminalNotFound if there is no such terminal.
+func (r *Registry) Remove(id string, mode CloseMode) error {
+	r.mu.Lock()
+	terminal, ok := r.terminals[id]
+	delete(r.terminals, id)
+	delete(r.workers, id)
+	r.mu.Unlock()
+
+	if !ok {
+		return ErrTerminalNotFound
+	}
+	// the terminal may have been closed on its own, it is removed all the same
+	if err := terminal.CloseWith(mode); err != nil && !errors.Is(err, ErrTerminalClosed) {
+		return err
+	}
+	return nil
+}
+
+// ValidateID checks that the id of a terminal is not empty, and can be used as a segment of a URL path.
+// It returns ErrInvalidTerminalID otherwise.
+func ValidateID(id string) error {
+	if id == "" || strings.ContainsAny(id, "/?#% ") {
+		return ErrInvalidTerminalID
+	}
+	return nil
+}
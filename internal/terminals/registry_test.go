@@ -0,0 +1,121 @@
+package terminals
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/azhovan/currywurst/internal/orders"
+)
+
+func Test_Registry(t *testing.T) {
+	var started int
+	registry, err := NewRegistry(1, func(*Terminal) (Workers, error) {
+		started++
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("expected error to be nil, got:%v", err)
+	}
+
+	terminal, err := registry.Add("terminal-0")
+	if err != nil {
+		t.Fatalf("expected nil error, got:%v", err)
+	}
+	if started != 1 {
+		t.Errorf("expected the terminal to be started once, got:%d", started)
+	}
+	if _, err = registry.Add("terminal-0"); !errors.Is(err, ErrTerminalExists) {
+		t.Errorf("expected error type %v, got %v", ErrTerminalExists, err)
+	}
+	if _, err = registry.Add("a/b"); !errors.Is(err, ErrInvalidTerminalID) {
+		t.Errorf("expected error type %v, got %v", ErrInvalidTerminalID, err)
+	}
+
+	// the renamed terminal keeps its queue
+	terminal.Put(orders.NewOrder(context.TODO(), 45, orders.Vegan))
+	if err = registry.Rename("terminal-0", "window"); err != nil {
+		t.Fatalf("expected nil error, got:%v", err)
+	}
+	if _, ok := registry.Get("terminal-0"); ok {
+		t.Errorf("expected the old id to be gone")
+	}
+	if got, ok := registry.Get("window"); !ok || got != terminal || got.Len() != 1 {
+		t.Errorf("expected the terminal with its queued order under the new id")
+	}
+
+	// the removed terminal is closed, so its worker stops
+	if err = registry.Remove("window", CloseDrain); err != nil {
+		t.Fatalf("expected nil error, got:%v", err)
+	}
+	if closed, _ := terminal.IsClosed(); !closed {
+		t.Errorf("expected the removed terminal to be closed")
+	}
+	if err = registry.Remove("window", CloseDrain); !errors.Is(err, ErrTerminalNotFound) {
+		t.Errorf("expected error type %v, got %v", ErrTerminalNotFound, err)
+	}
+	if registry.Len() != 0 {
+		t.Errorf("expected no terminals, got:%v", registry.IDs())
+	}
+}
+
+func Test_RegistryConcurrency(t *testing.T) {
+	registry, _ := NewRegistry(1, nil)
+
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			registry.Add("terminal")
+			registry.Rename("terminal", "renamed")
+			registry.Remove("renamed", CloseFail)
+		}()
+		go func() {
+			defer wg.Done()
+			registry.Get("terminal")
+			registry.IDs()
+		}()
+	}
+	wg.Wait()
+}
+
+func Test_PausedTerminal(t *testing.T) {
+	terminal, _ := NewTerminal(1)
+	terminal.Pause()
+
+	order := orders.NewOrder(context.TODO(), 45, orders.Vegan)
+	if err := terminal.Put(order); !errors.Is(err, ErrTerminalPaused) {
+		t.Errorf("expected error type %v, got %v", ErrTerminalPaused, err)
+	}
+
+	terminal.Resume()
+	if err := terminal.Put(orders.NewOrder(context.TODO(), 45, orders.Vegan)); err != nil {
+		t.Errorf("expected nil error, got:%v", err)
+	}
+}
+
+func Test_Busiest(t *testing.T) {
+	registry, err := NewRegistry(10, nil)
+	if err != nil {
+		t.Fatalf("expected error to be nil, got:%v", err)
+	}
+	small, _ := registry.Add("small")
+	large, _ := registry.Add("large")
+
+	if got := registry.Busiest(nil); got != nil {
+		t.Errorf("expected no terminal while the queues are empty, got:%v", got)
+	}
+
+	small.Put(orders.NewOrder(context.TODO(), 30, orders.Vegan))
+	large.Put(orders.NewOrder(context.TODO(), 30, orders.Vegan))
+	large.Put(orders.NewOrder(context.TODO(), 30, orders.Vegan))
+	if got := registry.Busiest(small); got != large {
+		t.Errorf("expected the terminal with the longest queue")
+	}
+	// a terminal does not steal from itself
+	if got := registry.Busiest(large); got != small {
+		t.Errorf("expected the busiest terminal other than the given one")
+	}
+}
@@ -0,0 +1,89 @@
+package terminals
+
+import (
+	"time"
+)
+
+// State is the state of a terminal, it tells whether the terminal accepts and processes orders.
+type State string
+
+// Define the states of a terminal
+const (
+	StateOpen        State = "open"        // The terminal accepts new orders and processes the queued ones
+	StatePaused      State = "paused"      // The terminal refuses new orders, the queued ones are still processed
+	StateMaintenance State = "maintenance" // The terminal refuses new orders, the queued ones wait until it is open again
+	StateClosed      State = "closed"      // The terminal refuses new orders for good, it is final
+)
+
+// IsValid checks if the state is one of the states of a terminal
+func (s State) IsValid() bool {
+	switch s {
+	case StateOpen, StatePaused, StateMaintenance, StateClosed:
+		return true
+	default:
+		return false
+	}
+}
+
+// String returns the string representation of the state
+func (s State) String() string {
+	return string(s)
+}
+
+// refusal returns the error Put refuses new orders with in the state, or nil if the state accepts them
+func (s State) refusal() error {
+	switch s {
+	case StatePaused:
+		return ErrTerminalPaused
+	case StateMaintenance:
+		return ErrTerminalMaintenance
+	case StateClosed:
+		return ErrTerminalClosed
+	default:
+		return nil
+	}
+}
+
+// Status is the state of a terminal, along with the reason and the time it has been switched to it.
+type Status struct {
+	State  State
+	Reason string    // The reason the state has been switched, i.e. "coin acceptor jammed"
+	Since  time.Time // The time the state has been switched
+}
+
+// Status returns the current state of the terminal.
+func (t *Terminal) Status() Status {
+	if t == nil {
+		return Status{}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.status
+}
+
+// SetState switches the terminal to the given state for the given reason, and wakes up the callers waiting on it.
+// Switching to StateClosed closes the terminal with CloseDrain, a closed terminal can not be switched again.
+// It returns ErrInvalidState if the state is unknown, or an error if the terminal is nil or closed.
+func (t *Terminal) SetState(state State, reason string) error {
+	if !state.IsValid() {
+		return ErrInvalidState
+	}
+	if state == StateClosed {
+		return t.close(CloseDrain, reason)
+	}
+
+	closed, err := t.IsClosed()
+	if err != nil {
+		return err
+	}
+	if closed {
+		return ErrTerminalClosed
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.status = Status{State: state, Reason: reason, Since: t.clock.Now()}
+	t.cond.Broadcast()
+	return nil
+}
@@ -0,0 +1,87 @@
+package terminals
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/azhovan/currywurst/internal/clock"
+	"github.com/azhovan/currywurst/internal/orders"
+)
+
+func Test_States(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	c := clock.Func(func() time.Time { return now })
+	terminal, err := NewTerminal(10, WithClock(c))
+	if err != nil {
+		t.Fatalf("expected error to be nil, got:%v", err)
+	}
+	if status := terminal.Status(); status.State != StateOpen || !status.Since.Equal(now) {
+		t.Errorf("expected the terminal to be open since %v, got:%+v", now, status)
+	}
+
+	queued := orders.NewOrder(context.TODO(), 45, orders.Vegan)
+	terminal.Put(queued)
+
+	// the coin acceptor jammed, the terminal refuses new orders and keeps its queue
+	now = now.Add(time.Hour)
+	if err = terminal.SetState(StateMaintenance, "coin acceptor jammed"); err != nil {
+		t.Fatalf("expected nil error, got:%v", err)
+	}
+	status := terminal.Status()
+	if status.State != StateMaintenance || status.Reason != "coin acceptor jammed" || !status.Since.Equal(now) {
+		t.Errorf("expected the terminal under maintenance since %v with the reason, got:%+v", now, status)
+	}
+	refused := orders.NewOrder(context.TODO(), 45, orders.Vegan)
+	if err = terminal.Put(refused); !errors.Is(err, ErrTerminalMaintenance) {
+		t.Errorf("expected error type %v, got %v", ErrTerminalMaintenance, err)
+	}
+	if refused.State() != orders.StateFailed {
+		t.Errorf("expected the refused order to be %s, got:%s", orders.StateFailed, refused.State())
+	}
+	if _, err = terminal.TryGet(); !errors.Is(err, ErrTerminalEmpty) {
+		t.Errorf("expected the queue to be held, got error:%v", err)
+	}
+
+	// the worker waits until the terminal is open again
+	got := make(chan *orders.Order)
+	go func() {
+		order, _ := terminal.Get()
+		got <- order
+	}()
+	select {
+	case <-got:
+		t.Fatalf("expected the worker to wait while the terminal is under maintenance")
+	case <-time.After(20 * time.Millisecond):
+	}
+	if err = terminal.SetState(StateOpen, "coin acceptor fixed"); err != nil {
+		t.Fatalf("expected nil error, got:%v", err)
+	}
+	select {
+	case order := <-got:
+		if order != queued {
+			t.Errorf("expected the queued order, got:%v", order)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected the worker to take the queued order once the terminal is open")
+	}
+
+	if err = terminal.SetState(State("broken"), ""); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("expected error type %v, got %v", ErrInvalidState, err)
+	}
+
+	// a closed terminal is final
+	if err = terminal.SetState(StateClosed, "end of the season"); err != nil {
+		t.Fatalf("expected nil error, got:%v", err)
+	}
+	if mode, closed := terminal.ClosedWith(); !closed || mode != CloseDrain {
+		t.Errorf("expected the terminal to be closed with %v, got:%v", CloseDrain, mode)
+	}
+	if status := terminal.Status(); status.State != StateClosed || status.Reason != "end of the season" {
+		t.Errorf("expected the terminal to be closed with the reason, got:%+v", status)
+	}
+	if err = terminal.SetState(StateOpen, ""); !errors.Is(err, ErrTerminalClosed) {
+		t.Errorf("expected error type %v, got %v", ErrTerminalClosed, err)
+	}
+}
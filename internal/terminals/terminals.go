@@ -1,10 +1,13 @@
 package terminals
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sync"
+	"time"
 
+	"github.com/azhovan/currywurst/internal/clock"
 	"github.com/azhovan/currywurst/internal/orders"
 )
 
@@ -20,38 +23,113 @@ var (
 
 	// ErrTerminalClosed is the error returned when the terminal is closed and cannot accept or process any orders.
 	ErrTerminalClosed = errors.New("terminal is closed")
+
+	// ErrTerminalPaused is the error returned when the terminal is paused and does not accept new orders for now.
+	ErrTerminalPaused = errors.New("terminal is paused")
+
+	// ErrTerminalMaintenance is the error returned when the terminal is under maintenance and does not accept new orders.
+	ErrTerminalMaintenance = errors.New("terminal is under maintenance")
+
+	// ErrInvalidState is the error returned when a terminal is switched to an unknown state.
+	ErrInvalidState = errors.New("invalid terminal state")
+)
+
+// CloseMode tells what happens to the orders that are still queued when a terminal is closed.
+type CloseMode int
+
+// Define the close modes of a terminal
+const (
+	// CloseFail fails every queued order with ErrTerminalClosed, so their customers are answered right away.
+	CloseFail CloseMode = iota
+	// CloseDrain lets the worker take the queued orders and finish them, new orders are refused.
+	CloseDrain
 )
 
 // A Terminal is a queue of orders that customers can join and place their orders.
-// A terminal has a fixed capacity and can process one order at a time.
+// A terminal has a fixed capacity, its orders are processed by as many workers as take them, every order by one of them.
+// The orders are served by priority class, see orders.Priority, with aging so the lower classes are not starved.
 type Terminal struct {
-	orders   chan *orders.Order // The list of customer's order
-	done     chan bool          // The signal to indicate that the terminal is closed and no more orders can be added or processed
-	capacity int                // The maximum number of orders that can be queued at a time before terminal is blocked.
+	orders   *orderQueue // The list of customer's order
+	done     chan bool   // The signal to indicate that the terminal is closed and no more orders can be added or processed
+	capacity int         // The maximum number of orders that can be queued at a time before terminal is blocked.
+	clock    clock.Clock // The clock that tells the time the orders join the queue at
 
-	mu   sync.Mutex // A lock that terminal holds when signaling/waiting
-	cond *sync.Cond // A conditional variable for signaling the worker when terminal is empty or full
+	mu        sync.Mutex // A lock that terminal holds when signaling/waiting
+	cond      *sync.Cond // A conditional variable for signaling the worker when terminal is empty or full
+	closeMode CloseMode  // The way the terminal has been closed, it is only meaningful once done is closed
+	status    Status     // The state of the terminal, it tells whether the terminal accepts and processes orders
+
+	serviceTime time.Duration // The moving average of the time it takes to serve an order
+	served      int           // The number of orders the service time has been measured over
+}
+
+// Option is a function that modifies the terminal
+type Option func(*Terminal)
+
+// WithAging sets the time an order waits in the queue to be served like an order of the next priority class.
+// With zero the priority classes are served strictly one after the other.
+func WithAging(aging time.Duration) Option {
+	return func(t *Terminal) {
+		t.orders.aging = aging
+	}
+}
+
+// WithClock sets the clock that tells the time the orders join the queue at
+func WithClock(c clock.Clock) Option {
+	return func(t *Terminal) {
+		t.clock = c
+	}
 }
 
 // NewTerminal creates and returns a new Terminal with an empty queue of orders.
-func NewTerminal(capacity int) (*Terminal, error) {
+func NewTerminal(capacity int, opts ...Option) (*Terminal, error) {
 	if capacity < 0 {
 		return nil, fmt.Errorf("invalid capacity: %d", capacity)
 	}
 
 	terminal := &Terminal{
-		orders:   make(chan *orders.Order, capacity),
+		orders:   &orderQueue{aging: DefaultAging},
 		done:     make(chan bool, 1),
 		capacity: capacity,
+		clock:    clock.System,
+
+		serviceTime: DefaultServiceTime,
 	}
 	terminal.cond = sync.NewCond(&terminal.mu)
+
+	// apply the options
+	for _, opt := range opts {
+		opt(terminal)
+	}
+	terminal.status = Status{State: StateOpen, Since: terminal.clock.Now()}
+
 	return terminal, nil
 }
 
-// Put adds an order to the terminal's queue of orders.
-// It returns nil if successful, or an error if the
-// terminal is nil, closed, full, or the order is nil.
+// Put adds an order to the terminal's queue of orders, it waits as long as the terminal is full.
+// It returns nil if successful, or an error if the terminal is nil or closed, or the order is nil.
 func (t *Terminal) Put(order *orders.Order) error {
+	return t.PutContext(context.Background(), order)
+}
+
+// PutContext adds an order to the terminal's queue of orders, it waits while the terminal is full until the
+// context is done. It returns nil if successful, an error that wraps both ErrTerminalFull and the error of the
+// context if the terminal is still full when the context is done, or an error if the terminal is nil or closed,
+// or the order is nil.
+func (t *Terminal) PutContext(ctx context.Context, order *orders.Order) error {
+	return t.put(ctx, order, true)
+}
+
+// TryPut adds an order to the terminal's queue of orders without waiting.
+// It returns ErrTerminalFull right away if the terminal is full.
+func (t *Terminal) TryPut(order *orders.Order) error {
+	return t.put(context.Background(), order, false)
+}
+
+// put adds an order to the queue, waiting for a free slot until the context is done if wait is set.
+// The order is failed when it can not be queued, so its customer is not left waiting for it.
+// An order that is being prepared, i.e. handed back by the crash recovery, is refunded as well.
+func (t *Terminal) put(ctx context.Context, order *orders.Order, wait bool) error {
 	if t == nil {
 		return ErrTerminalNil
 	}
@@ -59,70 +137,122 @@ func (t *Terminal) Put(order *orders.Order) error {
 		return orders.ErrOrderNil
 	}
 
-	// using conditional variable has a downside here
+	// using conditional variable has a downside here, it can not wait on the context.
+	// The waiters are woken up once the context is done, so they notice it.
+	stop := context.AfterFunc(ctx, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.cond.Broadcast()
+	})
+	defer stop()
+
 	t.mu.Lock()
 	defer t.mu.Unlock()
 	// terminal is full, wait for the worker to catch up
-	for len(t.orders) == t.capacity {
+	for wait && t.orders.Len() >= t.capacity {
+		if closed, _ := t.IsClosed(); closed || t.status.State != StateOpen {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			fail(order, ErrTerminalFull)
+			return fmt.Errorf("%w: %w", ErrTerminalFull, err)
+		}
 		t.cond.Wait()
 	}
 
-	// defer the signal for the worker
-	// if it has been waiting for orders to come in
-	defer t.cond.Signal()
-
-	// try to send the order to the queue
-	select {
-	case <-t.done:
+	// a closed terminal refuses new orders, even if it still drains its queue
+	if closed, _ := t.IsClosed(); closed {
+		fail(order, ErrTerminalClosed)
 		return ErrTerminalClosed
-	case t.orders <- order:
-		return nil
-	default:
+	}
+	if err := t.status.State.refusal(); err != nil {
+		fail(order, err)
+		return err
+	}
+
+	// defer the wake-up for the workers
+	// if they have been waiting for orders to come in
+	defer t.cond.Broadcast()
+
+	// the order is queued before it is sent, so the worker never sees an order in the received state.
+	// An order that has been cancelled in the meantime is not queued. An order that was paid before
+	// the server restarted is handed back as it is, the worker only prepares it.
+	if order.State() != orders.StatePreparing {
+		if err := order.Transition(orders.StateQueued); err != nil {
+			return err
+		}
+	}
+
+	// try to add the order to the queue
+	if t.orders.Len() >= t.capacity {
+		fail(order, ErrTerminalFull)
 		return ErrTerminalFull
 	}
+	t.orders.push(order, t.clock.Now())
+	return nil
 }
 
 // Get returns and removes the first order in the terminal's queue of orders.
 // It returns the order and nil if successful, or nil and an error if the terminal is nil, closed, or the order is nil or cancelled.
+// A terminal that is closed with CloseDrain keeps returning its queued orders, until the queue is empty.
+// It is safe to call Get from many workers, every order is handed to exactly one of them.
 func (t *Terminal) Get() (*orders.Order, error) {
+	return t.GetContext(context.Background())
+}
+
+// GetContext is like Get, but it stops waiting for an order once the context is done,
+// and returns the error of the context.
+func (t *Terminal) GetContext(ctx context.Context) (*orders.Order, error) {
+	return t.get(ctx, true)
+}
+
+// TryGet returns and removes the first order in the terminal's queue of orders without waiting.
+// It returns ErrTerminalEmpty right away if the queue is empty, or ErrTerminalClosed if the terminal is also closed.
+// It is how an idle worker of another terminal steals an order, the orders are still taken in the terminal's order.
+func (t *Terminal) TryGet() (*orders.Order, error) {
+	return t.get(context.Background(), false)
+}
+
+// get takes the first order out of the queue, waiting for one until the context is done if wait is set.
+func (t *Terminal) get(ctx context.Context, wait bool) (*orders.Order, error) {
 	if t == nil {
 		return nil, ErrTerminalNil
 	}
 
-	// terminal has been already closed
-	select {
-	case <-t.done:
-		return nil, ErrTerminalClosed
-	default:
-	}
+	// the waiters are woken up once the context is done, so they notice it
+	stop := context.AfterFunc(ctx, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.cond.Broadcast()
+	})
+	defer stop()
 
 	// check if there are any orders in the queue
 	// block until a new orders come in
 	t.mu.Lock()
 	defer t.mu.Unlock()
-	// defer the wake-up signal, so new orders can come in
-	defer t.cond.Signal()
+	// defer the wake-up, so the customers waiting for a free slot can come in
+	defer t.cond.Broadcast()
 
 	// block until the terminal has a new order to process
-	// or the terminal is no longer open
-	for len(t.orders) == 0 {
+	// or the terminal is no longer open. The queue of a terminal under maintenance is left as it is.
+	for t.orders.Len() == 0 || t.status.State == StateMaintenance {
 		closed, _ := t.IsClosed()
 		if closed {
 			return nil, ErrTerminalClosed
 		}
+		if !wait {
+			return nil, ErrTerminalEmpty
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 
 		t.cond.Wait()
 	}
 
-	// there is only one goroutine that consumes this channel,
-	// because the channel will not be closed and drained by
-	// another goroutine we may not need to check the `ok` value
-	// after receiving from the channel like this:
-	// 	order, ok := <-t.orders
-	//	 if !ok {
-	// 		return nil, ErrTerminalClosed
-	// 	}
-	order := <-t.orders
+	// the queue is only touched with the lock held, and it is not empty
+	order := t.orders.pop()
 
 	// this MUST not happen, but just in case there is curred data in the system
 	// we add this safety check
@@ -143,13 +273,28 @@ func (t *Terminal) Get() (*orders.Order, error) {
 	}
 }
 
-// Close closes the terminal and stops accepting or processing new orders.
+// Close closes the terminal and stops accepting or processing new orders, the queued orders are failed.
 // It returns nil if successful, or an error if the terminal is nil or already closed.
 func (t *Terminal) Close() error {
+	return t.CloseWith(CloseFail)
+}
+
+// CloseWith closes the terminal and stops accepting new orders. The queued orders are either failed with
+// ErrTerminalClosed or left for the worker to finish, according to the mode. The callers waiting in Put
+// or Get are woken up. It returns nil if successful, or an error if the terminal is nil or already closed.
+func (t *Terminal) CloseWith(mode CloseMode) error {
+	return t.close(mode, "")
+}
+
+// close closes the terminal with the given mode, the reason is kept in the status of the terminal
+func (t *Terminal) close(mode CloseMode, reason string) error {
 	// check if the terminal is valid and open
 	if t == nil {
 		return ErrTerminalNil
 	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	select {
 	case <-t.done:
 		return ErrTerminalClosed
@@ -157,14 +302,64 @@ func (t *Terminal) Close() error {
 	}
 
 	// close the terminal
+	t.closeMode = mode
+	t.status = Status{State: StateClosed, Reason: reason, Since: t.clock.Now()}
 	close(t.done)
-	// setting the orders to nil is safe, because the only goroutine
-	// that sends values to this channel is the current terminal, which is closed
-	// therefore, there is no risk of panic.
-	t.orders = nil
+
+	// the queue is drained with the lock held, so no worker takes an order in the meantime
+	if mode == CloseFail {
+		for t.orders.Len() > 0 {
+			if order := t.orders.pop(); order != nil {
+				fail(order, ErrTerminalClosed)
+			}
+		}
+	}
+
+	// wake up everybody waiting on the terminal, so they notice it is closed
+	t.cond.Broadcast()
 	return nil
 }
 
+// ClosedWith reports whether the terminal is closed, and the mode it has been closed with.
+func (t *Terminal) ClosedWith() (CloseMode, bool) {
+	if closed, _ := t.IsClosed(); !closed {
+		return 0, false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.closeMode, true
+}
+
+// Pause stops the terminal from accepting new orders, the queued orders are still processed.
+// The callers waiting in Put for a free slot are refused with ErrTerminalPaused.
+// It returns an error if the terminal is nil or closed.
+func (t *Terminal) Pause() error {
+	return t.SetState(StatePaused, "")
+}
+
+// Resume opens a paused terminal or a terminal under maintenance, so it accepts new orders again.
+// It returns an error if the terminal is nil or closed.
+func (t *Terminal) Resume() error {
+	return t.SetState(StateOpen, "")
+}
+
+// IsPaused checks if the terminal is paused.
+func (t *Terminal) IsPaused() bool {
+	return t.Status().State == StatePaused
+}
+
+// Len returns the number of orders waiting in the terminal's queue.
+func (t *Terminal) Len() int {
+	if t == nil {
+		return 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.orders.Len()
+}
+
 // IsClosed checks if the terminal is closed.
 // It returns true if the terminal is closed, false otherwise.
 // It also returns an error if the terminal is nil.
@@ -180,3 +375,13 @@ func (t *Terminal) IsClosed() (bool, error) {
 		return false, nil
 	}
 }
+
+// fail fails an order the terminal can not take or process. An order that was paid before the server restarted,
+// and is handed back to the terminal to be prepared again, is refunded in full.
+func fail(order *orders.Order, err error) {
+	if order.State() == orders.StatePreparing {
+		order.Recover(orders.StateFailed, err.Error())
+		return
+	}
+	order.Fail(err)
+}
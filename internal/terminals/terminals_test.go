@@ -5,8 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/azhovan/currywurst/internal/clock"
 	"github.com/azhovan/currywurst/internal/orders"
 )
 
@@ -28,9 +31,6 @@ func Test_PutGetOrder(t *testing.T) {
 		terminal.Put(v.order)
 	}
 
-	// close the terminal to signal no more order
-	close(terminal.orders)
-
 	expectedInsertedPrices := []int{45, 50, 10}
 	for i, price := range expectedInsertedPrices {
 		t.Run(fmt.Sprintf("test: %d", i), func(t *testing.T) {
@@ -111,14 +111,45 @@ func Test_EmptyTerminal(t *testing.T) {
 		t.Fatalf("expected nil error, got:%v", err2)
 	}
 	// assert that sent order has the same attributes as got order
-	if wantOrder.OrderType != gotOrder.OrderType {
-		t.Fatalf("expected order type %v, got:%v", wantOrder.OrderType, gotOrder.OrderType)
+	if wantOrder.Items[0].OrderType != gotOrder.Items[0].OrderType {
+		t.Fatalf("expected order type %v, got:%v", wantOrder.Items[0].OrderType, gotOrder.Items[0].OrderType)
 	}
 	if wantOrder.Inserted != gotOrder.Inserted {
 		t.Fatalf("expected order Inserted price %v, got:%v", wantOrder.Inserted, gotOrder.Inserted)
 	}
 }
 
+func Test_TryGet(t *testing.T) {
+	terminal, err := NewTerminal(5)
+	if err != nil {
+		t.Fatalf("expected error to be nil, got:%v", err)
+	}
+
+	// nothing is queued, TryGet does not wait
+	if _, err = terminal.TryGet(); !errors.Is(err, ErrTerminalEmpty) {
+		t.Errorf("expected error type %v, got %v", ErrTerminalEmpty, err)
+	}
+
+	// the orders are taken in the order they joined the queue
+	var placed []*orders.Order
+	for i := 0; i < 3; i++ {
+		order := orders.NewOrder(context.TODO(), 45, orders.Vegan)
+		terminal.Put(order)
+		placed = append(placed, order)
+	}
+	for i, want := range placed {
+		got, err := terminal.TryGet()
+		if err != nil || got != want {
+			t.Fatalf("expected order %d of the queue, got:%v error:%v", i, got, err)
+		}
+	}
+
+	terminal.Close()
+	if _, err = terminal.TryGet(); !errors.Is(err, ErrTerminalClosed) {
+		t.Errorf("expected error type %v, got %v", ErrTerminalClosed, err)
+	}
+}
+
 func Test_ClosedTerminal(t *testing.T) {
 	order := orders.NewOrder(context.Background(), 10, orders.Vegan)
 
@@ -142,3 +173,263 @@ func Test_ClosedTerminal(t *testing.T) {
 		t.Errorf("expected error type %v, got %v", orders.ErrOrderNil, err)
 	}
 }
+
+func Test_FullTerminal(t *testing.T) {
+	terminal, err := NewTerminal(1)
+	if err != nil {
+		t.Fatalf("expected error to be nil, got:%v", err)
+	}
+	if err = terminal.TryPut(orders.NewOrder(context.TODO(), 45, orders.Vegan)); err != nil {
+		t.Fatalf("expected nil error, got:%v", err)
+	}
+
+	// the terminal is full, try put fails fast
+	order := orders.NewOrder(context.TODO(), 45, orders.Vegan)
+	if err = terminal.TryPut(order); !errors.Is(err, ErrTerminalFull) {
+		t.Errorf("expected error type %v, got %v", ErrTerminalFull, err)
+	}
+	if order.State() != orders.StateFailed {
+		t.Errorf("expected the order to be %s, got:%s", orders.StateFailed, order.State())
+	}
+
+	// put waits until the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = terminal.PutContext(ctx, orders.NewOrder(context.TODO(), 45, orders.Vegan))
+	if !errors.Is(err, ErrTerminalFull) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected error type %v and %v, got %v", ErrTerminalFull, context.DeadlineExceeded, err)
+	}
+
+	// put gets the slot once the worker catches up
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		terminal.Get()
+	}()
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err = terminal.PutContext(ctx, orders.NewOrder(context.TODO(), 45, orders.Vegan)); err != nil {
+		t.Errorf("expected nil error, got:%v", err)
+	}
+}
+
+func Test_CloseModes(t *testing.T) {
+	tests := []struct {
+		mode      CloseMode
+		wantState orders.State
+		wantErr   error
+	}{
+		{mode: CloseFail, wantState: orders.StateFailed, wantErr: ErrTerminalClosed},
+		{mode: CloseDrain, wantState: orders.StateQueued, wantErr: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("mode: %d", tt.mode), func(t *testing.T) {
+			terminal, err := NewTerminal(2)
+			if err != nil {
+				t.Fatalf("expected error to be nil, got:%v", err)
+			}
+			order := orders.NewOrder(context.TODO(), 45, orders.Vegan)
+			terminal.Put(order)
+
+			if err = terminal.CloseWith(tt.mode); err != nil {
+				t.Fatalf("expected nil error, got:%v", err)
+			}
+			if order.State() != tt.wantState {
+				t.Errorf("expected the queued order to be %s, got:%s", tt.wantState, order.State())
+			}
+
+			// new orders are refused in both modes
+			if err = terminal.Put(orders.NewOrder(context.TODO(), 45, orders.Vegan)); !errors.Is(err, ErrTerminalClosed) {
+				t.Errorf("expected error type %v, got %v", ErrTerminalClosed, err)
+			}
+
+			// a draining terminal hands out its queued orders, then it is closed
+			if _, err = terminal.Get(); !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected error type %v, got %v", tt.wantErr, err)
+			}
+			if _, err = terminal.Get(); !errors.Is(err, ErrTerminalClosed) {
+				t.Errorf("expected error type %v, got %v", ErrTerminalClosed, err)
+			}
+			if mode, closed := terminal.ClosedWith(); !closed || mode != tt.mode {
+				t.Errorf("expected the terminal to be closed with %d, got:%d closed:%t", tt.mode, mode, closed)
+			}
+		})
+	}
+}
+
+func Test_CloseWakesUp(t *testing.T) {
+	empty, _ := NewTerminal(1)
+	full, _ := NewTerminal(1)
+	full.Put(orders.NewOrder(context.TODO(), 45, orders.Vegan))
+
+	errs := make(chan error, 2)
+	go func() {
+		_, err := empty.Get()
+		errs <- err
+	}()
+	go func() {
+		errs <- full.Put(orders.NewOrder(context.TODO(), 45, orders.Vegan))
+	}()
+
+	// give the callers the time to wait on the terminals
+	time.Sleep(10 * time.Millisecond)
+	empty.Close()
+	full.Close()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			if !errors.Is(err, ErrTerminalClosed) {
+				t.Errorf("expected error type %v, got %v", ErrTerminalClosed, err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("the callers waiting on the terminal are not woken up")
+		}
+	}
+}
+
+// priorityOrder returns a new order of the given priority class
+func priorityOrder(priority orders.Priority) *orders.Order {
+	order := orders.NewOrder(context.TODO(), 45, orders.Vegan)
+	order.Priority = priority
+	return order
+}
+
+func Test_Priority(t *testing.T) {
+	terminal, _ := NewTerminal(10, WithAging(0))
+
+	normal := priorityOrder("")
+	staff := priorityOrder(orders.PriorityStaff)
+	accessibility := priorityOrder(orders.PriorityAccessibility)
+	preOrder := priorityOrder(orders.PriorityPreOrder)
+	lastNormal := priorityOrder(orders.PriorityNormal)
+	for _, order := range []*orders.Order{normal, staff, accessibility, preOrder, lastNormal} {
+		terminal.Put(order)
+	}
+
+	for i, want := range []*orders.Order{accessibility, preOrder, staff, normal, lastNormal} {
+		if got, _ := terminal.Get(); got != want {
+			t.Errorf("expected order #%d to be the %s order, got the %s order", i, want.Priority, got.Priority)
+		}
+	}
+}
+
+func Test_Aging(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	c := clock.Func(func() time.Time { return now })
+	terminal, _ := NewTerminal(10, WithAging(time.Minute), WithClock(c))
+
+	// the accessibility order skips a normal order that waited less than 3 minutes
+	normal := priorityOrder(orders.PriorityNormal)
+	terminal.Put(normal)
+	now = now.Add(2 * time.Minute)
+	accessibility := priorityOrder(orders.PriorityAccessibility)
+	terminal.Put(accessibility)
+
+	if got, _ := terminal.Get(); got != accessibility {
+		t.Errorf("expected the accessibility order first, got the %s order", got.Priority)
+	}
+	terminal.Get()
+
+	// but not a normal order that waited longer than that
+	normal = priorityOrder(orders.PriorityNormal)
+	terminal.Put(normal)
+	now = now.Add(4 * time.Minute)
+	terminal.Put(priorityOrder(orders.PriorityAccessibility))
+
+	if got, _ := terminal.Get(); got != normal {
+		t.Errorf("expected the normal order that waited long enough first, got the %s order", got.Priority)
+	}
+}
+
+func Test_PriorityConcurrency(t *testing.T) {
+	const producers, consumers, perProducer = 8, 4, 50
+	const total = producers * perProducer * 2
+	terminal, _ := NewTerminal(total, WithAging(0))
+
+	// the steps of the producers and the consumers are ticked on a shared counter, so the test can tell
+	// when an order was certainly queued before another one was taken
+	var tick atomic.Int64
+	type produced struct {
+		producer, seq int
+		queued        int64 // the tick once Put returned, the order is in the queue by then
+	}
+	type taken struct {
+		order      *orders.Order
+		asked, got int64 // the ticks before Get was called and once it returned
+	}
+	info := map[*orders.Order]produced{}
+	mu := &sync.Mutex{}
+
+	// every producer puts its normal and staff orders, while the consumers take them
+	wg := &sync.WaitGroup{}
+	for p := 0; p < producers; p++ {
+		wg.Add(1)
+		go func(p int) {
+			defer wg.Done()
+			for i := 0; i < perProducer; i++ {
+				for _, priority := range []orders.Priority{orders.PriorityNormal, orders.PriorityStaff} {
+					order := priorityOrder(priority)
+					if err := terminal.Put(order); err != nil {
+						t.Errorf("expected nil error, got:%v", err)
+					}
+					queued := tick.Add(1)
+					mu.Lock()
+					info[order] = produced{producer: p, seq: i, queued: queued}
+					mu.Unlock()
+				}
+			}
+		}(p)
+	}
+
+	collected := make([][]taken, consumers)
+	var remaining atomic.Int64
+	remaining.Store(total)
+	for c := 0; c < consumers; c++ {
+		wg.Add(1)
+		go func(c int) {
+			defer wg.Done()
+			for remaining.Add(-1) >= 0 {
+				asked := tick.Add(1)
+				order, err := terminal.Get()
+				if err != nil {
+					t.Errorf("expected nil error, got:%v", err)
+					return
+				}
+				collected[c] = append(collected[c], taken{order: order, asked: asked, got: tick.Add(1)})
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	var all []taken
+	for c, list := range collected {
+		all = append(all, list...)
+
+		// a consumer takes its orders in the order of the queue, so the orders of a producer are in line per class
+		last := map[string]int{}
+		for _, o := range list {
+			key := fmt.Sprintf("%s/%d", o.order.Priority, info[o.order].producer)
+			if previous, ok := last[key]; ok && previous >= info[o.order].seq {
+				t.Fatalf("expected the orders %s in line, consumer %d got %d after %d", key, c, info[o.order].seq, previous)
+			}
+			last[key] = info[o.order].seq
+		}
+	}
+	if len(all) != total {
+		t.Fatalf("expected %d orders to be taken, got:%d", total, len(all))
+	}
+
+	// a normal order is never taken while a staff order is waiting: a staff order that was queued before a normal
+	// order was asked for, must not be taken after the normal order was handed out
+	for _, normal := range all {
+		if normal.order.Priority != orders.PriorityNormal {
+			continue
+		}
+		for _, staff := range all {
+			if staff.order.Priority == orders.PriorityStaff && info[staff.order].queued < normal.asked && staff.asked > normal.got {
+				t.Fatalf("expected the staff orders queued before a normal order is taken to be served first")
+			}
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package terminals
+
+import (
+	"slices"
+	"time"
+)
+
+// DefaultServiceTime is the time an order is expected to take, until the terminal has served an order.
+const DefaultServiceTime = time.Minute
+
+// serviceTimeWeight is the weight of the latest order in the moving average of the service time,
+// the higher it is the faster the estimate follows a change of pace of the kitchen.
+const serviceTimeWeight = 0.2
+
+// WithServiceTime sets the time an order is expected to take, until the terminal has served an order.
+func WithServiceTime(d time.Duration) Option {
+	return func(t *Terminal) {
+		t.serviceTime = d
+	}
+}
+
+// Served records the time a worker took to serve an order of the terminal, from taking it out of the queue
+// until it is ready. The service time of the terminal is the exponential moving average of these times,
+// the first order replaces the default.
+func (t *Terminal) Served(d time.Duration) {
+	if t == nil || d < 0 {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.served == 0 {
+		t.serviceTime = d
+	} else {
+		t.serviceTime += time.Duration(serviceTimeWeight * float64(d-t.serviceTime))
+	}
+	t.served++
+}
+
+// ServiceTime returns the moving average of the time it takes to serve an order of the terminal.
+func (t *Terminal) ServiceTime() time.Duration {
+	if t == nil {
+		return 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.serviceTime
+}
+
+// Position returns the place of the order with the given id in the queue of the terminal, starting at 1.
+// It returns false if the order is not waiting in the queue, i.e. it is already being processed.
+// The lock of the terminal is only held to copy the queue, the orders are counted without it,
+// so the customers polling the status of their orders do not hold up the workers.
+func (t *Terminal) Position(orderId string) (int, bool) {
+	if t == nil {
+		return 0, false
+	}
+
+	t.mu.Lock()
+	item, ok := t.orders.find(orderId)
+	if !ok {
+		t.mu.Unlock()
+		return 0, false
+	}
+	items := slices.Clone(t.orders.items)
+	aging := t.orders.aging
+	t.mu.Unlock()
+
+	return position(item, items, aging), true
+}
+
+// EstimateWait returns the time until an order at the given position of the queue is ready,
+// when the queue is served by the given number of workers. The workers serve the orders in rounds,
+// so the order is ready once the rounds up to and including its own are done.
+// The idle workers of the other terminals that steal orders are not counted, they only help out
+// when they have nothing else to do, so the estimate errs on the long side while they do.
+func (t *Terminal) EstimateWait(position, workers int) time.Duration {
+	if position < 1 {
+		return 0
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	rounds := (position + workers - 1) / workers
+	return time.Duration(rounds) * t.ServiceTime()
+}
+
+// CurrentWait returns the time until an order that joins the queue now is ready, when the queue is served
+// by the given number of workers. It is the figure shown on the signage of the terminal.
+func (t *Terminal) CurrentWait(workers int) time.Duration {
+	return t.EstimateWait(t.Len()+1, workers)
+}
@@ -0,0 +1,68 @@
+package terminals
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/azhovan/currywurst/internal/orders"
+)
+
+func Test_ServiceTime(t *testing.T) {
+	terminal, err := NewTerminal(10, WithServiceTime(time.Minute))
+	if err != nil {
+		t.Fatalf("expected error to be nil, got:%v", err)
+	}
+	if got := terminal.ServiceTime(); got != time.Minute {
+		t.Errorf("expected the default service time %v, got:%v", time.Minute, got)
+	}
+
+	// the first order replaces the default, the next ones move the average
+	terminal.Served(10 * time.Second)
+	if got := terminal.ServiceTime(); got != 10*time.Second {
+		t.Errorf("expected service time %v, got:%v", 10*time.Second, got)
+	}
+	terminal.Served(20 * time.Second)
+	if got, want := terminal.ServiceTime(), 12*time.Second; got != want {
+		t.Errorf("expected service time %v, got:%v", want, got)
+	}
+}
+
+func Test_Position(t *testing.T) {
+	terminal, err := NewTerminal(10, WithAging(0), WithServiceTime(10*time.Second))
+	if err != nil {
+		t.Fatalf("expected error to be nil, got:%v", err)
+	}
+
+	first := orders.NewOrder(context.TODO(), 30, orders.Vegan)
+	second := orders.NewOrder(context.TODO(), 30, orders.Vegan)
+	staff := orders.NewOrder(context.TODO(), 30, orders.Vegan)
+	staff.Priority = orders.PriorityStaff
+	for _, order := range []*orders.Order{first, second, staff} {
+		terminal.Put(order)
+	}
+
+	// the order of a higher class is served first
+	for want, order := range []*orders.Order{staff, first, second} {
+		if got, ok := terminal.Position(order.ID); !ok || got != want+1 {
+			t.Errorf("expected position %d, got:%d", want+1, got)
+		}
+	}
+
+	// with two workers the third order is served in the second round
+	if got, want := terminal.EstimateWait(3, 2), 20*time.Second; got != want {
+		t.Errorf("expected wait %v, got:%v", want, got)
+	}
+	if got, want := terminal.CurrentWait(1), 40*time.Second; got != want {
+		t.Errorf("expected current wait %v, got:%v", want, got)
+	}
+
+	// an order that is taken out of the queue has no position
+	terminal.Get()
+	if _, ok := terminal.Position(staff.ID); ok {
+		t.Errorf("expected no position for an order that is not queued")
+	}
+	if got, ok := terminal.Position(second.ID); !ok || got != 2 {
+		t.Errorf("expected position 2, got:%d", got)
+	}
+}
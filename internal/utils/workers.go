@@ -8,31 +8,40 @@ import (
 	"github.com/azhovan/currywurst/internal/workers"
 )
 
-// CreateTerminalWorkers creates the workers and the terminals and returns them as a map and a cash register.
-// It takes the number of terminals as an argument and creates a worker and a terminal for each one.
-// It also creates a shared cash register for all the terminals.
-// It returns a map of terminal ids to terminals, a cash register, and an error if any.
-func CreateTerminalWorkers(terminalCount int) (map[string]*terminals.Terminal, *cashregister.CashRegister, error) {
-	terminalsMap := map[string]*terminals.Terminal{}
+// CreateTerminalWorkers creates the workers and the terminals and returns them as a registry and a cash register.
+// It takes the number of terminals as an argument and creates a terminal with a pool of one worker for each one.
+// It also creates a shared cash register for all the terminals, the terminals that are added to the registry
+// later get a pool of their own that shares the same cash register.
+// With stealing the idle workers take orders from the busiest terminal, so a skewed load is spread over all of them.
+// The given options are applied to every worker, i.e. to share an inventory between them.
+// It returns a registry of the terminals by id, a cash register, and an error if any.
+func CreateTerminalWorkers(terminalCount int, stealing bool, opts ...workers.Option) (*terminals.Registry, *cashregister.CashRegister, error) {
 	// cashRegister is the shared cash register between terminals
 	cashRegister := cashregister.NewCashRegister()
 	// this is just an arbitrary number! for demonstration purposes
 	terminalCapacity := 1 << 10
 
+	// each pool of workers only manages a specific terminal, its workers run in the background
+	// until the terminal is closed. A terminal starts with a single worker, the pool can be resized at runtime.
+	var registry *terminals.Registry
+	start := func(terminal *terminals.Terminal) (terminals.Workers, error) {
+		if stealing {
+			// the registry is set by the time a terminal is added
+			return workers.NewPool(terminal, cashRegister, 1, append(opts[:len(opts):len(opts)], workers.WithStealing(registry))...)
+		}
+		return workers.NewPool(terminal, cashRegister, 1, opts...)
+	}
+	registry, err := terminals.NewRegistry(terminalCapacity, start)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	// create works and associated terminal
-	// each worker only manages a specific terminal
 	for i := 0; i < terminalCount; i++ {
-		terminal, err := terminals.NewTerminal(terminalCapacity)
-		if err != nil {
+		if _, err = registry.Add("terminal-" + strconv.Itoa(i)); err != nil {
 			return nil, nil, err
 		}
-		terminalId := "terminal-" + strconv.Itoa(i)
-		worker := workers.NewWorker(terminal, cashRegister)
-		// run the worker in the background
-		go worker.Run()
-		// update the terminals map
-		terminalsMap[terminalId] = terminal
 	}
 
-	return terminalsMap, cashRegister, nil
+	return registry, cashRegister, nil
 }
@@ -6,14 +6,14 @@ import (
 
 func TestCreateTerminalWorkers(t *testing.T) {
 	// create the workers and the terminals
-	terminals, cashRegister, err := CreateTerminalWorkers(3)
+	terminals, cashRegister, err := CreateTerminalWorkers(3, true)
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	// check the number of terminals
-	if len(terminals) != 3 {
-		t.Errorf("expected 5 terminals, got %d", len(terminals))
+	if terminals.Len() != 3 {
+		t.Errorf("expected 3 terminals, got %d", terminals.Len())
 	}
 
 	// check the cash register
@@ -0,0 +1,364 @@
+// Package websocket implements the server side of the WebSocket protocol (RFC 6455) with the standard library only.
+// It supports text and binary messages, fragmented messages, and answers the ping and close frames of the client.
+// Extensions and subprotocols are not supported.
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// guid is the magic value of the opening handshake, defined by RFC 6455
+const guid = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// DefaultMaxMessageSize is the size in bytes of the largest message a connection reads by default.
+const DefaultMaxMessageSize = 64 << 10
+
+// DefaultWriteTimeout is the time a connection waits by default for a frame to be written, before it gives up.
+const DefaultWriteTimeout = 10 * time.Second
+
+var (
+	// ErrBadHandshake is the error returned when the request is not a valid WebSocket opening handshake.
+	ErrBadHandshake = errors.New("websocket: bad handshake")
+
+	// ErrClosed is the error returned when a message is written to a closed connection.
+	ErrClosed = errors.New("websocket: connection closed")
+)
+
+// MessageType is the type of a data message
+type MessageType int
+
+// Define the types of the data messages, their values are the opcodes of the frames
+const (
+	TextMessage   MessageType = 1
+	BinaryMessage MessageType = 2
+)
+
+// Define the opcodes of the frames
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// Define the status codes of the close frames that are used by this package
+const (
+	CloseNormal          = 1000 // The purpose of the connection has been fulfilled
+	CloseGoingAway       = 1001 // The server is going down
+	CloseProtocolError   = 1002 // The peer sent a frame that violates the protocol
+	CloseInvalidPayload  = 1007 // The peer sent a text message that is not valid UTF-8
+	ClosePolicyViolation = 1008 // The peer sent a message that violates the policy of the server
+	CloseMessageTooBig   = 1009 // The peer sent a message that is too big
+	CloseNoStatus        = 1005 // The close frame of the peer did not carry a status code
+)
+
+// CloseError is the error returned by ReadMessage once the peer closed the connection,
+// or the connection was closed because the peer violated the protocol.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+// Error returns the error message for CloseError.
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: closed with status %d %s", e.Code, e.Reason)
+}
+
+// Conn is a server side WebSocket connection.
+// A single goroutine may read from the connection, while any number of goroutines write to it.
+type Conn struct {
+	conn net.Conn
+	br   *bufio.Reader
+
+	// wmu serializes the writes of the frames
+	wmu sync.Mutex
+	// closed is set once the close frame has been sent
+	closed bool
+
+	// MaxMessageSize is the size in bytes of the largest message the connection reads.
+	MaxMessageSize int
+
+	// ReadTimeout is the time the connection waits for the next frame of the peer, any frame including a pong
+	// extends it. ReadMessage returns a timeout error once it is exceeded. Zero means no timeout.
+	ReadTimeout time.Duration
+
+	// WriteTimeout is the time the connection waits for a frame to be written. Zero means no timeout.
+	WriteTimeout time.Duration
+}
+
+// Upgrade validates the opening handshake of the client and takes over the connection of the request.
+// On failure it responds with an error and returns an error that wraps ErrBadHandshake.
+// The read and write deadlines of the server no longer apply to the returned connection,
+// it sets its own for every frame, see ReadTimeout and WriteTimeout.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return nil, fmt.Errorf("%w: method %s", ErrBadHandshake, r.Method)
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "Upgrade required", http.StatusUpgradeRequired)
+		return nil, fmt.Errorf("%w: not a websocket upgrade", ErrBadHandshake)
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "Unsupported version", http.StatusUpgradeRequired)
+		return nil, fmt.Errorf("%w: unsupported version", ErrBadHandshake)
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return nil, fmt.Errorf("%w: invalid key", ErrBadHandshake)
+	}
+
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, fmt.Errorf("%w: %v", ErrBadHandshake, err)
+	}
+	// the deadlines of the server were meant for the HTTP request
+	conn.SetDeadline(time.Time{})
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + AcceptKey(key) + "\r\n\r\n"
+	if _, err := rw.WriteString(response); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &Conn{conn: conn, br: rw.Reader, MaxMessageSize: DefaultMaxMessageSize, WriteTimeout: DefaultWriteTimeout}, nil
+}
+
+// AcceptKey returns the value of the Sec-WebSocket-Accept header for the given Sec-WebSocket-Key.
+func AcceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + guid))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// headerContains reports whether the comma separated values of the header contain the given token, case insensitive
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, v := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ReadMessage reads the next data message of the peer, the fragments of a message are joined together.
+// The ping frames are answered with a pong, and the pong frames only extend the read deadline.
+// Once the peer closes the connection, or violates the protocol, the close frame is sent back
+// and a *CloseError is returned. Any other error means the connection is broken.
+func (c *Conn) ReadMessage() (MessageType, []byte, error) {
+	var (
+		messageType MessageType
+		message     []byte
+	)
+
+	for {
+		// every frame of the peer, a pong as well, shows the peer is still there
+		if c.ReadTimeout > 0 {
+			c.conn.SetReadDeadline(time.Now().Add(c.ReadTimeout))
+		}
+
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			var closeErr *CloseError
+			if errors.As(err, &closeErr) {
+				c.Close(closeErr.Code, closeErr.Reason)
+			}
+			return 0, nil, err
+		}
+
+		switch opcode {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			closeErr := &CloseError{Code: CloseNoStatus}
+			if len(payload) >= 2 {
+				closeErr.Code = int(binary.BigEndian.Uint16(payload))
+				closeErr.Reason = string(payload[2:])
+			}
+			// the close frame is echoed with the status code of the peer
+			code := closeErr.Code
+			if code == CloseNoStatus {
+				code = CloseNormal
+			}
+			c.Close(code, "")
+			return 0, nil, closeErr
+		case opText, opBinary:
+			if messageType != 0 {
+				return 0, nil, c.fail(CloseProtocolError, "unexpected new message")
+			}
+			messageType = MessageType(opcode)
+		case opContinuation:
+			if messageType == 0 {
+				return 0, nil, c.fail(CloseProtocolError, "unexpected continuation")
+			}
+		default:
+			return 0, nil, c.fail(CloseProtocolError, "unknown opcode")
+		}
+
+		if len(message)+len(payload) > c.MaxMessageSize {
+			return 0, nil, c.fail(CloseMessageTooBig, "message too big")
+		}
+		message = append(message, payload...)
+
+		if fin {
+			if messageType == TextMessage && !utf8.Valid(message) {
+				return 0, nil, c.fail(CloseInvalidPayload, "invalid utf-8")
+			}
+			return messageType, message, nil
+		}
+	}
+}
+
+// fail closes the connection because the peer violated the protocol and returns the matching *CloseError
+func (c *Conn) fail(code int, reason string) error {
+	c.Close(code, reason)
+	return &CloseError{Code: code, Reason: reason}
+}
+
+// readFrame reads a single frame of the peer and unmasks its payload
+func (c *Conn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(c.br, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0F
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, &CloseError{Code: CloseProtocolError, Reason: "reserved bits are set"}
+	}
+	// the frames of a client are always masked
+	if header[1]&0x80 == 0 {
+		return false, 0, nil, &CloseError{Code: CloseProtocolError, Reason: "frame is not masked"}
+	}
+
+	length := uint64(header[1] & 0x7F)
+	control := opcode&0x8 != 0
+	if control && (!fin || length > 125) {
+		return false, 0, nil, &CloseError{Code: CloseProtocolError, Reason: "invalid control frame"}
+	}
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > uint64(c.MaxMessageSize) {
+		return false, 0, nil, &CloseError{Code: CloseMessageTooBig, Reason: "message too big"}
+	}
+
+	var mask [4]byte
+	if _, err = io.ReadFull(c.br, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return fin, opcode, payload, nil
+}
+
+// WriteMessage writes a data message to the peer in a single frame. It is safe for concurrent use.
+func (c *Conn) WriteMessage(messageType MessageType, data []byte) error {
+	return c.writeFrame(byte(messageType), data)
+}
+
+// Ping writes a ping frame to the peer, the peer answers with a pong.
+func (c *Conn) Ping(data []byte) error {
+	return c.writeFrame(opPing, data)
+}
+
+// writeFrame writes a single unmasked final frame to the peer
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	if c.closed {
+		return ErrClosed
+	}
+	if opcode == opClose {
+		c.closed = true
+	}
+
+	frame := make([]byte, 0, len(payload)+10)
+	frame = append(frame, 0x80|opcode)
+	switch {
+	case len(payload) <= 125:
+		frame = append(frame, byte(len(payload)))
+	case len(payload) <= 0xFFFF:
+		frame = append(frame, 126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, 127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+	frame = append(frame, payload...)
+
+	// a peer that stopped reading does not hold up the writers for good
+	if c.WriteTimeout > 0 {
+		c.conn.SetWriteDeadline(time.Now().Add(c.WriteTimeout))
+	}
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// Close sends a close frame with the given status code and reason, and closes the connection.
+// It is safe to call it more than once.
+func (c *Conn) Close(code int, reason string) error {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	if len(reason) > 123 {
+		reason = reason[:123]
+	}
+	payload = append(payload, reason...)
+
+	err := c.writeFrame(opClose, payload)
+	if errors.Is(err, ErrClosed) {
+		return nil
+	}
+	c.conn.Close()
+	return err
+}
@@ -0,0 +1,270 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dial opens a websocket connection to the server of the test, and returns the raw connection of the client
+func dial(t *testing.T, url string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	if err != nil {
+		t.Fatalf("net.Dial() got error:%v, want nil", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+	request := "GET / HTTP/1.1\r\nHost: test\r\nUpgrade: websocket\r\nConnection: keep-alive, Upgrade\r\n" +
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: " + key + "\r\n\r\n"
+	if _, err := conn.Write([]byte(request)); err != nil {
+		t.Fatalf("conn.Write() got error:%v, want nil", err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatalf("http.ReadResponse() got error:%v, want nil", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("got status:%d, want:%d", resp.StatusCode, http.StatusSwitchingProtocols)
+	}
+	// the example of RFC 6455
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("got Sec-WebSocket-Accept:%s, want:%s", got, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=")
+	}
+
+	return conn, br
+}
+
+// writeClientFrame writes a masked frame, the way a client does
+func writeClientFrame(t *testing.T, conn net.Conn, fin bool, opcode byte, payload []byte) {
+	t.Helper()
+
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	frame := []byte{first}
+	switch {
+	case len(payload) <= 125:
+		frame = append(frame, 0x80|byte(len(payload)))
+	default:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	}
+
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	if _, err := conn.Write(frame); err != nil {
+		t.Fatalf("conn.Write() got error:%v, want nil", err)
+	}
+}
+
+// readServerFrame reads an unmasked frame, the way a client does
+func readServerFrame(t *testing.T, br *bufio.Reader) (byte, []byte) {
+	t.Helper()
+
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(br, header); err != nil {
+		t.Fatalf("reading the frame got error:%v, want nil", err)
+	}
+	length := int(header[1] & 0x7F)
+	if length == 126 {
+		ext := make([]byte, 2)
+		io.ReadFull(br, ext)
+		length = int(binary.BigEndian.Uint16(ext))
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(br, payload); err != nil {
+		t.Fatalf("reading the payload got error:%v, want nil", err)
+	}
+	return header[0] & 0x0F, payload
+}
+
+// echoServer returns a server that echoes the messages of the client, and reports the error that ended the connection
+func echoServer(t *testing.T, maxMessageSize int) (*httptest.Server, chan error) {
+	t.Helper()
+
+	errs := make(chan error, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			errs <- err
+			return
+		}
+		if maxMessageSize > 0 {
+			conn.MaxMessageSize = maxMessageSize
+		}
+		for {
+			messageType, message, err := conn.ReadMessage()
+			if err != nil {
+				errs <- err
+				return
+			}
+			conn.WriteMessage(messageType, message)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server, errs
+}
+
+func TestUpgrade_BadHandshake(t *testing.T) {
+	server, errs := echoServer(t, 0)
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("http.Get() got error:%v, want nil", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUpgradeRequired {
+		t.Errorf("got status:%d, want:%d", resp.StatusCode, http.StatusUpgradeRequired)
+	}
+	if err := <-errs; !errors.Is(err, ErrBadHandshake) {
+		t.Errorf("Upgrade() got error:%v, want:%v", err, ErrBadHandshake)
+	}
+}
+
+func TestConn_ReadMessage(t *testing.T) {
+	server, errs := echoServer(t, 0)
+	conn, br := dial(t, server.URL)
+
+	// a single frame message
+	writeClientFrame(t, conn, true, opText, []byte(`{"type":"auth"}`))
+	if opcode, payload := readServerFrame(t, br); opcode != opText || string(payload) != `{"type":"auth"}` {
+		t.Errorf("got frame %d %q, want:%d %q", opcode, payload, opText, `{"type":"auth"}`)
+	}
+
+	// a fragmented message with a ping in between
+	long := bytes.Repeat([]byte("a"), 300)
+	writeClientFrame(t, conn, false, opText, long)
+	writeClientFrame(t, conn, true, opPing, []byte("ping"))
+	writeClientFrame(t, conn, true, opContinuation, []byte("b"))
+
+	if opcode, payload := readServerFrame(t, br); opcode != opPong || string(payload) != "ping" {
+		t.Errorf("got frame %d %q, want:%d %q", opcode, payload, opPong, "ping")
+	}
+	if opcode, payload := readServerFrame(t, br); opcode != opText || string(payload) != string(long)+"b" {
+		t.Errorf("got frame %d of %d bytes, want:%d of %d bytes", opcode, len(payload), opText, len(long)+1)
+	}
+
+	// the close frame is echoed
+	writeClientFrame(t, conn, true, opClose, binary.BigEndian.AppendUint16(nil, CloseNormal))
+	if opcode, payload := readServerFrame(t, br); opcode != opClose || binary.BigEndian.Uint16(payload) != CloseNormal {
+		t.Errorf("got frame %d %v, want close frame with status %d", opcode, payload, CloseNormal)
+	}
+
+	var closeErr *CloseError
+	if err := <-errs; !errors.As(err, &closeErr) || closeErr.Code != CloseNormal {
+		t.Errorf("ReadMessage() got error:%v, want close error with status %d", err, CloseNormal)
+	}
+}
+
+func TestConn_ReadMessage_ProtocolErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		write  func(t *testing.T, conn net.Conn)
+		status int
+	}{
+		{
+			name: "unmasked frame",
+			write: func(t *testing.T, conn net.Conn) {
+				conn.Write([]byte{0x80 | opText, 2, 'h', 'i'})
+			},
+			status: CloseProtocolError,
+		},
+		{
+			name: "invalid utf-8",
+			write: func(t *testing.T, conn net.Conn) {
+				writeClientFrame(t, conn, true, opText, []byte{0xff, 0xfe})
+			},
+			status: CloseInvalidPayload,
+		},
+		{
+			name: "message too big",
+			write: func(t *testing.T, conn net.Conn) {
+				writeClientFrame(t, conn, true, opBinary, bytes.Repeat([]byte("a"), 200))
+			},
+			status: CloseMessageTooBig,
+		},
+		{
+			name: "unexpected continuation",
+			write: func(t *testing.T, conn net.Conn) {
+				writeClientFrame(t, conn, true, opContinuation, []byte("a"))
+			},
+			status: CloseProtocolError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, errs := echoServer(t, 100)
+			conn, br := dial(t, server.URL)
+
+			tt.write(t, conn)
+
+			opcode, payload := readServerFrame(t, br)
+			if opcode != opClose || binary.BigEndian.Uint16(payload) != uint16(tt.status) {
+				t.Errorf("got frame %d %v, want close frame with status %d", opcode, payload, tt.status)
+			}
+			var closeErr *CloseError
+			if err := <-errs; !errors.As(err, &closeErr) || closeErr.Code != tt.status {
+				t.Errorf("ReadMessage() got error:%v, want close error with status %d", err, tt.status)
+			}
+		})
+	}
+}
+
+func TestConn_ReadTimeout(t *testing.T) {
+	errs := make(chan error, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			errs <- err
+			return
+		}
+		conn.ReadTimeout = 50 * time.Millisecond
+		for {
+			messageType, message, err := conn.ReadMessage()
+			if err != nil {
+				errs <- err
+				return
+			}
+			conn.WriteMessage(messageType, message)
+		}
+	}))
+	t.Cleanup(server.Close)
+	conn, br := dial(t, server.URL)
+
+	// the pongs of the client keep the connection alive, long after the timeout
+	for i := 0; i < 6; i++ {
+		writeClientFrame(t, conn, true, opPong, nil)
+		time.Sleep(20 * time.Millisecond)
+	}
+	writeClientFrame(t, conn, true, opText, []byte("still there"))
+	if opcode, payload := readServerFrame(t, br); opcode != opText || string(payload) != "still there" {
+		t.Errorf("got frame %d %q, want:%d %q", opcode, payload, opText, "still there")
+	}
+
+	// a client that sends nothing times out
+	var netErr net.Error
+	if err := <-errs; !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("ReadMessage() got error:%v, want a timeout", err)
+	}
+}
@@ -0,0 +1,91 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+
+	"github.com/azhovan/currywurst/internal/cashregister"
+	"github.com/azhovan/currywurst/internal/terminals"
+)
+
+// ErrInvalidPoolSize is the error returned when a pool is given less than one worker.
+var ErrInvalidPoolSize = errors.New("invalid pool size, a terminal needs at least one worker")
+
+// Pool is a group of workers that process the orders of the same terminal, so a busy terminal is served
+// by more than one cook. The size of the pool can be changed while the workers are running.
+// It is safe for concurrent use.
+type Pool struct {
+	terminal *terminals.Terminal
+	cr       *cashregister.CashRegister
+	opts     []Option
+
+	mu sync.Mutex
+	// running holds the workers that are running, a worker leaves once it stops
+	running []*poolWorker
+}
+
+// poolWorker is a worker of the pool that is running in the background
+type poolWorker struct {
+	stop context.CancelFunc // tells the worker to stop
+}
+
+// NewPool starts the given number of workers on the terminal and returns their pool.
+// The workers share the cash register, and the given options are applied to every worker.
+func NewPool(t *terminals.Terminal, cr *cashregister.CashRegister, size int, opts ...Option) (*Pool, error) {
+	p := &Pool{terminal: t, cr: cr, opts: opts}
+	if err := p.Resize(size); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Size returns the number of workers of the pool that are running.
+// The workers stop on their own once the terminal is closed, they are no longer counted then.
+func (p *Pool) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.running)
+}
+
+// Resize starts or stops workers until the pool has the given number of workers.
+// A worker that is stopped finishes the order it is processing first.
+// It returns ErrInvalidPoolSize if the size is less than one.
+func (p *Pool) Resize(size int) error {
+	if size < 1 {
+		return ErrInvalidPoolSize
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for len(p.running) < size {
+		ctx, stop := context.WithCancel(context.Background())
+		worker := NewWorker(p.terminal, p.cr, p.opts...)
+		running := &poolWorker{stop: stop}
+		// run the worker in the background, it leaves the pool once it stops
+		go func() {
+			worker.RunContext(ctx)
+			p.leave(running)
+		}()
+		p.running = append(p.running, running)
+	}
+	for len(p.running) > size {
+		last := len(p.running) - 1
+		p.running[last].stop()
+		p.running = p.running[:last]
+	}
+	return nil
+}
+
+// leave takes a worker that stopped out of the pool, a worker that was stopped by Resize has left already
+func (p *Pool) leave(worker *poolWorker) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	worker.stop()
+	if i := slices.Index(p.running, worker); i >= 0 {
+		p.running = slices.Delete(p.running, i, i+1)
+	}
+}
@@ -0,0 +1,100 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/azhovan/currywurst/internal/cashregister"
+	"github.com/azhovan/currywurst/internal/orders"
+	"github.com/azhovan/currywurst/internal/terminals"
+)
+
+func Test_Pool(t *testing.T) {
+	tm, err := terminals.NewTerminal(100)
+	if err != nil {
+		t.Fatalf("expected error to be nil, got:%v", err)
+	}
+	defer tm.Close()
+
+	pool, err := NewPool(tm, cashregister.NewCashRegister(), 3)
+	if err != nil {
+		t.Fatalf("expected nil error, got:%v", err)
+	}
+	if pool.Size() != 3 {
+		t.Errorf("expected 3 workers, got:%d", pool.Size())
+	}
+
+	// the workers share the queue, every order is processed once.
+	// The orders are paid with the exact price, so they do not use up the change of the shared stock
+	var placed []*orders.Order
+	for i := 0; i < 50; i++ {
+		order := orders.NewOrder(context.TODO(), 30, orders.Vegan)
+		tm.Put(order)
+		placed = append(placed, order)
+	}
+	for _, order := range placed {
+		if err = order.WaitWithTimeout(5 * time.Second); err != nil || order.State() != orders.StateReady {
+			t.Fatalf("expected the order to be %s, got:%s error:%v", orders.StateReady, order.State(), err)
+		}
+	}
+
+	// the pool is resized at runtime
+	if err = pool.Resize(1); err != nil || pool.Size() != 1 {
+		t.Errorf("expected 1 worker, got:%d error:%v", pool.Size(), err)
+	}
+	order := orders.NewOrder(context.TODO(), 30, orders.Vegan)
+	tm.Put(order)
+	if err = order.WaitWithTimeout(5 * time.Second); err != nil {
+		t.Errorf("expected the remaining worker to process the order, got error:%v", err)
+	}
+
+	if err = pool.Resize(0); !errors.Is(err, ErrInvalidPoolSize) {
+		t.Errorf("expected error type %v, got %v", ErrInvalidPoolSize, err)
+	}
+}
+
+func Test_RunContext(t *testing.T) {
+	tm, err := terminals.NewTerminal(1)
+	if err != nil {
+		t.Fatalf("expected error to be nil, got:%v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		NewWorker(tm, cashregister.NewCashRegister()).RunContext(ctx)
+		close(stopped)
+	}()
+
+	// the worker waits for an order on the empty terminal, until it is told to stop
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatalf("expected the worker to stop once the context is done")
+	}
+}
+
+func Test_PoolClosedTerminal(t *testing.T) {
+	tm, err := terminals.NewTerminal(10)
+	if err != nil {
+		t.Fatalf("expected error to be nil, got:%v", err)
+	}
+
+	pool, err := NewPool(tm, cashregister.NewCashRegister(), 2)
+	if err != nil {
+		t.Fatalf("expected nil error, got:%v", err)
+	}
+
+	// the workers stop once the terminal is closed, and are no longer counted
+	tm.SetState(terminals.StateClosed, "end of the season")
+	deadline := time.Now().Add(5 * time.Second)
+	for pool.Size() != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if pool.Size() != 0 {
+		t.Errorf("expected no workers once the terminal is closed, got:%d", pool.Size())
+	}
+}
@@ -0,0 +1,149 @@
+package workers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/azhovan/currywurst/internal/cashregister"
+	"github.com/azhovan/currywurst/internal/orders"
+	"github.com/azhovan/currywurst/internal/terminals"
+)
+
+// skewedLoad places all the orders on one of two terminals, and returns the longest time an order took to be ready
+func skewedLoad(t *testing.T, stealing bool) time.Duration {
+	t.Helper()
+
+	const prepare = 5 * time.Millisecond
+	cr := cashregister.NewCashRegister()
+	var registry *terminals.Registry
+	registry, err := terminals.NewRegistry(100, func(tm *terminals.Terminal) (terminals.Workers, error) {
+		opts := []Option{WithPreparationTime(prepare)}
+		if stealing {
+			opts = append(opts, WithStealing(registry))
+		}
+		return NewPool(tm, cr, 1, opts...)
+	})
+	if err != nil {
+		t.Fatalf("expected error to be nil, got:%v", err)
+	}
+	busy, err := registry.Add("busy")
+	if err != nil {
+		t.Fatalf("expected error to be nil, got:%v", err)
+	}
+	if _, err = registry.Add("idle"); err != nil {
+		t.Fatalf("expected error to be nil, got:%v", err)
+	}
+	defer func() {
+		for _, id := range registry.IDs() {
+			registry.Remove(id, terminals.CloseFail)
+		}
+	}()
+
+	// the orders are paid with the exact price, so they do not use up the change of the shared stock
+	start := time.Now()
+	var placed []*orders.Order
+	for i := 0; i < 20; i++ {
+		order := orders.NewOrder(context.TODO(), 30, orders.Vegan)
+		if err = busy.Put(order); err != nil {
+			t.Fatalf("expected error to be nil, got:%v", err)
+		}
+		placed = append(placed, order)
+	}
+
+	var slowest time.Duration
+	for _, order := range placed {
+		if err = order.WaitWithTimeout(5 * time.Second); err != nil || order.State() != orders.StateReady {
+			t.Fatalf("expected the order to be %s, got:%s error:%v", orders.StateReady, order.State(), err)
+		}
+		history := order.History()
+		if took := history[len(history)-1].At.Sub(start); took > slowest {
+			slowest = took
+		}
+	}
+	return slowest
+}
+
+func Test_Stealing(t *testing.T) {
+	without := skewedLoad(t, false)
+	with := skewedLoad(t, true)
+
+	// the idle worker takes about half of the orders, so the last order is ready much sooner
+	if with >= without*3/4 {
+		t.Errorf("expected stealing to lower the tail latency, got:%v without stealing:%v", with, without)
+	}
+}
+
+func Test_StealingServiceTime(t *testing.T) {
+	cr := cashregister.NewCashRegister()
+	var registry *terminals.Registry
+	registry, _ = terminals.NewRegistry(100, func(tm *terminals.Terminal) (terminals.Workers, error) {
+		return NewPool(tm, cr, 1, WithPreparationTime(time.Millisecond), WithStealing(registry))
+	})
+	busy, _ := registry.Add("busy")
+	idle, _ := registry.Add("idle")
+	defer func() {
+		for _, id := range registry.IDs() {
+			registry.Remove(id, terminals.CloseFail)
+		}
+	}()
+
+	var placed []*orders.Order
+	for i := 0; i < 10; i++ {
+		order := orders.NewOrder(context.TODO(), 30, orders.Vegan)
+		busy.Put(order)
+		placed = append(placed, order)
+	}
+	for _, order := range placed {
+		if err := order.WaitWithTimeout(5 * time.Second); err != nil || order.State() != orders.StateReady {
+			t.Fatalf("expected the order to be %s, got:%s error:%v", orders.StateReady, order.State(), err)
+		}
+	}
+
+	// the stolen orders count for the terminal they were queued on, the idle terminal has served none of its own
+	if got := idle.ServiceTime(); got != terminals.DefaultServiceTime {
+		t.Errorf("expected the service time of the idle terminal to be %v, got:%v", terminals.DefaultServiceTime, got)
+	}
+	if got := busy.ServiceTime(); got >= terminals.DefaultServiceTime {
+		t.Errorf("expected the service time of the busy terminal to be measured, got:%v", got)
+	}
+}
+
+// closingVictims closes the terminal of the worker with CloseFail once it steals, and hands it the victim
+type closingVictims struct {
+	victim *terminals.Terminal
+}
+
+// Busiest implements Victims
+func (v closingVictims) Busiest(except *terminals.Terminal) *terminals.Terminal {
+	except.CloseWith(terminals.CloseFail)
+	return v.victim
+}
+
+func Test_StealingOwnTerminalClosed(t *testing.T) {
+	own, _ := terminals.NewTerminal(10)
+	victim, _ := terminals.NewTerminal(10)
+
+	stolen := orders.NewOrder(context.TODO(), 30, orders.Vegan)
+	if err := victim.Put(stolen); err != nil {
+		t.Fatalf("expected error to be nil, got:%v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		NewWorker(own, cashregister.NewCashRegister(), WithStealing(closingVictims{victim: victim})).Run()
+		close(done)
+	}()
+
+	// the order of the healthy terminal is served, even though the terminal of the worker is closed meanwhile
+	if err := stolen.WaitWithTimeout(5 * time.Second); err != nil || stolen.State() != orders.StateReady {
+		t.Fatalf("expected the order to be %s, got:%s error:%v", orders.StateReady, stolen.State(), err)
+	}
+
+	// and the worker stops, its own terminal is closed
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Errorf("expected the worker to stop once its terminal is closed")
+	}
+}
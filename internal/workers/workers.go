@@ -1,33 +1,87 @@
 package workers
 
 import (
+	"context"
+	"time"
+
 	"github.com/azhovan/currywurst/internal/cashregister"
+	"github.com/azhovan/currywurst/internal/inventory"
 	"github.com/azhovan/currywurst/internal/orders"
 	"github.com/azhovan/currywurst/internal/terminals"
-	"github.com/azhovan/currywurst/pkg"
 )
 
 // Worker represents a worker that can process orders from a terminal and return change using a cash register.
 // A worker has a reference to a terminal and a cash register, and can run a loop that reads orders
 // from the terminal, validates them, pays them using the cash register, and returns the change to the customer.
 type Worker struct {
-	terminal *terminals.Terminal
-	cr       *cashregister.CashRegister
+	terminal  *terminals.Terminal
+	cr        *cashregister.CashRegister
+	inventory *inventory.Inventory // optional, the ingredients of the orders are not tracked when it is nil
+	victims   Victims              // optional, the worker only serves its own terminal when it is nil
+	prepare   time.Duration        // The time it takes to prepare a paid order
+}
+
+// StealInterval is how long an idle worker that steals orders waits for its own terminal,
+// before it looks at the queues of the other terminals again.
+const StealInterval = 10 * time.Millisecond
+
+// Victims are the terminals an idle worker can steal orders from.
+type Victims interface {
+	// Busiest returns the terminal with the most queued orders other than the given one, or nil if there is none.
+	Busiest(except *terminals.Terminal) *terminals.Terminal
+}
+
+// Option is a function that modifies the worker
+type Option func(*Worker)
+
+// WithInventory sets the inventory the worker consumes the ingredients of the orders from
+func WithInventory(inv *inventory.Inventory) Option {
+	return func(w *Worker) {
+		w.inventory = inv
+	}
+}
+
+// WithStealing lets the worker take orders from the busiest of the given terminals whenever its own terminal
+// has nothing queued. The orders are taken from the head of the other queue, so every terminal keeps its order.
+func WithStealing(victims Victims) Option {
+	return func(w *Worker) {
+		w.victims = victims
+	}
+}
+
+// WithPreparationTime sets the time it takes the worker to prepare a paid order, before it is ready.
+func WithPreparationTime(d time.Duration) Option {
+	return func(w *Worker) {
+		w.prepare = d
+	}
 }
 
-// NewWorker returns a new worker instance with the given terminal and cash register.
+// NewWorker returns a new worker instance with the given terminal, cash register and options.
 // It does not start the worker loop; use the Run method for that.
-func NewWorker(t *terminals.Terminal, cr *cashregister.CashRegister) *Worker {
-	return &Worker{
+func NewWorker(t *terminals.Terminal, cr *cashregister.CashRegister, opts ...Option) *Worker {
+	w := &Worker{
 		terminal: t,
 		cr:       cr,
 	}
+
+	// apply the options
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	return w
 }
 
 // Run starts the worker loop that processes orders from the terminal and returns change using the cash register.
 // It expects the terminal and the cash register to be non-nil and initialized.
 // It sets the Error field of the order if any error occurs during the payment process.
 func (w *Worker) Run() {
+	w.RunContext(context.Background())
+}
+
+// RunContext is like Run, but the worker also stops once the context is done.
+// The order the worker is processing at that time is finished first.
+func (w *Worker) RunContext(ctx context.Context) {
 	// unrecoverable state
 	// we may log here, for simplicity lets just exit
 	if w.terminal == nil {
@@ -41,32 +95,54 @@ func (w *Worker) Run() {
 	}
 
 	for {
-		// Get() blocks until there is a new order
+		// next() blocks until there is a new order
 		// it also has internal check for order cancellation and terminal closing
-		order, err := w.terminal.Get()
+		order, from, err := w.next(ctx)
+		taken := time.Now()
 
 		switch err {
 		// there is nothing to do here. we may log it as well
-		// for simplicity we just exit
-		case terminals.ErrTerminalNil:
-		case terminals.ErrTerminalClosed:
+		// for simplicity we just exit. The worker is also told to stop through the context.
+		case terminals.ErrTerminalNil, terminals.ErrTerminalClosed, context.Canceled, context.DeadlineExceeded:
 			return
+		// the order has been cancelled by the customer while it was waiting in the queue,
+		// Cancel is idempotent and only makes sure the cancellation is recorded in the order lifecycle
+		case orders.ErrOrderCancelled:
+			order.Cancel()
+			continue
 		// we may log it as well
 		// for simplicity lets just move to next
-		case orders.ErrOrderNil:
-		case orders.ErrOrderCancelled:
-		case terminals.ErrTerminalEmpty:
+		case orders.ErrOrderNil, terminals.ErrTerminalEmpty:
 			continue
 		default:
 		}
 
+		// an order that was paid before the server restarted is handed back by the crash recovery,
+		// it is only prepared again. The stock is counted from the start of the server, so its ingredients
+		// are consumed again, and the payment is refunded if they ran out.
+		if order.State() == orders.StatePreparing {
+			if w.inventory != nil {
+				if err = w.inventory.Consume(order.Items); err != nil {
+					order.Recover(orders.StateFailed, err.Error())
+					continue
+				}
+			}
+			w.serve(order, from, taken)
+			continue
+		}
+
+		// the order may have been cancelled since it was taken from the queue,
+		// in that case it is no longer processed
+		if err = order.Transition(orders.StateValidating); err != nil {
+			continue
+		}
+
 		// The order validation can also happen in the HTTP handler or in the Put() method of terminal.
 		// but having it here is more concise, because we don't have to
 		// expose the internal implementation details in the HTTP handler nor
 		// complicate sending order to terminal. besides this is very inexpensive operation.
 		if err = order.Validate(); err != nil {
-			order.Error = err
-			order.Ready <- false
+			order.Fail(err)
 			continue
 		}
 
@@ -74,31 +150,101 @@ func (w *Worker) Run() {
 		// cancelled or the terminal is still open
 		orderCancelled, orderErr := order.IsCancelled()
 		if orderCancelled && orderErr == nil {
+			order.Cancel()
 			continue
 		}
 
-		// if terminal has been closed, we won't proceed with the order and exit
-		terminalClosed, terminalErr := w.terminal.IsClosed()
-		if terminalClosed && terminalErr == nil {
-			return
+		// if the terminal the order was taken from has been closed and it fails its orders, we won't proceed
+		// with the order. A terminal that drains its queue lets the worker finish the order.
+		// The worker only stops once its own terminal is closed, a stolen order says nothing about it.
+		if mode, closed := from.ClosedWith(); closed && mode == terminals.CloseFail {
+			order.Fail(terminals.ErrTerminalClosed)
+			if from == w.terminal {
+				return
+			}
+			continue
+		}
+
+		// this is the point of no return, once the order is being paid it can no longer be cancelled.
+		// If the customer cancelled the order in the meantime, the transition fails and the order is dropped.
+		if err = order.Transition(orders.StatePaying); err != nil {
+			continue
 		}
 
-		// There is no need to check whether the orderType is nil or not
+		// There is no need to check whether the order types are valid or not
 		// this has happened already in the validation step, so we are confident that
-		// order at this stage is valid and has proper price
-		price := pkg.GetOrderType(order.OrderType.String()).
-			Price()
+		// order at this stage is valid and has proper price.
+		// The whole basket is paid at once, so the customer gets a single change.
+		price := order.Total()
+
+		// the ingredients are consumed right before the payment, so an order that
+		// can not be made is never paid. They are given back if the payment fails.
+		if w.inventory != nil {
+			if err = w.inventory.Consume(order.Items); err != nil {
+				order.Fail(err)
+				continue
+			}
+		}
 
 		// calculate the returned price
 		returned, err := w.cr.Pay(price, order.Inserted)
 		if err != nil {
-			order.Error = err
-			order.Ready <- false
-
+			if w.inventory != nil {
+				w.inventory.Release(order.Items)
+			}
+			order.Fail(err)
 			continue
 		}
 
-		order.Ready <- false
-		order.Returned = returned
+		// the order has been paid, it is prepared and handed over to the customer
+		order.Paid(returned)
+		w.serve(order, from, taken)
+	}
+}
+
+// serve prepares the paid order, that was taken from the given terminal at the given time,
+// and makes it ready for the customer
+func (w *Worker) serve(order *orders.Order, from *terminals.Terminal, taken time.Time) {
+	if w.prepare > 0 {
+		time.Sleep(w.prepare)
+	}
+	if order.Transition(orders.StateReady) == nil {
+		// the pace of the terminal the order was queued on is tracked, it tells its customers how long they wait.
+		// An order that is stolen counts for the terminal it came from, not for the terminal of the worker.
+		from.Served(time.Since(taken))
+	}
+}
+
+// next returns the next order the worker processes, along with the terminal it is taken from.
+// It is the first order of the worker's terminal, or, if stealing is enabled and the terminal has nothing queued,
+// the first order of the busiest terminal.
+func (w *Worker) next(ctx context.Context) (*orders.Order, *terminals.Terminal, error) {
+	if w.victims == nil {
+		order, err := w.terminal.GetContext(ctx)
+		return order, w.terminal, err
+	}
+
+	for {
+		order, err := w.terminal.TryGet()
+		if err != terminals.ErrTerminalEmpty {
+			return order, w.terminal, err
+		}
+
+		// the worker is idle, it helps out the terminal with the longest queue
+		if victim := w.victims.Busiest(w.terminal); victim != nil {
+			order, err := victim.TryGet()
+			if err != terminals.ErrTerminalEmpty && err != terminals.ErrTerminalClosed {
+				return order, victim, err
+			}
+		}
+
+		// wait for an order of its own for a while, and look again
+		wait, cancel := context.WithTimeout(ctx, StealInterval)
+		order, err = w.terminal.GetContext(wait)
+		cancel()
+		if err == context.DeadlineExceeded && ctx.Err() == nil {
+			continue
+		}
+		return order, w.terminal, err
 	}
 }
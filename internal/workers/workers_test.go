@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/azhovan/currywurst/internal/cashregister"
+	"github.com/azhovan/currywurst/internal/inventory"
 	"github.com/azhovan/currywurst/internal/orders"
 	"github.com/azhovan/currywurst/internal/terminals"
 )
@@ -36,6 +37,10 @@ func Test_Run(t *testing.T) {
 	if order.Error != nil {
 		t.Errorf("expected nil error, got:%v", order.Error)
 	}
+
+	if order.State() != orders.StateReady {
+		t.Errorf("expected order state %s, got:%s", orders.StateReady, order.State())
+	}
 }
 
 func Test_CancelledOrderByCustomer(t *testing.T) {
@@ -64,6 +69,10 @@ func Test_CancelledOrderByCustomer(t *testing.T) {
 	if !errors.Is(err, orders.ErrOrderCancelled) {
 		t.Errorf("expected error type %v, got %v", orders.ErrOrderCancelled, err)
 	}
+
+	if order.State() != orders.StateCancelled {
+		t.Errorf("expected order state %s, got:%s", orders.StateCancelled, order.State())
+	}
 }
 
 func Test_InvalidOrder(t *testing.T) {
@@ -94,3 +103,128 @@ func Test_InvalidOrder(t *testing.T) {
 		t.Errorf("expected error type %v, got %v", e, order.Error)
 	}
 }
+
+func Test_BasketOrder(t *testing.T) {
+	tm, err := terminals.NewTerminal(1)
+	if err != nil {
+		t.Fatalf("expected error to be nil, got:%v", err)
+	}
+
+	cr := cashregister.NewCashRegister()
+
+	// two vegan and one non-vegan currywurst are paid at once
+	order := orders.NewBasketOrder(context.TODO(), 100, []orders.Item{
+		{OrderType: orders.Vegan, Quantity: 2},
+		{OrderType: orders.NonVegan, Quantity: 1},
+	})
+	err = tm.Put(order)
+	if err != nil {
+		t.Fatalf("failed to send new order to terminal, err:%v", err)
+	}
+
+	workers := NewWorker(tm, cr)
+	go workers.Run()
+
+	err = order.WaitWithTimeout(time.Second * 20)
+	if err != nil {
+		t.Fatalf("expected nil error, got:%v", err)
+	}
+	if order.Error != nil {
+		t.Fatalf("expected nil error, got:%v", order.Error)
+	}
+
+	if order.Returned.Cents != 5 {
+		t.Errorf("expected returned cents %d, got:%d", 5, order.Returned.Cents)
+	}
+}
+
+func Test_OutOfStockOrder(t *testing.T) {
+	tm, err := terminals.NewTerminal(2)
+	if err != nil {
+		t.Fatalf("expected error to be nil, got:%v", err)
+	}
+
+	cr := cashregister.NewCashRegister()
+	inv := inventory.NewInventory(inventory.DefaultRecipes(), map[inventory.Ingredient]int{
+		inventory.VeganSausage: 1,
+		inventory.Sauce:        10,
+	})
+
+	// the first order takes the last vegan sausage
+	first := orders.NewOrder(context.TODO(), 30, orders.Vegan)
+	second := orders.NewOrder(context.TODO(), 30, orders.Vegan)
+	for _, order := range []*orders.Order{first, second} {
+		if err = tm.Put(order); err != nil {
+			t.Fatalf("failed to send new order to terminal, err:%v", err)
+		}
+	}
+
+	workers := NewWorker(tm, cr, WithInventory(inv))
+	go workers.Run()
+
+	for _, order := range []*orders.Order{first, second} {
+		if err = order.WaitWithTimeout(time.Second * 20); err != nil {
+			t.Fatalf("expected nil error, got:%v", err)
+		}
+	}
+
+	if first.Error != nil {
+		t.Errorf("expected nil error, got:%v", first.Error)
+	}
+	if !errors.Is(second.Error, inventory.ErrOutOfStock) {
+		t.Errorf("expected error type %v, got %v", inventory.ErrOutOfStock, second.Error)
+	}
+	if inv.Available(orders.Vegan) {
+		t.Errorf("expected vegan currywurst to be sold out")
+	}
+}
+
+func Test_RequeuedOrder(t *testing.T) {
+	tm, err := terminals.NewTerminal(2)
+	if err != nil {
+		t.Fatalf("expected error to be nil, got:%v", err)
+	}
+
+	cr := cashregister.NewCashRegister()
+	inv := inventory.NewInventory(inventory.DefaultRecipes(), map[inventory.Ingredient]int{
+		inventory.VeganSausage: 1,
+		inventory.Sauce:        10,
+	})
+
+	// the orders were paid before the server restarted, and are handed back by the crash recovery
+	var requeued []*orders.Order
+	for i := 0; i < 2; i++ {
+		order := orders.NewOrder(context.TODO(), 50, orders.Vegan)
+		for _, state := range []orders.State{orders.StateQueued, orders.StateValidating, orders.StatePaying} {
+			order.Transition(state)
+		}
+		order.Paid(cashregister.ReturnedAmount{Cents: 20, Formatted: "20 Cent"})
+
+		restored := orders.Restore(order.Snapshot())
+		if err = tm.Put(restored); err != nil {
+			t.Fatalf("failed to send the re-queued order to terminal, err:%v", err)
+		}
+		requeued = append(requeued, restored)
+	}
+
+	workers := NewWorker(tm, cr, WithInventory(inv))
+	go workers.Run()
+
+	for _, order := range requeued {
+		if err = order.WaitWithTimeout(time.Second * 20); err != nil {
+			t.Fatalf("expected nil error, got:%v", err)
+		}
+	}
+
+	// the first order is prepared without being paid again, it keeps its change
+	first := requeued[0]
+	if first.State() != orders.StateReady || first.Returned.Cents != 20 {
+		t.Errorf("expected order state %s with 20 cents returned, got:%s with %d", orders.StateReady, first.State(), first.Returned.Cents)
+	}
+
+	// the second order can not be made anymore, it is refunded in full
+	second := requeued[1]
+	if second.State() != orders.StateFailed || second.Returned.Cents != 50 {
+		t.Errorf("expected order state %s with 50 cents returned, got:%s with %d", orders.StateFailed, second.State(), second.Returned.Cents)
+	}
+}
@@ -1,3 +1,3 @@
 // Package pkg contains the order types that are used by the internal packages and the api-server.
-// It defines the constants and the functions for the order types, such as vegan, non-vegan.
+// It defines the constants and the functions for the order types, such as vegan, non-vegan, fries and drink.
 package pkg
@@ -1,5 +1,7 @@
 package pkg
 
+import "sort"
+
 // OrderType is an interface that represents a type of order that can be processed
 // by the cash register. It defines two methods: Name and Price, which return the
 // name and the price of the order type, respectively. The cash register package uses
@@ -19,12 +21,40 @@ type OrderType interface {
 	Name() string
 	// Price returns the price of the order type, in euros.
 	Price() int
+	// TaxCategory returns the VAT category of the order type, such as food or beverage.
+	TaxCategory() TaxCategory
 }
 
+// TaxCategory is the VAT category of an order type, it decides which VAT rate applies to it.
+type TaxCategory string
+
+// Define the possible values for the tax category
+const (
+	// Food is taxed at the reduced rate when it is taken away and at the standard rate when it is eaten in.
+	Food TaxCategory = "food"
+	// Beverage is always taxed at the standard rate.
+	Beverage TaxCategory = "beverage"
+)
+
 // validOrderTypes is a map that stores the valid order types by their names
 var validOrderTypes = map[string]OrderType{
 	"vegan":     Vegan{},
 	"non-vegan": NonVegan{},
+	"fries":     Fries{},
+	"drink":     Drink{},
+}
+
+// OrderTypes returns all the valid order types, sorted by name.
+func OrderTypes() []OrderType {
+	orderTypes := make([]OrderType, 0, len(validOrderTypes))
+	for _, orderType := range validOrderTypes {
+		orderTypes = append(orderTypes, orderType)
+	}
+	sort.Slice(orderTypes, func(i, j int) bool {
+		return orderTypes[i].Name() < orderTypes[j].Name()
+	})
+
+	return orderTypes
 }
 
 // GetOrderType returns the order type by its name, or nil if not found.
@@ -50,6 +80,11 @@ func (v Vegan) Price() int {
 	return 30
 }
 
+// TaxCategory returns the tax category of the vegan order type
+func (v Vegan) TaxCategory() TaxCategory {
+	return Food
+}
+
 // NonVegan is a type of order that is not vegan
 type NonVegan struct{}
 
@@ -62,3 +97,44 @@ func (n NonVegan) Name() string {
 func (n NonVegan) Price() int {
 	return 35
 }
+
+// TaxCategory returns the tax category of the non-vegan order type
+func (n NonVegan) TaxCategory() TaxCategory {
+	return Food
+}
+
+// Fries is a type of order that is a portion of fries
+type Fries struct{}
+
+// Name returns the name of the fries order type
+func (f Fries) Name() string {
+	return "fries"
+}
+
+// Price returns the price of the fries order type
+func (f Fries) Price() int {
+	return 25
+}
+
+// TaxCategory returns the tax category of the fries order type
+func (f Fries) TaxCategory() TaxCategory {
+	return Food
+}
+
+// Drink is a type of order that is a soft drink
+type Drink struct{}
+
+// Name returns the name of the drink order type
+func (d Drink) Name() string {
+	return "drink"
+}
+
+// Price returns the price of the drink order type
+func (d Drink) Price() int {
+	return 20
+}
+
+// TaxCategory returns the tax category of the drink order type
+func (d Drink) TaxCategory() TaxCategory {
+	return Beverage
+}
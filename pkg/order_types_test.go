@@ -34,3 +34,17 @@ func TestGetOrderType(t *testing.T) {
 		})
 	}
 }
+
+func TestOrderTypes(t *testing.T) {
+	want := []string{"drink", "fries", "non-vegan", "vegan"}
+
+	orderTypes := OrderTypes()
+	if len(orderTypes) != len(want) {
+		t.Fatalf("OrderTypes() got %d order types, want:%d", len(orderTypes), len(want))
+	}
+	for i, orderType := range orderTypes {
+		if orderType.Name() != want[i] {
+			t.Errorf("OrderTypes()[%d] = %s, want:%s", i, orderType.Name(), want[i])
+		}
+	}
+}